@@ -0,0 +1,98 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andrewkroh/traefik-github-auth/internal/github"
+	"github.com/andrewkroh/traefik-github-auth/internal/otelsetup"
+	"github.com/andrewkroh/traefik-github-auth/internal/validator"
+)
+
+// runCheck implements the "check" subcommand, which validates a single
+// token exactly as the server would and prints the resulting identity.
+// It returns the process exit code: 0 on success, 1 if the token is
+// rejected, and 2 for usage errors.
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("traefik-github-auth check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var (
+		org               string
+		token             string
+		baseURL           string
+		rejectClassicPATs bool
+		timeout           time.Duration
+	)
+	fs.StringVar(&org, "org", "", "GitHub organization, or comma-separated list of organizations, to validate membership against (required)")
+	fs.StringVar(&token, "token", "", "GitHub token to validate (defaults to $GITHUB_TOKEN)")
+	fs.StringVar(&baseURL, "github-base-url", os.Getenv("GITHUB_API_BASE_URL"), "GitHub API base URL (default: $GITHUB_API_BASE_URL or https://api.github.com)")
+	fs.BoolVar(&rejectClassicPATs, "reject-classic-pats", true, "Whether to reject classic PATs")
+	fs.DurationVar(&timeout, "timeout", 30*time.Second, "Maximum duration of the validation")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	orgs := splitList(org)
+	if len(orgs) == 0 || token == "" {
+		fmt.Fprintf(stderr, "Error: -org and -token are required\n\n")
+		fs.Usage()
+		return 2
+	}
+
+	logger := slog.New(otelsetup.NewContextHandler(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	var ghOpts []github.Option
+	if baseURL != "" {
+		ghOpts = append(ghOpts, github.WithBaseURL(baseURL))
+	}
+	ghOpts = append(ghOpts, github.WithLogger(logger))
+	ghClient := github.NewHTTPClient(ghOpts...)
+
+	v := validator.New(ghClient, noopCache{}, orgs, rejectClassicPATs, logger,
+		validator.WithTimeout(timeout))
+
+	res, err := v.Validate(context.Background(), token)
+	if err != nil {
+		fmt.Fprintf(stderr, "Token rejected: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "login: %s\n", res.Login)
+	fmt.Fprintf(stdout, "id: %d\n", res.ID)
+	fmt.Fprintf(stdout, "org: %s\n", res.Org)
+	fmt.Fprintf(stdout, "teams: %s\n", strings.Join(res.Teams, ","))
+	if !res.TokenExpiresAt.IsZero() {
+		fmt.Fprintf(stdout, "token expires: %s\n", res.TokenExpiresAt.Format(time.RFC3339))
+	}
+	return 0
+}
+
+// noopCache is a validator.Cache that stores nothing, so every validation
+// calls GitHub.
+type noopCache struct{}
+
+var _ validator.Cache = noopCache{}
+
+func (noopCache) Get(string) (validator.ValidationResult, error, bool) {
+	return validator.ValidationResult{}, nil, false
+}
+
+func (noopCache) Set(string, validator.ValidationResult, error) {}
+
+func (noopCache) SetWithTTL(string, validator.ValidationResult, error, time.Duration) {}
+
+func (noopCache) Delete(string) {}
@@ -0,0 +1,80 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message":"Bad credentials"}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/user":
+			fmt.Fprint(w, `{"login":"octocat","id":1}`)
+		case "/orgs/my-org/members/octocat":
+			w.WriteHeader(http.StatusNoContent)
+		case "/user/teams":
+			fmt.Fprint(w, `[{"slug":"backend","organization":{"login":"my-org"}}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_API_BASE_URL", srv.URL)
+	t.Setenv("GITHUB_TOKEN", "")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "valid token",
+			args:       []string{"-org", "my-org", "-token", "good-token"},
+			wantCode:   0,
+			wantStdout: "login: octocat\nid: 1\norg: my-org\nteams: backend\n",
+		},
+		{
+			name:       "invalid token",
+			args:       []string{"-org", "my-org", "-token", "bad-token"},
+			wantCode:   1,
+			wantStderr: "Token rejected: unauthorized",
+		},
+		{
+			name:       "missing token",
+			args:       []string{"-org", "my-org"},
+			wantCode:   2,
+			wantStderr: "-org and -token are required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runCheck(tt.args, &stdout, &stderr)
+			if code != tt.wantCode {
+				t.Fatalf("runCheck() = %d, want %d (stderr: %s)", code, tt.wantCode, stderr.String())
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
@@ -8,42 +8,283 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/andrewkroh/traefik-github-auth/internal/cache"
+	"github.com/andrewkroh/traefik-github-auth/internal/denylist"
 	"github.com/andrewkroh/traefik-github-auth/internal/github"
 	"github.com/andrewkroh/traefik-github-auth/internal/handler"
+	"github.com/andrewkroh/traefik-github-auth/internal/health"
 	"github.com/andrewkroh/traefik-github-auth/internal/otelsetup"
+	"github.com/andrewkroh/traefik-github-auth/internal/quota"
 	"github.com/andrewkroh/traefik-github-auth/internal/validator"
 )
 
+// membershipRetryDelay is how long to wait before retrying a 404 org
+// membership check when -retry-membership-404 is set.
+const membershipRetryDelay = 500 * time.Millisecond
+
 // version is set at build time via -ldflags "-X main.version=v1.0.0".
 var version = "dev"
 
 // Config holds the server configuration parsed from CLI flags.
 type Config struct {
 	// Org is the GitHub organization name to validate membership against.
+	// It may be a comma-separated list, in which case membership in any
+	// one of the organizations is sufficient.
 	Org string
 
 	// Listen is the HTTP listen address.
 	Listen string
 
+	// SuccessStatus is the 2xx status code returned for a valid token.
+	SuccessStatus int
+
+	// MaxTokenLength is the longest accepted token in bytes, or 0 for no
+	// limit.
+	MaxTokenLength int
+
+	// HeaderSigningKey, if set, is the HMAC key used to sign the identity
+	// headers.
+	HeaderSigningKey string
+
+	// CORSAllowOrigin, if set, is the origin (or "*") whose CORS preflight
+	// requests are answered without validation.
+	CORSAllowOrigin string
+
+	// EnableEmail keeps the user's public email through the whole
+	// pipeline and sends it in the email identity header. When false the
+	// email is discarded by the GitHub client.
+	EnableEmail bool
+
+	// ForwardUserFields lists the optional user attributes (node_id,
+	// avatar_url) sent in identity headers.
+	ForwardUserFields []string
+
+	// ForwardTeamNames sends the display names of the user's teams in the
+	// team names identity header.
+	ForwardTeamNames bool
+
+	// AuditLog logs one audit record per validation decision.
+	AuditLog bool
+
+	// CacheStatusHeader adds X-Auth-Cache and X-Auth-Cache-Age headers
+	// to successful responses for debugging.
+	CacheStatusHeader bool
+
+	// FetchOrgRole looks up the user's role in the org and sends it in the
+	// org role identity header.
+	FetchOrgRole bool
+
+	// RejectInjectedHeaders rejects requests that already carry identity
+	// headers.
+	RejectInjectedHeaders bool
+
+	// Pprof enables the net/http/pprof handlers on the admin listener.
+	Pprof bool
+
+	// AdminListen, if set, is a separate HTTP listen address serving the
+	// health, readiness, version, and metrics routes instead of Listen.
+	AdminListen string
+
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout, and IdleTimeout are set
+	// on the http.Server. Zero means no timeout.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// LogLevel is the minimum level of emitted log records.
+	LogLevel slog.Level
+
+	// LogFormat selects the log encoding (json or text).
+	LogFormat string
+
+	// TLSCert and TLSKey are paths to the PEM certificate and key used to
+	// serve TLS. Both must be set together; when unset the listener is
+	// plaintext.
+	TLSCert string
+	TLSKey  string
+
+	// TLSClientCA is the path to a PEM bundle of CAs used to verify client
+	// certificates. When set, clients must present a valid certificate.
+	TLSClientCA string
+
 	// CacheTTL is the duration for which cached validation results are valid.
 	CacheTTL time.Duration
 
+	// CacheKeySalt, if set, is the salt used to derive cache keys from
+	// tokens. Otherwise a random salt is generated at startup.
+	CacheKeySalt string
+
+	// WarmupTokensFile, if set, is a file of tokens that are validated at
+	// startup to populate the cache before traffic is accepted.
+	WarmupTokensFile string
+
+	// IdentityCacheTTL is the duration for which a token's user identity
+	// is cached separately from the validation result. Zero disables the
+	// identity cache.
+	IdentityCacheTTL time.Duration
+
+	// ValidateTimeout bounds each token validation, including all of its
+	// GitHub API requests. Zero disables the bound.
+	ValidateTimeout time.Duration
+
+	// ErrorCacheTTL is how long unexpected GitHub errors are cached to
+	// debounce retries. Zero disables error caching.
+	ErrorCacheTTL time.Duration
+
+	// ForbiddenCacheTTL is how long not-org-member and classic PAT
+	// rejections are cached. Zero uses CacheTTL.
+	ForbiddenCacheTTL time.Duration
+
+	// MaxEntryLifetime caps how long a cache entry may live across
+	// refreshes. Zero disables the cap.
+	MaxEntryLifetime time.Duration
+
+	// CacheTTLJitter is the fraction of the TTL by which successful cache
+	// entry lifetimes are randomized, e.g. 0.1 for ±10%.
+	CacheTTLJitter float64
+
 	// CacheMaxSize is the maximum number of entries in the token cache.
 	CacheMaxSize int
 
 	// RejectClassicPATs controls whether classic PATs are rejected.
 	RejectClassicPATs bool
+
+	// RequireTokenPrefix rejects tokens that do not start with a known
+	// GitHub token prefix without calling GitHub.
+	RequireTokenPrefix bool
+
+	// LoginRegex, when set, is a regular expression that the user's GitHub
+	// login must fully match.
+	LoginRegex string
+
+	// RequireTeams, when non-empty, restricts access to members of at least
+	// one of these team slugs.
+	RequireTeams []string
+
+	// TeamHeaders maps team slugs to the names of boolean response headers
+	// reporting the user's membership in each team.
+	TeamHeaders map[string]string
+
+	// DisableTeams skips listing the user's teams and omits the
+	// X-Auth-User-Teams header.
+	DisableTeams bool
+
+	// TeamsBestEffort authorizes org members even when the team lookup
+	// fails, reporting X-Auth-Teams-Status: degraded.
+	TeamsBestEffort bool
+
+	// RetryMembership404 retries an org membership check once after a
+	// short delay when GitHub responds 404.
+	RetryMembership404 bool
+
+	// CircuitBreakerThreshold is the number of consecutive GitHub failures
+	// after which calls fail fast for CircuitBreakerCooldown. 0 disables
+	// the circuit breaker.
+	CircuitBreakerThreshold int
+
+	// CircuitBreakerCooldown is how long the circuit breaker stays open
+	// before probing GitHub again.
+	CircuitBreakerCooldown time.Duration
+
+	// TokenDenylistFile, if set, is a file of SHA-256 token hashes that
+	// are rejected. It is reloaded on SIGHUP.
+	TokenDenylistFile string
+
+	// GitHubBaseURL, if set, is the GitHub API base URL (e.g. a GitHub
+	// Enterprise Server API endpoint). It takes precedence over the
+	// GITHUB_API_BASE_URL environment variable.
+	GitHubBaseURL string
+
+	// GitHubProxy, if set, is the URL of an HTTP proxy for GitHub API
+	// requests. Otherwise the standard proxy environment variables apply.
+	GitHubProxy string
+
+	// ConditionalRequests sends If-None-Match with stored ETags when
+	// listing teams.
+	ConditionalRequests bool
+
+	// MaxTeamPages is the maximum number of team pages followed when
+	// listing a user's teams.
+	MaxTeamPages int
+
+	// TeamsHeaderFormat selects how the teams header value is encoded
+	// (csv or json).
+	TeamsHeaderFormat string
+
+	// HeaderPrefix is the prefix of the identity response headers.
+	HeaderPrefix string
+
+	// TrustedProxies are the CIDR prefixes of proxies whose
+	// X-Forwarded-For entries are trusted for the client source IP.
+	TrustedProxies []netip.Prefix
+
+	// ForwardedAuthHeader, when set, names a header from which the bearer
+	// token is read if the Authorization header is absent.
+	ForwardedAuthHeader string
+
+	// TokenCookie, when set, names a cookie from which the token is read
+	// if the Authorization header is absent.
+	TokenCookie string
+
+	// ReadyProbeInterval is how often GitHub reachability is probed to gate
+	// /ready. Zero, the default, disables the probe.
+	ReadyProbeInterval time.Duration
+
+	// ReadyFailureThreshold is the number of consecutive failed probes
+	// after which /ready returns 503.
+	ReadyFailureThreshold int
+
+	// UsageReportInterval is how often a GitHub API usage summary is
+	// logged. Zero disables the summary.
+	UsageReportInterval time.Duration
+
+	// EnableMetrics exposes Prometheus metrics at GET /metrics.
+	EnableMetrics bool
+
+	// DrainOnShutdown controls whether new validations are rejected with
+	// 503 as soon as a shutdown signal is received.
+	DrainOnShutdown bool
+
+	// ShutdownDrainDelay is how long to keep serving after /ready starts
+	// failing on shutdown, giving load balancers time to stop routing
+	// new traffic.
+	ShutdownDrainDelay time.Duration
+
+	// ShutdownTimeout bounds how long shutdown waits for in-flight
+	// requests and telemetry to be flushed.
+	ShutdownTimeout time.Duration
+
+	// ClassicPATDetection selects how classic PATs are detected
+	// (header, prefix, or any).
+	ClassicPATDetection string
+
+	// GitHubAppID is the ID of the GitHub App whose installation token is
+	// used for org-scoped membership calls. Zero disables app auth.
+	GitHubAppID int64
+
+	// GitHubAppPrivateKey is the path to the GitHub App's PEM private key.
+	GitHubAppPrivateKey string
+
+	// GitHubAppInstallationID is the installation ID of the GitHub App
+	// within the organization.
+	GitHubAppInstallationID int64
 }
 
 // parseFlags parses CLI flags from the given arguments into a Config.
@@ -54,11 +295,97 @@ func parseFlags(args []string) (*Config, error) {
 
 	cfg := &Config{}
 
-	fs.StringVar(&cfg.Org, "org", "", "GitHub organization name to validate membership against (required)")
+	fs.StringVar(&cfg.Org, "org", "", "GitHub organization, or comma-separated list of organizations, to validate membership against (required)")
 	fs.StringVar(&cfg.Listen, "listen", ":8080", "HTTP listen address")
+	fs.IntVar(&cfg.MaxTokenLength, "max-token-length", 500, "Maximum token length in bytes; longer tokens are rejected with 401 (0 disables)")
+	fs.IntVar(&cfg.SuccessStatus, "success-status", http.StatusOK, "HTTP status code (2xx) returned for a valid token, e.g. 204")
+	fs.StringVar(&cfg.HeaderSigningKey, "header-signing-key", "", "Shared secret used to HMAC-sign the identity headers in X-Auth-Signature (prefer GITHUB_AUTH_HEADER_SIGNING_KEY)")
+	fs.StringVar(&cfg.CORSAllowOrigin, "cors-allow-origin", "", "Origin (e.g. https://app.example.com) or * whose CORS preflight requests are answered with 204 without validation (optional)")
+	fs.BoolVar(&cfg.EnableEmail, "enable-email", false, "Read the user's public profile email from GitHub and forward it in the X-Auth-User-Email header")
+	fs.BoolVar(&cfg.FetchOrgRole, "fetch-org-role", false, "Look up the user's org role (admin or member) and forward it in the X-Auth-User-Org-Role header; costs one more GitHub API call per validation")
+	fs.Func("forward-user-fields", "Comma-separated optional user fields to forward in identity headers: node_id, avatar_url (optional)", func(s string) error {
+		cfg.ForwardUserFields = splitList(s)
+		return nil
+	})
+	fs.BoolVar(&cfg.ForwardTeamNames, "forward-team-names", false, "Forward the display names of the user's teams in the X-Auth-User-Team-Names header")
+	fs.BoolVar(&cfg.AuditLog, "audit-log", false, "Log one audit record with a fixed set of fields for every validation decision")
+	fs.BoolVar(&cfg.CacheStatusHeader, "cache-status-header", false, "Report whether a successful result was cached in the X-Auth-Cache and X-Auth-Cache-Age response headers")
+	fs.BoolVar(&cfg.RejectInjectedHeaders, "reject-injected-headers", true, "Reject requests that already carry identity headers; disable only on trusted internal networks")
+	fs.BoolVar(&cfg.Pprof, "pprof", false, "Serve net/http/pprof handlers under /debug/pprof/ on the admin listener (requires -admin-listen)")
+	fs.StringVar(&cfg.AdminListen, "admin-listen", "", "Separate HTTP listen address for /healthz, /ready, /version, and /metrics (default: serve them on -listen)")
+	fs.TextVar(&cfg.LogLevel, "log-level", slog.LevelInfo, "Minimum log level: debug, info, warn, or error")
+	fs.StringVar(&cfg.LogFormat, "log-format", string(otelsetup.LogFormatJSON), "Log encoding: json or text")
+	fs.DurationVar(&cfg.ReadHeaderTimeout, "read-header-timeout", 5*time.Second, "Maximum time to read request headers (0 disables)")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", 10*time.Second, "Maximum time to read an entire request (0 disables)")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", 30*time.Second, "Maximum time to write a response (0 disables)")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", 2*time.Minute, "Maximum time to keep an idle keep-alive connection open (0 disables)")
+	fs.StringVar(&cfg.TLSCert, "tls-cert", "", "Path to the PEM TLS certificate (enables TLS, requires -tls-key)")
+	fs.StringVar(&cfg.TLSKey, "tls-key", "", "Path to the PEM TLS private key (requires -tls-cert)")
+	fs.StringVar(&cfg.TLSClientCA, "tls-client-ca", "", "Path to a PEM CA bundle; when set, clients must present a certificate signed by it (mutual TLS)")
 	fs.DurationVar(&cfg.CacheTTL, "cache-ttl", 5*time.Minute, "Cache TTL duration")
+	fs.StringVar(&cfg.CacheKeySalt, "cache-key-salt", "", "Secret salt for deriving cache keys from tokens (default: random per process; prefer GITHUB_AUTH_CACHE_KEY_SALT)")
+	fs.StringVar(&cfg.WarmupTokensFile, "warmup-tokens-file", "", "File of tokens (one per line) to validate at startup to warm the cache (optional)")
+	fs.DurationVar(&cfg.IdentityCacheTTL, "identity-cache-ttl", 0, "Duration to cache a token's user identity separately from the result, e.g. 1h; set longer than -cache-ttl (0 disables)")
+	fs.Float64Var(&cfg.CacheTTLJitter, "cache-ttl-jitter", 0.1, "Fraction of the TTL by which successful cache entry lifetimes are randomized, e.g. 0.1 for ±10% (0 disables)")
 	fs.IntVar(&cfg.CacheMaxSize, "cache-max-size", 1000, "Maximum number of entries in the token cache")
+	fs.DurationVar(&cfg.ValidateTimeout, "validate-timeout", 0, "Maximum duration of a token validation including all GitHub API calls, e.g. 5s; exceeding it returns 504 (0 disables)")
+	fs.DurationVar(&cfg.ForbiddenCacheTTL, "forbidden-cache-ttl", time.Minute, "Duration to cache not-org-member and classic PAT rejections (0 uses -cache-ttl)")
+	fs.DurationVar(&cfg.ErrorCacheTTL, "error-cache-ttl", 0, "Duration to cache unexpected GitHub errors, e.g. 1s (0 disables)")
+	fs.DurationVar(&cfg.MaxEntryLifetime, "max-entry-lifetime", 0, "Maximum lifetime of a cache entry regardless of refreshes (0 disables)")
 	fs.BoolVar(&cfg.RejectClassicPATs, "reject-classic-pats", true, "Whether to reject classic PATs")
+	fs.BoolVar(&cfg.RequireTokenPrefix, "require-token-prefix", false, "Reject tokens without a known GitHub token prefix (github_pat_, ghp_, gho_, ghu_) without calling GitHub")
+	fs.StringVar(&cfg.LoginRegex, "login-regex", "", "Regular expression the GitHub login must fully match (optional)")
+	fs.Func("require-teams", "Comma-separated team slugs; users must belong to at least one (optional)", func(s string) error {
+		cfg.RequireTeams = splitList(s)
+		return nil
+	})
+	fs.Func("team-headers", "Comma-separated slug=Header pairs; each header is set to true or false by team membership, e.g. admins=X-Is-Admin (optional)", func(s string) error {
+		m, err := parseTeamHeaders(s)
+		if err != nil {
+			return err
+		}
+		cfg.TeamHeaders = m
+		return nil
+	})
+	fs.BoolVar(&cfg.DisableTeams, "disable-teams", false, "Skip listing the user's teams and omit the X-Auth-User-Teams header; -require-teams is then checked per team")
+	fs.BoolVar(&cfg.TeamsBestEffort, "teams-best-effort", false, "Allow org members when the team lookup fails, with X-Auth-Teams-Status: degraded")
+	fs.BoolVar(&cfg.RetryMembership404, "retry-membership-404", false, "Retry an org membership check once after a short delay when GitHub responds 404")
+	fs.IntVar(&cfg.CircuitBreakerThreshold, "circuit-breaker-threshold", 0, "Consecutive GitHub API failures after which calls fail fast with 503 (0 disables)")
+	fs.DurationVar(&cfg.CircuitBreakerCooldown, "circuit-breaker-cooldown", 30*time.Second, "How long the circuit breaker stays open before probing GitHub again")
+	fs.StringVar(&cfg.TokenDenylistFile, "token-denylist-file", "", "File of hex SHA-256 token hashes to reject, one per line; reloaded on SIGHUP")
+	fs.StringVar(&cfg.GitHubBaseURL, "github-base-url", "", "GitHub API base URL, e.g. https://ghe.example.com/api/v3 (default: $GITHUB_API_BASE_URL or https://api.github.com)")
+	fs.StringVar(&cfg.GitHubProxy, "github-proxy", "", "URL of an HTTP proxy for GitHub API requests (default: HTTPS_PROXY/NO_PROXY from the environment)")
+	fs.BoolVar(&cfg.ConditionalRequests, "conditional-requests", false, "Use ETag conditional requests when listing teams to save GitHub rate limit budget")
+	fs.IntVar(&cfg.MaxTeamPages, "max-team-pages", 50, "Maximum number of pages to follow when listing a user's teams")
+	fs.StringVar(&cfg.TeamsHeaderFormat, "teams-header-format", string(handler.TeamsFormatCSV), "Encoding of the teams header value: csv or json")
+	fs.StringVar(&cfg.HeaderPrefix, "header-prefix", "X-Auth-User-", "Prefix of the identity response headers")
+	fs.Func("trusted-proxies", "Comma-separated CIDRs of proxies whose X-Forwarded-For entries are trusted (optional)", func(s string) error {
+		prefixes, err := parsePrefixes(s)
+		if err != nil {
+			return err
+		}
+		cfg.TrustedProxies = prefixes
+		return nil
+	})
+	fs.StringVar(&cfg.ForwardedAuthHeader, "forwarded-auth-header", "", "Header to read the bearer token from when the Authorization header is absent, e.g. X-Forwarded-Authorization (optional)")
+	fs.StringVar(&cfg.TokenCookie, "token-cookie", "", "Cookie to read the token from when the Authorization header is absent (optional)")
+	fs.DurationVar(&cfg.ReadyProbeInterval, "ready-probe-interval", 0, "Interval between GitHub reachability probes that gate /ready (0 disables; opt-in)")
+	fs.IntVar(&cfg.ReadyFailureThreshold, "ready-failure-threshold", 3, "Consecutive failed GitHub probes before /ready returns 503")
+	fs.DurationVar(&cfg.UsageReportInterval, "usage-report-interval", time.Minute, "Interval between GitHub API usage summary logs (0 disables)")
+	fs.BoolVar(&cfg.EnableMetrics, "enable-metrics", false, "Expose Prometheus metrics at GET /metrics")
+	fs.BoolVar(&cfg.DrainOnShutdown, "drain-on-shutdown", true, "Reject new validations with 503 once shutdown begins")
+	fs.DurationVar(&cfg.ShutdownDrainDelay, "shutdown-drain-delay", 0, "Time to keep serving after /ready fails on shutdown, before draining")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", 10*time.Second, "Maximum time to wait for in-flight requests and telemetry export on shutdown")
+	fs.StringVar(&cfg.ClassicPATDetection, "classic-pat-detection", string(github.DetectByAny), "How to detect classic PATs: header, prefix, or any")
+	fs.Int64Var(&cfg.GitHubAppID, "github-app-id", 0, "GitHub App ID used for org-scoped membership calls (optional)")
+	fs.StringVar(&cfg.GitHubAppPrivateKey, "github-app-private-key", "", "Path to the GitHub App private key PEM file")
+	fs.Int64Var(&cfg.GitHubAppInstallationID, "github-app-installation-id", 0, "GitHub App installation ID for the organization")
+
+	// Seed values from the environment; explicit flags override them.
+	if err := applyEnv(fs); err != nil {
+		fmt.Fprintf(fs.Output(), "Error: %v\n", err)
+		return nil, err
+	}
 
 	if err := fs.Parse(args); err != nil {
 		return nil, err
@@ -74,40 +401,454 @@ func parseFlags(args []string) (*Config, error) {
 	return cfg, nil
 }
 
+// envPrefix is prepended to the upper-cased flag name, with dashes replaced
+// by underscores, to form the environment variable read for each flag.
+const envPrefix = "GITHUB_AUTH_"
+
+// envName returns the environment variable that sets the named flag, e.g.
+// GITHUB_AUTH_CACHE_TTL for -cache-ttl.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets each flag in fs from its environment variable, if present.
+// It must be called before fs.Parse so that flags take precedence.
+func applyEnv(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		name := envName(f.Name)
+		if v, ok := os.LookupEnv(name); ok {
+			if setErr := fs.Set(f.Name, v); setErr != nil {
+				err = fmt.Errorf("invalid value %q for environment variable %s: %w", v, name, setErr)
+			}
+		}
+	})
+	return err
+}
+
 // validate checks that the Config has all required fields set and that
 // values are within acceptable ranges.
 func (c *Config) validate() error {
-	if c.Org == "" {
+	if len(c.orgs()) == 0 {
 		return errors.New("flag -org is required")
 	}
+	for _, org := range c.orgs() {
+		if !orgLoginRegex.MatchString(org) {
+			return fmt.Errorf("flag -org contains an invalid organization name %q", org)
+		}
+	}
+	switch otelsetup.LogFormat(c.LogFormat) {
+	case "", otelsetup.LogFormatJSON, otelsetup.LogFormatText:
+	default:
+		return fmt.Errorf("flag -log-format must be one of json or text, got %q", c.LogFormat)
+	}
+	if c.MaxTokenLength < 0 {
+		return fmt.Errorf("flag -max-token-length must not be negative, got %d", c.MaxTokenLength)
+	}
+	if c.SuccessStatus != 0 && (c.SuccessStatus < 200 || c.SuccessStatus > 299) {
+		return fmt.Errorf("flag -success-status must be a 2xx status code, got %d", c.SuccessStatus)
+	}
+	if c.AdminListen != "" && c.AdminListen == c.Listen {
+		return errors.New("flag -admin-listen must differ from -listen")
+	}
+	if c.Pprof && c.AdminListen == "" {
+		return errors.New("flag -pprof requires -admin-listen")
+	}
+	for _, d := range []struct {
+		flag  string
+		value time.Duration
+	}{
+		{"read-header-timeout", c.ReadHeaderTimeout},
+		{"read-timeout", c.ReadTimeout},
+		{"write-timeout", c.WriteTimeout},
+		{"idle-timeout", c.IdleTimeout},
+	} {
+		if d.value < 0 {
+			return fmt.Errorf("flag -%s must be non-negative, got %s", d.flag, d.value)
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("flags -tls-cert and -tls-key must be set together")
+	}
+	if c.TLSClientCA != "" && !c.useTLS() {
+		return errors.New("flag -tls-client-ca requires -tls-cert and -tls-key")
+	}
 	if c.CacheTTL < 0 {
 		return fmt.Errorf("flag -cache-ttl must be non-negative, got %s", c.CacheTTL)
 	}
+	if c.IdentityCacheTTL < 0 {
+		return fmt.Errorf("flag -identity-cache-ttl must be non-negative, got %s", c.IdentityCacheTTL)
+	}
+	if c.ValidateTimeout < 0 {
+		return fmt.Errorf("flag -validate-timeout must be non-negative, got %s", c.ValidateTimeout)
+	}
+	if c.ForbiddenCacheTTL < 0 {
+		return fmt.Errorf("flag -forbidden-cache-ttl must be non-negative, got %s", c.ForbiddenCacheTTL)
+	}
+	if c.ErrorCacheTTL < 0 {
+		return fmt.Errorf("flag -error-cache-ttl must be non-negative, got %s", c.ErrorCacheTTL)
+	}
+	if c.MaxEntryLifetime < 0 {
+		return fmt.Errorf("flag -max-entry-lifetime must be non-negative, got %s", c.MaxEntryLifetime)
+	}
+	if c.CacheTTLJitter < 0 || c.CacheTTLJitter >= 1 {
+		return fmt.Errorf("flag -cache-ttl-jitter must be in [0, 1), got %g", c.CacheTTLJitter)
+	}
 	if c.CacheMaxSize <= 0 {
 		return fmt.Errorf("flag -cache-max-size must be positive, got %d", c.CacheMaxSize)
 	}
+	if c.LoginRegex != "" {
+		if _, err := compileLoginRegex(c.LoginRegex); err != nil {
+			return fmt.Errorf("flag -login-regex is invalid: %w", err)
+		}
+	}
+	if c.DisableTeams && len(c.TeamHeaders) > 0 {
+		return errors.New("flags -disable-teams and -team-headers cannot be used together")
+	}
+	if c.TeamsBestEffort && len(c.RequireTeams) > 0 {
+		return errors.New("flags -teams-best-effort and -require-teams cannot be used together")
+	}
+	if c.MaxTeamPages < 1 {
+		return fmt.Errorf("flag -max-team-pages must be at least 1, got %d", c.MaxTeamPages)
+	}
+	switch handler.TeamsHeaderFormat(c.TeamsHeaderFormat) {
+	case "", handler.TeamsFormatCSV, handler.TeamsFormatJSON:
+	default:
+		return fmt.Errorf("flag -teams-header-format must be one of csv or json, got %q", c.TeamsHeaderFormat)
+	}
+	for _, f := range c.ForwardUserFields {
+		switch handler.UserField(f) {
+		case handler.UserFieldNodeID, handler.UserFieldAvatarURL:
+		default:
+			return fmt.Errorf("flag -forward-user-fields must contain only node_id or avatar_url, got %q", f)
+		}
+	}
+	if strings.ContainsAny(c.HeaderPrefix, " \t\r\n:") {
+		return fmt.Errorf("flag -header-prefix must be a valid header name prefix, got %q", c.HeaderPrefix)
+	}
+	if c.ForwardedAuthHeader != "" && !isToken(c.ForwardedAuthHeader) {
+		return fmt.Errorf("flag -forwarded-auth-header must be a valid header name, got %q", c.ForwardedAuthHeader)
+	}
+	if c.TokenCookie != "" && !isToken(c.TokenCookie) {
+		return fmt.Errorf("flag -token-cookie must be a valid cookie name, got %q", c.TokenCookie)
+	}
+	if c.ReadyProbeInterval < 0 {
+		return fmt.Errorf("flag -ready-probe-interval must be non-negative, got %s", c.ReadyProbeInterval)
+	}
+	if c.ReadyProbeInterval > 0 && c.ReadyFailureThreshold <= 0 {
+		return fmt.Errorf("flag -ready-failure-threshold must be positive, got %d", c.ReadyFailureThreshold)
+	}
+	if c.UsageReportInterval < 0 {
+		return fmt.Errorf("flag -usage-report-interval must be non-negative, got %s", c.UsageReportInterval)
+	}
+	if c.ShutdownDrainDelay < 0 {
+		return fmt.Errorf("flag -shutdown-drain-delay must be non-negative, got %s", c.ShutdownDrainDelay)
+	}
+	if c.CORSAllowOrigin != "" && c.CORSAllowOrigin != "*" {
+		if u, err := url.Parse(c.CORSAllowOrigin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			return fmt.Errorf("flag -cors-allow-origin must be * or an origin such as https://app.example.com, got %q", c.CORSAllowOrigin)
+		}
+	}
+	if c.GitHubBaseURL != "" {
+		if u, err := url.Parse(c.GitHubBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("flag -github-base-url must be an absolute http(s) URL, got %q", c.GitHubBaseURL)
+		}
+	}
+	if c.GitHubProxy != "" {
+		if u, err := url.Parse(c.GitHubProxy); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("flag -github-proxy must be an absolute URL, got %q", c.GitHubProxy)
+		}
+	}
+	if c.CircuitBreakerThreshold < 0 {
+		return fmt.Errorf("flag -circuit-breaker-threshold must be non-negative, got %d", c.CircuitBreakerThreshold)
+	}
+	if c.CircuitBreakerThreshold > 0 && c.CircuitBreakerCooldown <= 0 {
+		return fmt.Errorf("flag -circuit-breaker-cooldown must be positive, got %s", c.CircuitBreakerCooldown)
+	}
+	if c.ShutdownTimeout <= 0 {
+		return fmt.Errorf("flag -shutdown-timeout must be positive, got %s", c.ShutdownTimeout)
+	}
+	if c.ClassicPATDetection != "" {
+		if _, err := github.ParseClassicPATDetection(c.ClassicPATDetection); err != nil {
+			return fmt.Errorf("flag -classic-pat-detection must be one of header, prefix, or any, got %q", c.ClassicPATDetection)
+		}
+	}
+	appFlagsSet := 0
+	for _, set := range []bool{c.GitHubAppID != 0, c.GitHubAppPrivateKey != "", c.GitHubAppInstallationID != 0} {
+		if set {
+			appFlagsSet++
+		}
+	}
+	if appFlagsSet != 0 && appFlagsSet != 3 {
+		return errors.New("flags -github-app-id, -github-app-private-key, and -github-app-installation-id must be set together")
+	}
+	if c.GitHubAppID < 0 || c.GitHubAppInstallationID < 0 {
+		return errors.New("flags -github-app-id and -github-app-installation-id must be positive")
+	}
 	return nil
 }
 
+// orgLoginRegex matches the GitHub organization login charset:
+// alphanumerics and hyphens, not starting with a hyphen. Legacy logins may
+// contain consecutive or trailing hyphens, so those are allowed.
+var orgLoginRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]{0,38}$`)
+
+// compileLoginRegex compiles the -login-regex pattern anchored so that it
+// must match the entire login.
+func compileLoginRegex(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// orgs returns the configured organizations.
+func (c *Config) orgs() []string {
+	return splitList(c.Org)
+}
+
+// splitList splits a comma-separated flag value, trimming whitespace and
+// dropping empty elements.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// parseTeamHeaders parses a comma-separated list of slug=Header pairs.
+func parseTeamHeaders(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range splitList(s) {
+		slug, name, ok := strings.Cut(pair, "=")
+		slug, name = strings.TrimSpace(slug), strings.TrimSpace(name)
+		if !ok || slug == "" || !isToken(name) {
+			return nil, fmt.Errorf("invalid team header %q, want slug=Header-Name", pair)
+		}
+		m[slug] = name
+	}
+	return m, nil
+}
+
+// readTokensFile reads one token per line from path. Blank lines and lines
+// starting with "#" are ignored.
+func readTokensFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []string
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	return tokens, nil
+}
+
+// parsePrefixes parses a comma-separated list of CIDR prefixes.
+func parsePrefixes(s string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, v := range splitList(s) {
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+// isToken reports whether name is a valid cookie or header name (an
+// RFC 7230 token).
+func isToken(name string) bool {
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune(`()<>@,;:\"/[]?={}`, r) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// useTLS reports whether the listener serves TLS.
+func (c *Config) useTLS() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// tlsConfig returns the listener's TLS configuration. The certificate and
+// key are loaded by ListenAndServeTLS. When a client CA is configured,
+// clients must present a certificate that verifies against it.
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.TLSClientCA == "" {
+		return cfg, nil
+	}
+
+	pemBytes, err := os.ReadFile(c.TLSClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, fmt.Errorf("client CA %s contains no PEM certificates", c.TLSClientCA)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
+
+// githubBaseURL returns the GitHub API base URL from -github-base-url or,
+// if unset, the GITHUB_API_BASE_URL environment variable. It returns ""
+// to use the default.
+func (c *Config) githubBaseURL() string {
+	if c.GitHubBaseURL != "" {
+		return c.GitHubBaseURL
+	}
+	return os.Getenv("GITHUB_API_BASE_URL")
+}
+
+// useGitHubApp reports whether GitHub App installation tokens should be
+// used for org-scoped membership calls.
+func (c *Config) useGitHubApp() bool {
+	return c.GitHubAppID != 0
+}
+
+// newGitHubClient builds the GitHub API client from the configuration. A
+// single *http.Client, routed through -github-proxy if set, is shared by
+// API calls and GitHub App installation token minting.
+func newGitHubClient(cfg *Config, logger *slog.Logger) (*github.HTTPClient, error) {
+	hc := http.DefaultClient
+	if cfg.GitHubProxy != "" {
+		var err error
+		if hc, err = github.ProxiedHTTPClient(hc, cfg.GitHubProxy); err != nil {
+			return nil, err
+		}
+	}
+
+	ghOpts := []github.Option{github.WithHTTPClient(hc)}
+	baseURL := cfg.githubBaseURL()
+	if baseURL != "" {
+		ghOpts = append(ghOpts, github.WithBaseURL(baseURL))
+	}
+	ghOpts = append(ghOpts, github.WithLogger(logger))
+	ghOpts = append(ghOpts, github.WithMaxTeamPages(cfg.MaxTeamPages))
+	if cfg.RetryMembership404 {
+		ghOpts = append(ghOpts, github.WithMembershipRetryOn404(membershipRetryDelay))
+	}
+	if cfg.ConditionalRequests {
+		ghOpts = append(ghOpts, github.WithConditionalRequests(true))
+	}
+	ghOpts = append(ghOpts, github.WithClassicPATDetection(github.ClassicPATDetection(cfg.ClassicPATDetection)))
+	if !cfg.EnableEmail {
+		ghOpts = append(ghOpts, github.WithoutEmail())
+	}
+	if cfg.useGitHubApp() {
+		pemBytes, err := os.ReadFile(cfg.GitHubAppPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("reading GitHub App private key: %w", err)
+		}
+		key, err := github.ParsePrivateKey(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing GitHub App private key: %w", err)
+		}
+		appTokens := github.NewAppTokenSource(cfg.GitHubAppID, cfg.GitHubAppInstallationID, key, baseURL, hc)
+		ghOpts = append(ghOpts, github.WithOrgTokenSource(appTokens))
+	}
+	return github.NewHTTPClient(ghOpts...), nil
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(runCheck(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	cfg, err := parseFlags(os.Args[1:])
 	if err != nil {
 		os.Exit(1)
 	}
 
 	// Set up slog with trace context injection.
-	logger := otelsetup.NewLogger(os.Stderr)
+	logger := otelsetup.NewLogger(os.Stderr, cfg.LogLevel, otelsetup.LogFormat(cfg.LogFormat))
 	slog.SetDefault(logger)
 
 	// Set up OpenTelemetry.
+	var otelOpts []otelsetup.Option
+	handlerOpts := []handler.Option{
+		handler.WithHeaderPrefix(cfg.HeaderPrefix),
+		handler.WithVersion(version),
+		handler.WithRejectInjectedHeaders(cfg.RejectInjectedHeaders),
+		handler.WithEmail(cfg.EnableEmail),
+		handler.WithMaxTokenLength(cfg.MaxTokenLength),
+	}
+	if cfg.TeamsHeaderFormat != "" {
+		handlerOpts = append(handlerOpts, handler.WithTeamsHeaderFormat(handler.TeamsHeaderFormat(cfg.TeamsHeaderFormat)))
+	}
+	if len(cfg.TrustedProxies) > 0 {
+		handlerOpts = append(handlerOpts, handler.WithTrustedProxies(cfg.TrustedProxies))
+	}
+	if len(cfg.TeamHeaders) > 0 {
+		handlerOpts = append(handlerOpts, handler.WithTeamHeaders(cfg.TeamHeaders))
+	}
+	if len(cfg.ForwardUserFields) > 0 {
+		fields := make([]handler.UserField, len(cfg.ForwardUserFields))
+		for i, f := range cfg.ForwardUserFields {
+			fields[i] = handler.UserField(f)
+		}
+		handlerOpts = append(handlerOpts, handler.WithUserFields(fields))
+	}
+	if cfg.ForwardTeamNames {
+		handlerOpts = append(handlerOpts, handler.WithTeamNames())
+	}
+	if cfg.CacheStatusHeader {
+		handlerOpts = append(handlerOpts, handler.WithCacheStatus())
+	}
+	if cfg.AuditLog {
+		handlerOpts = append(handlerOpts, handler.WithAuditLog())
+	}
+	if cfg.CORSAllowOrigin != "" {
+		handlerOpts = append(handlerOpts, handler.WithCORSAllowOrigin(cfg.CORSAllowOrigin))
+	}
+	if cfg.ForwardedAuthHeader != "" {
+		handlerOpts = append(handlerOpts, handler.WithForwardedAuthHeader(cfg.ForwardedAuthHeader))
+	}
+	if cfg.TokenCookie != "" {
+		handlerOpts = append(handlerOpts, handler.WithTokenCookie(cfg.TokenCookie))
+	}
+	if cfg.SuccessStatus != 0 {
+		handlerOpts = append(handlerOpts, handler.WithSuccessStatus(cfg.SuccessStatus))
+	}
+	if cfg.HeaderSigningKey != "" {
+		handlerOpts = append(handlerOpts, handler.WithHeaderSigningKey([]byte(cfg.HeaderSigningKey)))
+	}
+	if cfg.AdminListen != "" {
+		handlerOpts = append(handlerOpts, handler.WithSeparateAdminRoutes())
+	}
+	if cfg.Pprof {
+		handlerOpts = append(handlerOpts, handler.WithPprof())
+	}
+	if cfg.EnableMetrics {
+		reader, metricsHandler, err := otelsetup.NewPrometheusReader()
+		if err != nil {
+			slog.Error("failed to set up Prometheus metrics", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		otelOpts = append(otelOpts, otelsetup.WithMetricReader(reader))
+		handlerOpts = append(handlerOpts, handler.WithMetricsHandler(metricsHandler))
+	}
+
 	ctx := context.Background()
-	otelShutdown, err := otelsetup.Setup(ctx, "traefik-github-auth", version)
+	otelShutdown, err := otelsetup.Setup(ctx, "traefik-github-auth", version, otelOpts...)
 	if err != nil {
 		slog.Error("failed to set up OpenTelemetry", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
 	defer func() {
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 		defer cancel()
 		if err := otelShutdown(shutdownCtx); err != nil {
 			slog.Error("OpenTelemetry shutdown error", slog.String("error", err.Error()))
@@ -115,28 +856,153 @@ func main() {
 	}()
 
 	// Create GitHub client.
-	var ghOpts []github.Option
-	if baseURL := os.Getenv("GITHUB_API_BASE_URL"); baseURL != "" {
-		ghOpts = append(ghOpts, github.WithBaseURL(baseURL))
+	ghClient, err := newGitHubClient(cfg, logger)
+	if err != nil {
+		slog.Error("failed to create GitHub client", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	// Gate readiness on GitHub reachability.
+	if cfg.ReadyProbeInterval > 0 {
+		prober := health.NewProber(ghClient.Ping, cfg.ReadyProbeInterval, cfg.ReadyFailureThreshold, logger)
+		defer prober.Stop()
+		handlerOpts = append(handlerOpts, handler.WithReadinessChecker(prober))
 	}
-	ghOpts = append(ghOpts, github.WithLogger(logger))
-	ghClient := github.NewHTTPClient(ghOpts...)
 
 	// Create cache.
-	tokenCache := cache.New(cfg.CacheTTL, cfg.CacheMaxSize)
+	cacheOpts := []cache.Option{
+		cache.WithMaxEntryLifetime(cfg.MaxEntryLifetime),
+		cache.WithTTLJitter(cfg.CacheTTLJitter),
+	}
+	if cfg.CacheKeySalt != "" {
+		cacheOpts = append(cacheOpts, cache.WithKeySalt([]byte(cfg.CacheKeySalt)))
+	}
+	// Results depend on the organizations validated against, so keys are
+	// partitioned by them. Identities do not, so the identity cache is not.
+	tokenCache := cache.New(cfg.CacheTTL, cfg.CacheMaxSize,
+		append(cacheOpts, cache.WithKeyPartition(strings.Join(cfg.orgs(), ",")))...)
 	defer tokenCache.Stop()
 
+	// Periodically summarize GitHub API usage.
+	if cfg.UsageReportInterval > 0 {
+		reporter := quota.NewReporter(ghClient, tokenCache, cfg.UsageReportInterval, logger)
+		defer reporter.Stop()
+	}
+
 	// Create validator.
-	v := validator.New(ghClient, tokenCache, cfg.Org, cfg.RejectClassicPATs, logger)
+	var validatorOpts []validator.Option
+	if cfg.LoginRegex != "" {
+		// Already checked by Config.validate.
+		re, _ := compileLoginRegex(cfg.LoginRegex)
+		validatorOpts = append(validatorOpts, validator.WithLoginPattern(re))
+	}
+	if len(cfg.RequireTeams) > 0 {
+		validatorOpts = append(validatorOpts, validator.WithRequiredTeams(cfg.RequireTeams))
+	}
+	if cfg.RequireTokenPrefix {
+		validatorOpts = append(validatorOpts, validator.WithTokenPrefixes(validator.KnownTokenPrefixes))
+	}
+	if cfg.DisableTeams {
+		validatorOpts = append(validatorOpts, validator.WithTeamsDisabled())
+	}
+	if !cfg.EnableEmail {
+		validatorOpts = append(validatorOpts, validator.WithoutEmail())
+	}
+	if cfg.ForbiddenCacheTTL > 0 {
+		validatorOpts = append(validatorOpts, validator.WithForbiddenCacheTTL(cfg.ForbiddenCacheTTL))
+	}
+	if cfg.ErrorCacheTTL > 0 {
+		validatorOpts = append(validatorOpts, validator.WithErrorCacheTTL(cfg.ErrorCacheTTL))
+	}
+	if cfg.IdentityCacheTTL > 0 {
+		identityCache := cache.New(cfg.IdentityCacheTTL, cfg.CacheMaxSize,
+			append(cacheOpts, cache.WithName("identity"))...)
+		defer identityCache.Stop()
+		validatorOpts = append(validatorOpts, validator.WithIdentityCache(identityCache))
+	}
+	if cfg.ValidateTimeout > 0 {
+		validatorOpts = append(validatorOpts, validator.WithTimeout(cfg.ValidateTimeout))
+	}
+	if cfg.FetchOrgRole {
+		validatorOpts = append(validatorOpts, validator.WithOrgRole())
+	}
+	if cfg.TeamsBestEffort {
+		validatorOpts = append(validatorOpts, validator.WithTeamsBestEffort())
+	}
+	if cfg.TokenDenylistFile != "" {
+		denied, err := denylist.Load(cfg.TokenDenylistFile)
+		if err != nil {
+			slog.Error("failed to load token denylist", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		validatorOpts = append(validatorOpts, validator.WithDenylist(denied))
+
+		// Reload the denylist on SIGHUP.
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+		go func() {
+			for range hup {
+				if err := denied.Reload(); err != nil {
+					slog.Error("failed to reload token denylist", slog.String("error", err.Error()))
+					continue
+				}
+				slog.Info("reloaded token denylist", slog.Int("hashes", denied.Len()))
+			}
+		}()
+	}
+
+	// Fail fast while GitHub is unhealthy.
+	var validatorClient github.Client = ghClient
+	if cfg.CircuitBreakerThreshold > 0 {
+		validatorClient = github.NewCircuitBreaker(ghClient, cfg.CircuitBreakerThreshold, cfg.CircuitBreakerCooldown, logger)
+	}
+	v := validator.New(validatorClient, tokenCache, cfg.orgs(), cfg.RejectClassicPATs, logger, validatorOpts...)
+
+	// Warm the cache before accepting traffic.
+	if cfg.WarmupTokensFile != "" {
+		tokens, err := readTokensFile(cfg.WarmupTokensFile)
+		if err != nil {
+			slog.Error("failed to read warmup tokens", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		warmed := v.Warmup(ctx, tokens)
+		slog.Info("cache warmup complete", slog.Int("tokens", len(tokens)), slog.Int("warmed", warmed))
+	}
 
 	// Create handler.
-	h := handler.New(v, logger)
+	h := handler.New(v, logger, handlerOpts...)
 
 	// Create HTTP server.
 	mux := h.Routes()
 	srv := &http.Server{
-		Addr:    cfg.Listen,
-		Handler: mux,
+		Addr:              cfg.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	if cfg.useTLS() {
+		tlsCfg, err := cfg.tlsConfig()
+		if err != nil {
+			slog.Error("failed to configure TLS", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		srv.TLSConfig = tlsCfg
+	}
+
+	// Optionally serve the admin routes on their own listener.
+	var adminSrv *http.Server
+	if cfg.AdminListen != "" {
+		adminSrv = &http.Server{
+			Addr:              cfg.AdminListen,
+			Handler:           h.AdminRoutes(),
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		}
 	}
 
 	// Graceful shutdown: listen for SIGINT and SIGTERM.
@@ -147,29 +1013,83 @@ func main() {
 	go func() {
 		slog.Info("server starting",
 			slog.String("listen", cfg.Listen),
-			slog.String("org", cfg.Org),
+			slog.String("admin_listen", cfg.AdminListen),
+			slog.Bool("tls", cfg.useTLS()),
+			slog.Bool("mtls", cfg.TLSClientCA != ""),
+			slog.Any("orgs", cfg.orgs()),
 			slog.Duration("cache_ttl", cfg.CacheTTL),
+			slog.Float64("cache_ttl_jitter", cfg.CacheTTLJitter),
 			slog.Int("cache_max_size", cfg.CacheMaxSize),
+			slog.Duration("max_entry_lifetime", cfg.MaxEntryLifetime),
+			slog.Duration("forbidden_cache_ttl", cfg.ForbiddenCacheTTL),
+			slog.Duration("error_cache_ttl", cfg.ErrorCacheTTL),
+			slog.Duration("identity_cache_ttl", cfg.IdentityCacheTTL),
+			slog.Duration("validate_timeout", cfg.ValidateTimeout),
 			slog.Bool("reject_classic_pats", cfg.RejectClassicPATs),
+			slog.String("classic_pat_detection", cfg.ClassicPATDetection),
+			slog.Any("require_teams", cfg.RequireTeams),
+			slog.Bool("disable_teams", cfg.DisableTeams),
+			slog.String("teams_header_format", cfg.TeamsHeaderFormat),
+			slog.String("header_prefix", cfg.HeaderPrefix),
+			slog.Any("trusted_proxies", cfg.TrustedProxies),
+			slog.Bool("github_app", cfg.useGitHubApp()),
+			slog.Bool("enable_metrics", cfg.EnableMetrics),
+			slog.Bool("pprof", cfg.Pprof),
+			slog.Bool("enable_email", cfg.EnableEmail),
+			slog.Bool("reject_injected_headers", cfg.RejectInjectedHeaders),
+			slog.Bool("header_signing", cfg.HeaderSigningKey != ""),
+			slog.Duration("ready_probe_interval", cfg.ReadyProbeInterval),
 			slog.String("version", version),
 		)
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if cfg.useTLS() {
+			err = srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("server error", slog.String("error", err.Error()))
 			os.Exit(1)
 		}
 	}()
 
+	if adminSrv != nil {
+		go func() {
+			if err := adminSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("admin server error", slog.String("error", err.Error()))
+				os.Exit(1)
+			}
+		}()
+	}
+
 	// Wait for shutdown signal.
 	<-ctx.Done()
 	slog.Info("shutting down server")
 
-	// Give outstanding requests 10 seconds to complete.
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Fail readiness first so load balancers stop routing new traffic,
+	// then keep serving for the drain delay before draining.
+	h.StartShutdown()
+	if cfg.ShutdownDrainDelay > 0 {
+		slog.Info("waiting for load balancers to drain", slog.Duration("delay", cfg.ShutdownDrainDelay))
+		time.Sleep(cfg.ShutdownDrainDelay)
+	}
+
+	if cfg.DrainOnShutdown {
+		h.StartDraining()
+	}
+
+	// Give outstanding requests time to complete.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		slog.Error("server shutdown error", slog.String("error", err.Error()))
 	}
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("admin server shutdown error", slog.String("error", err.Error()))
+		}
+	}
 
 	slog.Info("server stopped")
 }
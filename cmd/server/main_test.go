@@ -5,6 +5,25 @@
 package main
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -30,6 +49,75 @@ func TestParseFlags_Defaults(t *testing.T) {
 	if cfg.CacheMaxSize != 1000 {
 		t.Errorf("CacheMaxSize = %d, want %d", cfg.CacheMaxSize, 1000)
 	}
+	if cfg.CacheTTLJitter != 0.1 {
+		t.Errorf("CacheTTLJitter = %v, want %v", cfg.CacheTTLJitter, 0.1)
+	}
+	if cfg.MaxTeamPages != 50 {
+		t.Errorf("MaxTeamPages = %d, want %d", cfg.MaxTeamPages, 50)
+	}
+	if cfg.DrainOnShutdown != true {
+		t.Errorf("DrainOnShutdown = %v, want %v", cfg.DrainOnShutdown, true)
+	}
+	if cfg.ClassicPATDetection != "any" {
+		t.Errorf("ClassicPATDetection = %q, want %q", cfg.ClassicPATDetection, "any")
+	}
+	if cfg.LogLevel != slog.LevelInfo {
+		t.Errorf("LogLevel = %v, want %v", cfg.LogLevel, slog.LevelInfo)
+	}
+	if cfg.LogFormat != "json" {
+		t.Errorf("LogFormat = %q, want %q", cfg.LogFormat, "json")
+	}
+	if cfg.ReadHeaderTimeout != 5*time.Second {
+		t.Errorf("ReadHeaderTimeout = %v, want %v", cfg.ReadHeaderTimeout, 5*time.Second)
+	}
+	if cfg.SuccessStatus != 200 {
+		t.Errorf("SuccessStatus = %d, want 200", cfg.SuccessStatus)
+	}
+	if cfg.ForbiddenCacheTTL != time.Minute {
+		t.Errorf("ForbiddenCacheTTL = %v, want %v", cfg.ForbiddenCacheTTL, time.Minute)
+	}
+	if cfg.MaxTokenLength != 500 {
+		t.Errorf("MaxTokenLength = %d, want 500", cfg.MaxTokenLength)
+	}
+	if cfg.EnableEmail {
+		t.Error("EnableEmail = true, want false")
+	}
+	if !cfg.RejectInjectedHeaders {
+		t.Error("RejectInjectedHeaders = false, want true")
+	}
+	if cfg.CircuitBreakerThreshold != 0 {
+		t.Errorf("CircuitBreakerThreshold = %d, want 0", cfg.CircuitBreakerThreshold)
+	}
+	if cfg.CircuitBreakerCooldown != 30*time.Second {
+		t.Errorf("CircuitBreakerCooldown = %v, want %v", cfg.CircuitBreakerCooldown, 30*time.Second)
+	}
+	if cfg.ShutdownTimeout != 10*time.Second {
+		t.Errorf("ShutdownTimeout = %v, want %v", cfg.ShutdownTimeout, 10*time.Second)
+	}
+	if cfg.ReadTimeout != 10*time.Second {
+		t.Errorf("ReadTimeout = %v, want %v", cfg.ReadTimeout, 10*time.Second)
+	}
+	if cfg.WriteTimeout != 30*time.Second {
+		t.Errorf("WriteTimeout = %v, want %v", cfg.WriteTimeout, 30*time.Second)
+	}
+	if cfg.IdleTimeout != 2*time.Minute {
+		t.Errorf("IdleTimeout = %v, want %v", cfg.IdleTimeout, 2*time.Minute)
+	}
+	if cfg.ReadyProbeInterval != 0 {
+		t.Errorf("ReadyProbeInterval = %v, want 0", cfg.ReadyProbeInterval)
+	}
+	if cfg.ReadyFailureThreshold != 3 {
+		t.Errorf("ReadyFailureThreshold = %d, want %d", cfg.ReadyFailureThreshold, 3)
+	}
+	if cfg.UsageReportInterval != time.Minute {
+		t.Errorf("UsageReportInterval = %v, want %v", cfg.UsageReportInterval, time.Minute)
+	}
+	if cfg.TeamsHeaderFormat != "csv" {
+		t.Errorf("TeamsHeaderFormat = %q, want %q", cfg.TeamsHeaderFormat, "csv")
+	}
+	if cfg.HeaderPrefix != "X-Auth-User-" {
+		t.Errorf("HeaderPrefix = %q, want %q", cfg.HeaderPrefix, "X-Auth-User-")
+	}
 }
 
 func TestParseFlags_CustomValues(t *testing.T) {
@@ -63,6 +151,252 @@ func TestParseFlags_CustomValues(t *testing.T) {
 	}
 }
 
+func TestParseFlags_GitHubBaseURL(t *testing.T) {
+	t.Setenv("GITHUB_API_BASE_URL", "http://env.example.com")
+
+	cfg, err := parseFlags([]string{"-org", "my-org"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.githubBaseURL(); got != "http://env.example.com" {
+		t.Errorf("githubBaseURL() = %q, want the environment value", got)
+	}
+
+	cfg, err = parseFlags([]string{"-org", "my-org", "-github-base-url", "https://ghe.example.com/api/v3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.githubBaseURL(); got != "https://ghe.example.com/api/v3" {
+		t.Errorf("githubBaseURL() = %q, want the flag value", got)
+	}
+
+	if _, err := parseFlags([]string{"-org", "my-org", "-github-base-url", "://bad"}); err == nil {
+		t.Error("expected an error for a malformed -github-base-url")
+	}
+}
+
+func TestParseFlags_GitHubApp(t *testing.T) {
+	args := []string{
+		"-org", "my-org",
+		"-github-app-id", "7",
+		"-github-app-private-key", "/etc/app.pem",
+		"-github-app-installation-id", "42",
+	}
+
+	cfg, err := parseFlags(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.GitHubAppID != 7 {
+		t.Errorf("GitHubAppID = %d, want %d", cfg.GitHubAppID, 7)
+	}
+	if cfg.GitHubAppPrivateKey != "/etc/app.pem" {
+		t.Errorf("GitHubAppPrivateKey = %q, want %q", cfg.GitHubAppPrivateKey, "/etc/app.pem")
+	}
+	if cfg.GitHubAppInstallationID != 42 {
+		t.Errorf("GitHubAppInstallationID = %d, want %d", cfg.GitHubAppInstallationID, 42)
+	}
+	if !cfg.useGitHubApp() {
+		t.Error("useGitHubApp() = false, want true")
+	}
+}
+
+func TestParseFlags_RequireTeams(t *testing.T) {
+	cfg, err := parseFlags([]string{"-org", "my-org", "-require-teams", "platform-eng, sre,,"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"platform-eng", "sre"}
+	if !slices.Equal(cfg.RequireTeams, want) {
+		t.Errorf("RequireTeams = %q, want %q", cfg.RequireTeams, want)
+	}
+}
+
+func TestParseFlags_TrustedProxies(t *testing.T) {
+	cfg, err := parseFlags([]string{"-org", "my-org", "-trusted-proxies", "10.0.0.0/8, 192.0.2.1/24,2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+	if !slices.Equal(cfg.TrustedProxies, want) {
+		t.Errorf("TrustedProxies = %v, want %v", cfg.TrustedProxies, want)
+	}
+}
+
+func TestParseFlags_InvalidTrustedProxies(t *testing.T) {
+	_, err := parseFlags([]string{"-org", "my-org", "-trusted-proxies", "10.0.0.5"})
+	if err == nil {
+		t.Fatal("expected error for a bare IP in -trusted-proxies")
+	}
+}
+
+func TestParseFlags_Env(t *testing.T) {
+	t.Setenv("GITHUB_AUTH_ORG", "env-org")
+	t.Setenv("GITHUB_AUTH_LISTEN", ":9090")
+	t.Setenv("GITHUB_AUTH_CACHE_TTL", "10m")
+	t.Setenv("GITHUB_AUTH_REJECT_CLASSIC_PATS", "false")
+	t.Setenv("GITHUB_AUTH_REQUIRE_TEAMS", "sre,platform")
+
+	cfg, err := parseFlags(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Org != "env-org" {
+		t.Errorf("Org = %q, want %q", cfg.Org, "env-org")
+	}
+	if cfg.Listen != ":9090" {
+		t.Errorf("Listen = %q, want %q", cfg.Listen, ":9090")
+	}
+	if cfg.CacheTTL != 10*time.Minute {
+		t.Errorf("CacheTTL = %v, want %v", cfg.CacheTTL, 10*time.Minute)
+	}
+	if cfg.RejectClassicPATs {
+		t.Errorf("RejectClassicPATs = %v, want %v", cfg.RejectClassicPATs, false)
+	}
+	if want := []string{"sre", "platform"}; !slices.Equal(cfg.RequireTeams, want) {
+		t.Errorf("RequireTeams = %v, want %v", cfg.RequireTeams, want)
+	}
+}
+
+func TestParseFlags_FlagOverridesEnv(t *testing.T) {
+	t.Setenv("GITHUB_AUTH_ORG", "env-org")
+	t.Setenv("GITHUB_AUTH_CACHE_TTL", "10m")
+
+	cfg, err := parseFlags([]string{"-org", "flag-org"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Org != "flag-org" {
+		t.Errorf("Org = %q, want %q", cfg.Org, "flag-org")
+	}
+	// Env values for flags not given on the command line still apply.
+	if cfg.CacheTTL != 10*time.Minute {
+		t.Errorf("CacheTTL = %v, want %v", cfg.CacheTTL, 10*time.Minute)
+	}
+}
+
+func TestParseFlags_InvalidEnv(t *testing.T) {
+	t.Setenv("GITHUB_AUTH_CACHE_TTL", "soon")
+
+	if _, err := parseFlags([]string{"-org", "my-org"}); err == nil {
+		t.Fatal("expected error for an invalid environment value")
+	}
+}
+
+func TestParseFlags_TeamHeaders(t *testing.T) {
+	cfg, err := parseFlags([]string{"-org", "my-org", "-team-headers", "admins=X-Is-Admin, sre = X-Is-SRE"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]string{"admins": "X-Is-Admin", "sre": "X-Is-SRE"}
+	if !maps.Equal(cfg.TeamHeaders, want) {
+		t.Errorf("TeamHeaders = %v, want %v", cfg.TeamHeaders, want)
+	}
+
+	for _, bad := range []string{"admins", "=X-Is-Admin", "admins=", "admins=X Is Admin"} {
+		if _, err := parseFlags([]string{"-org", "my-org", "-team-headers", bad}); err == nil {
+			t.Errorf("expected error for -team-headers %q", bad)
+		}
+	}
+}
+
+func TestParseFlags_MultipleOrgs(t *testing.T) {
+	cfg, err := parseFlags([]string{"-org", "org-a, org-b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"org-a", "org-b"}
+	if !slices.Equal(cfg.orgs(), want) {
+		t.Errorf("orgs() = %q, want %q", cfg.orgs(), want)
+	}
+}
+
+func TestParseFlags_OrgOnlyCommas(t *testing.T) {
+	_, err := parseFlags([]string{"-org", " , "})
+	if err == nil {
+		t.Fatal("expected error when -org contains no names, got nil")
+	}
+}
+
+func TestParseFlags_ServerTimeouts(t *testing.T) {
+	cfg, err := parseFlags([]string{
+		"-org", "my-org",
+		"-read-header-timeout", "2s",
+		"-read-timeout", "3s",
+		"-write-timeout", "4s",
+		"-idle-timeout", "0",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.ReadHeaderTimeout != 2*time.Second {
+		t.Errorf("ReadHeaderTimeout = %v, want %v", cfg.ReadHeaderTimeout, 2*time.Second)
+	}
+	if cfg.ReadTimeout != 3*time.Second {
+		t.Errorf("ReadTimeout = %v, want %v", cfg.ReadTimeout, 3*time.Second)
+	}
+	if cfg.WriteTimeout != 4*time.Second {
+		t.Errorf("WriteTimeout = %v, want %v", cfg.WriteTimeout, 4*time.Second)
+	}
+	if cfg.IdleTimeout != 0 {
+		t.Errorf("IdleTimeout = %v, want 0", cfg.IdleTimeout)
+	}
+}
+
+func TestParseFlags_RejectInjectedHeaders(t *testing.T) {
+	cfg, err := parseFlags([]string{"-org", "my-org", "-reject-injected-headers=false"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RejectInjectedHeaders {
+		t.Error("RejectInjectedHeaders = true, want false")
+	}
+}
+
+func TestParseFlags_ShutdownTimeout(t *testing.T) {
+	cfg, err := parseFlags([]string{"-org", "my-org", "-shutdown-timeout", "45s"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ShutdownTimeout != 45*time.Second {
+		t.Errorf("ShutdownTimeout = %v, want %v", cfg.ShutdownTimeout, 45*time.Second)
+	}
+}
+
+func TestParseFlags_LogLevel(t *testing.T) {
+	tests := []struct {
+		value string
+		want  slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"info", slog.LevelInfo},
+		{"WARN", slog.LevelWarn},
+		{"error", slog.LevelError},
+	}
+	for _, tt := range tests {
+		cfg, err := parseFlags([]string{"-org", "my-org", "-log-level", tt.value})
+		if err != nil {
+			t.Fatalf("-log-level %s: unexpected error: %v", tt.value, err)
+		}
+		if cfg.LogLevel != tt.want {
+			t.Errorf("-log-level %s: LogLevel = %v, want %v", tt.value, cfg.LogLevel, tt.want)
+		}
+	}
+
+	if _, err := parseFlags([]string{"-org", "my-org", "-log-level", "verbose"}); err == nil {
+		t.Error("expected error for an unknown log level")
+	}
+}
+
 func TestParseFlags_OrgRequired(t *testing.T) {
 	_, err := parseFlags([]string{})
 	if err == nil {
@@ -121,6 +455,8 @@ func TestConfig_Validate(t *testing.T) {
 				Listen:            ":8080",
 				CacheTTL:          5 * time.Minute,
 				CacheMaxSize:      1000,
+				ShutdownTimeout:   10 * time.Second,
+				MaxTeamPages:      50,
 				RejectClassicPATs: true,
 			},
 			wantErr: false,
@@ -128,55 +464,913 @@ func TestConfig_Validate(t *testing.T) {
 		{
 			name: "missing org",
 			cfg: Config{
-				Org:          "",
-				Listen:       ":8080",
-				CacheTTL:     5 * time.Minute,
-				CacheMaxSize: 1000,
+				Org:             "",
+				Listen:          ":8080",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+			},
+			wantErr: true,
+		},
+		{
+			name: "org with inner whitespace",
+			cfg: Config{
+				Org:             "my org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+			},
+			wantErr: true,
+		},
+		{
+			name: "org with invalid characters",
+			cfg: Config{
+				Org:             "my-org,other/org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+			},
+			wantErr: true,
+		},
+		{
+			name: "org with surrounding whitespace",
+			cfg: Config{
+				Org:             " my-org ",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+			},
+			wantErr: false,
+		},
+		{
+			name: "admin listen same as listen",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				Listen:          ":8080",
+				AdminListen:     ":8080",
+			},
+			wantErr: true,
+		},
+		{
+			name: "pprof without admin listen",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				Pprof:           true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "pprof with admin listen",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				Listen:          ":8080",
+				AdminListen:     ":9090",
+				Pprof:           true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "separate admin listen",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				Listen:          ":8080",
+				AdminListen:     ":9091",
+			},
+			wantErr: false,
+		},
+		{
+			name: "text log format",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				LogFormat:       "text",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown log format",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				LogFormat:       "xml",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative read header timeout",
+			cfg: Config{
+				Org:               "my-org",
+				CacheTTL:          5 * time.Minute,
+				CacheMaxSize:      1000,
+				ShutdownTimeout:   10 * time.Second,
+				MaxTeamPages:      50,
+				ReadHeaderTimeout: -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative read timeout",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				ReadTimeout:     -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative write timeout",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				WriteTimeout:    -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative idle timeout",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				IdleTimeout:     -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls cert and key",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				TLSCert:         "/etc/tls/tls.crt",
+				TLSKey:          "/etc/tls/tls.key",
+			},
+			wantErr: false,
+		},
+		{
+			name: "tls cert without key",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				TLSCert:         "/etc/tls/tls.crt",
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls key without cert",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				TLSKey:          "/etc/tls/tls.key",
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls client CA with cert and key",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				TLSCert:         "/etc/tls/tls.crt",
+				TLSKey:          "/etc/tls/tls.key",
+				TLSClientCA:     "/etc/tls/ca.crt",
+			},
+			wantErr: false,
+		},
+		{
+			name: "tls client CA without cert and key",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				TLSClientCA:     "/etc/tls/ca.crt",
 			},
 			wantErr: true,
 		},
 		{
 			name: "negative cache TTL",
 			cfg: Config{
-				Org:          "my-org",
-				Listen:       ":8080",
-				CacheTTL:     -1 * time.Second,
-				CacheMaxSize: 1000,
+				Org:             "my-org",
+				Listen:          ":8080",
+				CacheTTL:        -1 * time.Second,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
 			},
 			wantErr: true,
 		},
 		{
 			name: "zero cache TTL is valid",
 			cfg: Config{
-				Org:          "my-org",
-				Listen:       ":8080",
-				CacheTTL:     0,
-				CacheMaxSize: 1000,
+				Org:             "my-org",
+				Listen:          ":8080",
+				CacheTTL:        0,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
 			},
 			wantErr: false,
 		},
 		{
 			name: "zero cache max size",
 			cfg: Config{
-				Org:          "my-org",
-				Listen:       ":8080",
-				CacheTTL:     5 * time.Minute,
-				CacheMaxSize: 0,
+				Org:             "my-org",
+				Listen:          ":8080",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    0,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
 			},
 			wantErr: true,
 		},
 		{
 			name: "negative cache max size",
 			cfg: Config{
-				Org:          "my-org",
-				Listen:       ":8080",
-				CacheTTL:     5 * time.Minute,
-				CacheMaxSize: -1,
+				Org:             "my-org",
+				Listen:          ":8080",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    -1,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
 			},
 			wantErr: true,
 		},
-	}
-
+		{
+			name: "negative identity cache TTL",
+			cfg: Config{
+				Org:              "my-org",
+				CacheTTL:         5 * time.Minute,
+				CacheMaxSize:     1000,
+				ShutdownTimeout:  10 * time.Second,
+				MaxTeamPages:     50,
+				IdentityCacheTTL: -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative validate timeout",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				ValidateTimeout: -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative forbidden cache TTL",
+			cfg: Config{
+				Org:               "my-org",
+				CacheTTL:          5 * time.Minute,
+				CacheMaxSize:      1000,
+				ShutdownTimeout:   10 * time.Second,
+				MaxTeamPages:      50,
+				ForbiddenCacheTTL: -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative error cache TTL",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				ErrorCacheTTL:   -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max entry lifetime",
+			cfg: Config{
+				Org:              "my-org",
+				CacheTTL:         5 * time.Minute,
+				CacheMaxSize:     1000,
+				ShutdownTimeout:  10 * time.Second,
+				MaxTeamPages:     50,
+				MaxEntryLifetime: -1 * time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative cache ttl jitter",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				CacheTTLJitter:  -0.1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "cache ttl jitter of one",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				CacheTTLJitter:  1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid login regex",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				LoginRegex:      `svc-[a-z]+`,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid login regex",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				LoginRegex:      `svc-[a-z`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max team pages",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero max team pages",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    0,
+			},
+			wantErr: true,
+		},
+		{
+			name: "disable teams with require teams",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				DisableTeams:    true,
+				RequireTeams:    []string{"sre"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "disable teams with team headers",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				DisableTeams:    true,
+				TeamHeaders:     map[string]string{"admins": "X-Is-Admin"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "teams best effort with require teams",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				TeamsBestEffort: true,
+				RequireTeams:    []string{"sre"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "classic PAT detection prefix",
+			cfg: Config{
+				Org:                 "my-org",
+				CacheTTL:            5 * time.Minute,
+				CacheMaxSize:        1000,
+				ShutdownTimeout:     10 * time.Second,
+				MaxTeamPages:        50,
+				ClassicPATDetection: "prefix",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown classic PAT detection",
+			cfg: Config{
+				Org:                 "my-org",
+				CacheTTL:            5 * time.Minute,
+				CacheMaxSize:        1000,
+				ShutdownTimeout:     10 * time.Second,
+				MaxTeamPages:        50,
+				ClassicPATDetection: "rate_limit",
+			},
+			wantErr: true,
+		},
+		{
+			name: "json teams header format",
+			cfg: Config{
+				Org:               "my-org",
+				CacheTTL:          5 * time.Minute,
+				CacheMaxSize:      1000,
+				ShutdownTimeout:   10 * time.Second,
+				MaxTeamPages:      50,
+				TeamsHeaderFormat: "json",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown teams header format",
+			cfg: Config{
+				Org:               "my-org",
+				CacheTTL:          5 * time.Minute,
+				CacheMaxSize:      1000,
+				ShutdownTimeout:   10 * time.Second,
+				MaxTeamPages:      50,
+				TeamsHeaderFormat: "yaml",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid forward user fields",
+			cfg: Config{
+				Org:               "my-org",
+				CacheTTL:          5 * time.Minute,
+				CacheMaxSize:      1000,
+				ShutdownTimeout:   10 * time.Second,
+				MaxTeamPages:      50,
+				ForwardUserFields: []string{"node_id", "avatar_url"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown forward user field",
+			cfg: Config{
+				Org:               "my-org",
+				CacheTTL:          5 * time.Minute,
+				CacheMaxSize:      1000,
+				ShutdownTimeout:   10 * time.Second,
+				MaxTeamPages:      50,
+				ForwardUserFields: []string{"site_admin"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative ready probe interval",
+			cfg: Config{
+				Org:                "my-org",
+				CacheTTL:           5 * time.Minute,
+				CacheMaxSize:       1000,
+				ShutdownTimeout:    10 * time.Second,
+				MaxTeamPages:       50,
+				ReadyProbeInterval: -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "ready probe without failure threshold",
+			cfg: Config{
+				Org:                "my-org",
+				CacheTTL:           5 * time.Minute,
+				CacheMaxSize:       1000,
+				ShutdownTimeout:    10 * time.Second,
+				MaxTeamPages:       50,
+				ReadyProbeInterval: 30 * time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "ready probe with failure threshold",
+			cfg: Config{
+				Org:                   "my-org",
+				CacheTTL:              5 * time.Minute,
+				CacheMaxSize:          1000,
+				ShutdownTimeout:       10 * time.Second,
+				MaxTeamPages:          50,
+				ReadyProbeInterval:    30 * time.Second,
+				ReadyFailureThreshold: 3,
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative usage report interval",
+			cfg: Config{
+				Org:                 "my-org",
+				CacheTTL:            5 * time.Minute,
+				CacheMaxSize:        1000,
+				ShutdownTimeout:     10 * time.Second,
+				MaxTeamPages:        50,
+				UsageReportInterval: -time.Minute,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative circuit breaker threshold",
+			cfg: Config{
+				Org:                     "my-org",
+				CacheTTL:                5 * time.Minute,
+				CacheMaxSize:            1000,
+				ShutdownTimeout:         10 * time.Second,
+				MaxTeamPages:            50,
+				CircuitBreakerThreshold: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "circuit breaker without cooldown",
+			cfg: Config{
+				Org:                     "my-org",
+				CacheTTL:                5 * time.Minute,
+				CacheMaxSize:            1000,
+				ShutdownTimeout:         10 * time.Second,
+				MaxTeamPages:            50,
+				CircuitBreakerThreshold: 5,
+			},
+			wantErr: true,
+		},
+		{
+			name: "circuit breaker",
+			cfg: Config{
+				Org:                     "my-org",
+				CacheTTL:                5 * time.Minute,
+				CacheMaxSize:            1000,
+				ShutdownTimeout:         10 * time.Second,
+				MaxTeamPages:            50,
+				CircuitBreakerThreshold: 5,
+				CircuitBreakerCooldown:  30 * time.Second,
+			},
+			wantErr: false,
+		},
+		{
+			name: "cors any origin",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				CORSAllowOrigin: "*",
+			},
+			wantErr: false,
+		},
+		{
+			name: "cors origin",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				CORSAllowOrigin: "https://app.example.com",
+			},
+			wantErr: false,
+		},
+		{
+			name: "cors origin with path",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				CORSAllowOrigin: "https://app.example.com/app",
+			},
+			wantErr: true,
+		},
+		{
+			name: "cors origin without scheme",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				CORSAllowOrigin: "app.example.com",
+			},
+			wantErr: true,
+		},
+		{
+			name: "github base url",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				GitHubBaseURL:   "https://ghe.example.com/api/v3",
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative github base url",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				GitHubBaseURL:   "ghe.example.com/api/v3",
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed github base url",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				GitHubBaseURL:   "https://ghe.example.com:port/api/v3",
+			},
+			wantErr: true,
+		},
+		{
+			name: "non-http github base url",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				GitHubBaseURL:   "ftp://ghe.example.com/api/v3",
+			},
+			wantErr: true,
+		},
+		{
+			name: "github proxy",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				GitHubProxy:     "http://proxy.example.com:3128",
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative github proxy",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				GitHubProxy:     "proxy.example.com",
+			},
+			wantErr: true,
+		},
+		{
+			name: "success status no content",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				SuccessStatus:   204,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success status not 2xx",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				SuccessStatus:   302,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max token length",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				MaxTokenLength:  -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero shutdown timeout",
+			cfg: Config{
+				Org:          "my-org",
+				CacheTTL:     5 * time.Minute,
+				CacheMaxSize: 1000,
+				MaxTeamPages: 50,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative shutdown drain delay",
+			cfg: Config{
+				Org:                "my-org",
+				CacheTTL:           5 * time.Minute,
+				CacheMaxSize:       1000,
+				ShutdownTimeout:    10 * time.Second,
+				MaxTeamPages:       50,
+				ShutdownDrainDelay: -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "forwarded auth header",
+			cfg: Config{
+				Org:                 "my-org",
+				CacheTTL:            5 * time.Minute,
+				CacheMaxSize:        1000,
+				ShutdownTimeout:     10 * time.Second,
+				MaxTeamPages:        50,
+				ForwardedAuthHeader: "X-Forwarded-Authorization",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid forwarded auth header",
+			cfg: Config{
+				Org:                 "my-org",
+				CacheTTL:            5 * time.Minute,
+				CacheMaxSize:        1000,
+				ShutdownTimeout:     10 * time.Second,
+				MaxTeamPages:        50,
+				ForwardedAuthHeader: "X-Forwarded Authorization:",
+			},
+			wantErr: true,
+		},
+		{
+			name: "token cookie",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				TokenCookie:     "gh_token",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid token cookie name",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				TokenCookie:     "gh token;",
+			},
+			wantErr: true,
+		},
+		{
+			name: "custom header prefix",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				HeaderPrefix:    "X-Forwarded-User-",
+			},
+			wantErr: false,
+		},
+		{
+			name: "header prefix with colon",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				HeaderPrefix:    "X-Auth:",
+			},
+			wantErr: true,
+		},
+		{
+			name: "header prefix with CRLF",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				HeaderPrefix:    "X-Auth-\r\nSet-Cookie: a",
+			},
+			wantErr: true,
+		},
+		{
+			name: "github app flags set together",
+			cfg: Config{
+				Org:                     "my-org",
+				CacheTTL:                5 * time.Minute,
+				CacheMaxSize:            1000,
+				ShutdownTimeout:         10 * time.Second,
+				MaxTeamPages:            50,
+				GitHubAppID:             7,
+				GitHubAppPrivateKey:     "/etc/app.pem",
+				GitHubAppInstallationID: 42,
+			},
+			wantErr: false,
+		},
+		{
+			name: "github app id without key or installation",
+			cfg: Config{
+				Org:             "my-org",
+				CacheTTL:        5 * time.Minute,
+				CacheMaxSize:    1000,
+				ShutdownTimeout: 10 * time.Second,
+				MaxTeamPages:    50,
+				GitHubAppID:     7,
+			},
+			wantErr: true,
+		},
+		{
+			name: "github app key without id",
+			cfg: Config{
+				Org:                     "my-org",
+				CacheTTL:                5 * time.Minute,
+				CacheMaxSize:            1000,
+				ShutdownTimeout:         10 * time.Second,
+				MaxTeamPages:            50,
+				GitHubAppPrivateKey:     "/etc/app.pem",
+				GitHubAppInstallationID: 42,
+			},
+			wantErr: true,
+		},
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			err := tt.cfg.validate()
@@ -186,3 +1380,170 @@ func TestConfig_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestCompileLoginRegex_Anchored(t *testing.T) {
+	re, err := compileLoginRegex(`svc-[a-z]+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !re.MatchString("svc-deploy") {
+		t.Error("expected svc-deploy to match")
+	}
+	if re.MatchString("evil-svc-deploy") {
+		t.Error("expected evil-svc-deploy not to match an anchored pattern")
+	}
+}
+
+// writeTestCA writes a self-signed CA certificate in PEM form to a file in
+// a temporary directory and returns its path.
+func writeTestCA(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("writing CA: %v", err)
+	}
+	return path
+}
+
+func TestConfig_TLSConfig(t *testing.T) {
+	t.Run("server TLS only", func(t *testing.T) {
+		cfg := Config{TLSCert: "tls.crt", TLSKey: "tls.key"}
+		tlsCfg, err := cfg.tlsConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if tlsCfg.ClientAuth != tls.NoClientCert {
+			t.Errorf("ClientAuth = %v, want %v", tlsCfg.ClientAuth, tls.NoClientCert)
+		}
+		if tlsCfg.ClientCAs != nil {
+			t.Error("expected no client CAs")
+		}
+	})
+
+	t.Run("mutual TLS", func(t *testing.T) {
+		cfg := Config{TLSCert: "tls.crt", TLSKey: "tls.key", TLSClientCA: writeTestCA(t)}
+		tlsCfg, err := cfg.tlsConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if tlsCfg.ClientAuth != tls.RequireAndVerifyClientCert {
+			t.Errorf("ClientAuth = %v, want %v", tlsCfg.ClientAuth, tls.RequireAndVerifyClientCert)
+		}
+		if tlsCfg.ClientCAs == nil {
+			t.Error("expected client CAs to be set")
+		}
+	})
+
+	t.Run("missing client CA file", func(t *testing.T) {
+		cfg := Config{TLSCert: "tls.crt", TLSKey: "tls.key", TLSClientCA: filepath.Join(t.TempDir(), "missing.crt")}
+		if _, err := cfg.tlsConfig(); err == nil {
+			t.Fatal("expected error for a missing client CA file")
+		}
+	})
+
+	t.Run("client CA without certificates", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "empty.crt")
+		if err := os.WriteFile(path, []byte("not a certificate"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		cfg := Config{TLSCert: "tls.crt", TLSKey: "tls.key", TLSClientCA: path}
+		if _, err := cfg.tlsConfig(); err == nil {
+			t.Fatal("expected error for a client CA without certificates")
+		}
+	})
+}
+
+func TestReadTokensFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(path, []byte("# CI service accounts\ntoken-a\n\n  token-b  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := readTokensFile(path)
+	if err != nil {
+		t.Fatalf("readTokensFile() error: %v", err)
+	}
+	if want := []string{"token-a", "token-b"}; !slices.Equal(tokens, want) {
+		t.Errorf("tokens = %q, want %q", tokens, want)
+	}
+
+	if _, err := readTokensFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestNewGitHubClient_AppTokenThroughProxy(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "app.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		t.Fatalf("writing key: %v", err)
+	}
+
+	// A forward proxy receives the absolute target URL. It stands in for
+	// GitHub so that the test fails if any request bypasses it.
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.Method+" "+r.URL.Host+r.URL.Path)
+		switch r.URL.Path {
+		case "/app/installations/42/access_tokens":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token":"app-token","expires_at":%q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+		case "/orgs/my-org/members/octocat":
+			if got := r.Header.Get("Authorization"); got != "Bearer app-token" {
+				t.Errorf("membership Authorization = %q, want the installation token", got)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer proxy.Close()
+
+	cfg := &Config{
+		GitHubBaseURL:           "http://github.invalid",
+		GitHubProxy:             proxy.URL,
+		GitHubAppID:             1,
+		GitHubAppPrivateKey:     keyPath,
+		GitHubAppInstallationID: 42,
+		MaxTeamPages:            1,
+	}
+	client, err := newGitHubClient(cfg, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("newGitHubClient() error: %v", err)
+	}
+	if err := client.CheckOrgMembership(context.Background(), "user-token", "my-org", "octocat"); err != nil {
+		t.Fatalf("CheckOrgMembership() error: %v", err)
+	}
+
+	want := []string{
+		"POST github.invalid/app/installations/42/access_tokens",
+		"GET github.invalid/orgs/my-org/members/octocat",
+	}
+	if !slices.Equal(proxied, want) {
+		t.Errorf("proxied requests = %q, want %q", proxied, want)
+	}
+}
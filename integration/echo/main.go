@@ -6,19 +6,29 @@
 // It returns the received request headers, method, and path as JSON, allowing
 // tests to verify that Traefik's ForwardAuth middleware correctly forwards
 // authentication headers to the upstream service.
+//
+// A status query parameter (e.g. ?status=503) selects the response status
+// code so that tests can check how upstream errors propagate through the
+// auth chain.
 package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
 )
 
 // echoResponse is the JSON structure returned by the echo server.
 type echoResponse struct {
-	Headers map[string][]string `json:"headers"`
-	Method  string              `json:"method"`
-	Path    string              `json:"path"`
+	Headers     map[string][]string `json:"headers"`
+	Method      string              `json:"method"`
+	Path        string              `json:"path"`
+	RemoteAddr  string              `json:"remote_addr"`
+	RequestLine string              `json:"request_line"`
+	Trailers    map[string][]string `json:"trailers,omitempty"`
 }
 
 func main() {
@@ -31,14 +41,32 @@ func main() {
 	}
 }
 
-// handleEcho returns all request headers, method, and path as JSON.
+// handleEcho returns the request headers, method, path, remote address,
+// request line, and trailers as JSON.
 func handleEcho(w http.ResponseWriter, r *http.Request) {
+	// Trailers are only available once the body has been read.
+	io.Copy(io.Discard, r.Body)
+
 	resp := echoResponse{
-		Headers: r.Header,
-		Method:  r.Method,
-		Path:    r.URL.Path,
+		Headers:     r.Header,
+		Method:      r.Method,
+		Path:        r.URL.Path,
+		RemoteAddr:  r.RemoteAddr,
+		RequestLine: fmt.Sprintf("%s %s %s", r.Method, r.RequestURI, r.Proto),
+		Trailers:    r.Trailer,
+	}
+
+	status := http.StatusOK
+	if s := r.URL.Query().Get("status"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 200 || n > 599 {
+			http.Error(w, "invalid status: "+s, http.StatusBadRequest)
+			return
+		}
+		status = n
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(resp)
 }
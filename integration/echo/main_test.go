@@ -0,0 +1,52 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleEcho(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/some/path?status=418", nil)
+	req.Header.Set("X-Auth-User-Login", "testuser1")
+	rec := httptest.NewRecorder()
+	handleEcho(rec, req)
+
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("expected status %d, got %d", http.StatusTeapot, rec.Code)
+	}
+
+	var resp echoResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Path != "/some/path" || resp.Method != http.MethodGet {
+		t.Errorf("unexpected method or path: %+v", resp)
+	}
+	if want := "GET /some/path?status=418 HTTP/1.1"; resp.RequestLine != want {
+		t.Errorf("expected request line %q, got %q", want, resp.RequestLine)
+	}
+	if resp.RemoteAddr != req.RemoteAddr {
+		t.Errorf("expected remote addr %q, got %q", req.RemoteAddr, resp.RemoteAddr)
+	}
+	if got := resp.Headers["X-Auth-User-Login"]; len(got) != 1 || got[0] != "testuser1" {
+		t.Errorf("expected X-Auth-User-Login header to be echoed, got %v", got)
+	}
+}
+
+func TestHandleEcho_InvalidStatus(t *testing.T) {
+	for _, status := range []string{"abc", "99", "600"} {
+		req := httptest.NewRequest(http.MethodGet, "/?status="+status, nil)
+		rec := httptest.NewRecorder()
+		handleEcho(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status=%s: expected status %d, got %d", status, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
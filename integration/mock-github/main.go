@@ -5,6 +5,11 @@
 // Package main implements a mock GitHub API server for integration testing.
 // It provides fake implementations of the GitHub API endpoints used by the
 // token validator, allowing end-to-end testing without real GitHub credentials.
+//
+// The built-in fixtures can be replaced by setting MOCK_FIXTURES to the path
+// of a JSON file mapping Bearer tokens to user fixtures, for example:
+//
+//	{"my-token": {"login": "octocat", "id": 1, "is_org_member": true, "teams": ["backend"]}}
 package main
 
 import (
@@ -12,16 +17,58 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// Failure modes that make every request for a fixture's token fail.
+const (
+	// failureRateLimited responds 403 with X-RateLimit-Remaining: 0, as
+	// GitHub does when the primary rate limit is exhausted.
+	failureRateLimited = "rate_limited"
+
+	// failureTooManyRequests responds 429 with a Retry-After header.
+	failureTooManyRequests = "too_many_requests"
+
+	// failureServerError responds 500.
+	failureServerError = "server_error"
+)
+
+// Page sizes for GET /user/teams, matching GitHub's defaults.
+const (
+	defaultPerPage = 30
+	maxPerPage     = 100
+)
+
+// fixturesEnv names the environment variable holding the path of a JSON
+// fixtures file that replaces the built-in fixtures.
+const fixturesEnv = "MOCK_FIXTURES"
+
+// defaultTeamOrg is the organization login reported for fixture teams.
+const defaultTeamOrg = "test-org"
+
 // userFixture holds the test data for a single mock user.
 type userFixture struct {
-	Login       string
-	ID          int64
-	IsOrgMember bool
-	Teams       []string
-	IsClassic   bool
+	Login       string   `json:"login"`
+	ID          int64    `json:"id"`
+	IsOrgMember bool     `json:"is_org_member"`
+	Teams       []string `json:"teams"`
+	IsClassic   bool     `json:"is_classic"`
+
+	// TeamOrg overrides the organization login reported for Teams. It lets
+	// fixtures return the org with different casing than the validator's
+	// configured -org, as GitHub may.
+	TeamOrg string `json:"team_org"`
+
+	// OtherOrgTeams are teams reported in an unrelated organization. They
+	// must be filtered out by the validator.
+	OtherOrgTeams []string `json:"other_org_teams"`
+
+	// Failure, if set, is the failure mode returned for every request
+	// made with the fixture's token (e.g. failureRateLimited).
+	Failure string `json:"failure"`
 }
 
 // fixtures maps Bearer tokens to user data.
@@ -54,9 +101,57 @@ var fixtures = map[string]userFixture{
 		Teams:       []string{"platform-eng"},
 		IsClassic:   true,
 	},
+	"mixed-case-org-token": {
+		Login:         "MixedCaseUser",
+		ID:            4001,
+		IsOrgMember:   true,
+		Teams:         []string{"Platform-Eng", "sre"},
+		TeamOrg:       "Test-ORG",
+		OtherOrgTeams: []string{"outside-team"},
+	},
+	"rate-limited-token": {
+		Login:   "ratelimited",
+		ID:      5001,
+		Failure: failureRateLimited,
+	},
+	"too-many-requests-token": {
+		Login:   "toomanyrequests",
+		ID:      5002,
+		Failure: failureTooManyRequests,
+	},
+	"server-error-token": {
+		Login:   "servererror",
+		ID:      5003,
+		Failure: failureServerError,
+	},
+	// Lists more teams than fit on one page so that pagination is used.
+	"many-teams-token": {
+		Login:       "manyteams",
+		ID:          6001,
+		IsOrgMember: true,
+		Teams:       numberedTeams(150),
+	},
+}
+
+// numberedTeams returns n team slugs, team-001 through team-n.
+func numberedTeams(n int) []string {
+	teams := make([]string, n)
+	for i := range teams {
+		teams[i] = fmt.Sprintf("team-%03d", i+1)
+	}
+	return teams
 }
 
 func main() {
+	if path := os.Getenv(fixturesEnv); path != "" {
+		loaded, err := loadFixtures(path)
+		if err != nil {
+			log.Fatalf("loading fixtures: %v", err)
+		}
+		fixtures = loaded
+		log.Printf("loaded %d fixtures from %s", len(fixtures), path)
+	}
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /user", handleGetUser)
 	mux.HandleFunc("GET /user/teams", handleListUserTeams)
@@ -68,6 +163,20 @@ func main() {
 	}
 }
 
+// loadFixtures reads a JSON object mapping Bearer tokens to user fixtures
+// from path.
+func loadFixtures(path string) (map[string]userFixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var loaded map[string]userFixture
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return loaded, nil
+}
+
 // extractToken parses the Bearer token from the Authorization header.
 // Returns the token and true if valid, or empty string and false otherwise.
 func extractToken(r *http.Request) (string, bool) {
@@ -83,6 +192,29 @@ func extractToken(r *http.Request) (string, bool) {
 	return token, true
 }
 
+// writeFailure writes the response for the fixture's failure mode. It
+// returns false if the fixture has none.
+func writeFailure(w http.ResponseWriter, fixture userFixture) bool {
+	switch fixture.Failure {
+	case "":
+		return false
+	case failureRateLimited:
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message":"API rate limit exceeded"}`)
+	case failureTooManyRequests:
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"message":"You have exceeded a secondary rate limit"}`)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"message":"Server Error"}`)
+	}
+	return true
+}
+
 // handleGetUser implements GET /user.
 func handleGetUser(w http.ResponseWriter, r *http.Request) {
 	token, ok := extractToken(r)
@@ -99,6 +231,10 @@ func handleGetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if writeFailure(w, fixture) {
+		return
+	}
+
 	// Classic PATs include the X-OAuth-Scopes header.
 	if fixture.IsClassic {
 		w.Header().Set("X-OAuth-Scopes", "repo, user")
@@ -127,6 +263,10 @@ func handleCheckOrgMembership(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if writeFailure(w, fixture) {
+		return
+	}
+
 	username := r.PathValue("username")
 	if fixture.Login != username {
 		w.WriteHeader(http.StatusNotFound)
@@ -140,7 +280,9 @@ func handleCheckOrgMembership(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleListUserTeams implements GET /user/teams.
+// handleListUserTeams implements GET /user/teams. Like GitHub, it pages the
+// results according to the page and per_page query parameters and links to
+// the following page with a Link header.
 func handleListUserTeams(w http.ResponseWriter, r *http.Request) {
 	token, ok := extractToken(r)
 	if !ok {
@@ -156,6 +298,10 @@ func handleListUserTeams(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if writeFailure(w, fixture) {
+		return
+	}
+
 	type org struct {
 		Login string `json:"login"`
 	}
@@ -164,14 +310,48 @@ func handleListUserTeams(w http.ResponseWriter, r *http.Request) {
 		Organization org    `json:"organization"`
 	}
 
-	teams := make([]team, 0, len(fixture.Teams))
+	teamOrg := fixture.TeamOrg
+	if teamOrg == "" {
+		teamOrg = defaultTeamOrg
+	}
+
+	teams := make([]team, 0, len(fixture.Teams)+len(fixture.OtherOrgTeams))
 	for _, slug := range fixture.Teams {
 		teams = append(teams, team{
 			Slug:         slug,
-			Organization: org{Login: "test-org"},
+			Organization: org{Login: teamOrg},
+		})
+	}
+	for _, slug := range fixture.OtherOrgTeams {
+		teams = append(teams, team{
+			Slug:         slug,
+			Organization: org{Login: "other-org"},
 		})
 	}
 
+	page, perPage := pageParams(r)
+	start := min((page-1)*perPage, len(teams))
+	end := min(start+perPage, len(teams))
+	if end < len(teams) {
+		lastPage := (len(teams) + perPage - 1) / perPage
+		w.Header().Set("Link", fmt.Sprintf(`<%[1]s?page=%[2]d&per_page=%[3]d>; rel="next", <%[1]s?page=%[4]d&per_page=%[3]d>; rel="last"`,
+			"http://"+r.Host+r.URL.Path, page+1, perPage, lastPage))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(teams)
+	json.NewEncoder(w).Encode(teams[start:end])
+}
+
+// pageParams returns the 1-based page number and page size requested by r,
+// applying GitHub's defaults and limits.
+func pageParams(r *http.Request) (page, perPage int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err = strconv.Atoi(r.URL.Query().Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	return page, min(perPage, maxPerPage)
 }
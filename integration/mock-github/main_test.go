@@ -0,0 +1,154 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	content := `{
+		"file-token": {"login": "fileuser", "id": 5001, "is_org_member": true, "teams": ["ops"], "team_org": "Test-Org"}
+	}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadFixtures(path)
+	if err != nil {
+		t.Fatalf("loadFixtures() error: %v", err)
+	}
+	prev := fixtures
+	fixtures = loaded
+	t.Cleanup(func() { fixtures = prev })
+
+	get := func(handler http.HandlerFunc, target, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := get(handleGetUser, "/user", "file-token")
+	var user struct {
+		Login string `json:"login"`
+		ID    int64  `json:"id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&user); err != nil {
+		t.Fatalf("decoding user: %v", err)
+	}
+	if user.Login != "fileuser" || user.ID != 5001 {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	rec = get(handleListUserTeams, "/user/teams", "file-token")
+	var teams []struct {
+		Slug         string `json:"slug"`
+		Organization struct {
+			Login string `json:"login"`
+		} `json:"organization"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&teams); err != nil {
+		t.Fatalf("decoding teams: %v", err)
+	}
+	if len(teams) != 1 || teams[0].Slug != "ops" || teams[0].Organization.Login != "Test-Org" {
+		t.Errorf("unexpected teams %+v", teams)
+	}
+
+	// Built-in fixtures are replaced, not merged.
+	if rec := get(handleGetUser, "/user", "valid-test-token-1"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected built-in token to be unknown, got status %d", rec.Code)
+	}
+}
+
+func TestFailureModes(t *testing.T) {
+	tests := []struct {
+		token      string
+		wantStatus int
+		wantHeader string
+	}{
+		{"rate-limited-token", http.StatusForbidden, "X-Ratelimit-Remaining"},
+		{"too-many-requests-token", http.StatusTooManyRequests, "Retry-After"},
+		{"server-error-token", http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			for _, handler := range []http.HandlerFunc{handleGetUser, handleListUserTeams} {
+				req := httptest.NewRequest(http.MethodGet, "/user", nil)
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+				rec := httptest.NewRecorder()
+				handler(rec, req)
+
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+				}
+				if tt.wantHeader != "" && rec.Header().Get(tt.wantHeader) == "" {
+					t.Errorf("expected %s header to be set", tt.wantHeader)
+				}
+			}
+		})
+	}
+}
+
+func TestListUserTeams_Pagination(t *testing.T) {
+	var got []string
+	target := "/user/teams?per_page=100"
+	for pages := 0; target != ""; pages++ {
+		if pages == 3 {
+			t.Fatal("expected pagination to end")
+		}
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer many-teams-token")
+		rec := httptest.NewRecorder()
+		handleListUserTeams(rec, req)
+
+		var teams []struct {
+			Slug string `json:"slug"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&teams); err != nil {
+			t.Fatalf("decoding teams: %v", err)
+		}
+		for _, team := range teams {
+			got = append(got, team.Slug)
+		}
+
+		target = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			next, _, ok := strings.Cut(strings.TrimPrefix(link, "<"), ">; rel=\"next\"")
+			if !ok {
+				t.Fatalf("expected a next link, got %q", link)
+			}
+			target = next
+		}
+	}
+
+	if want := numberedTeams(150); !slices.Equal(got, want) {
+		t.Fatalf("expected all 150 teams in order, got %d: %v", len(got), got)
+	}
+}
+
+func TestLoadFixtures_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	if err := os.WriteFile(path, []byte(`["not", "an", "object"]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadFixtures(path); err == nil {
+		t.Error("expected an error for a non-object fixtures file")
+	}
+	if _, err := loadFixtures(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing fixtures file")
+	}
+}
@@ -6,12 +6,18 @@
 package cache
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	mathrand "math/rand/v2"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 
 	"github.com/andrewkroh/traefik-github-auth/internal/validator"
@@ -27,40 +33,166 @@ type Entry struct {
 
 	// ExpiresAt is the time at which this entry should be considered expired.
 	ExpiresAt time.Time
+
+	// CreatedAt is when the entry was first stored. It is preserved when a
+	// live entry is overwritten so that the maximum lifetime is enforced
+	// across refreshes.
+	CreatedAt time.Time
 }
 
 // Cache is an in-memory cache for token validation results.
 type Cache struct {
-	ttl     time.Duration
-	maxSize int
+	ttl         time.Duration
+	maxSize     int
+	maxLifetime time.Duration
+	jitter      float64
+	name        string
+	noCleanup   bool
+
+	// now returns the current time. It is time.Now unless overridden with
+	// WithClock.
+	now func() time.Time
+
+	// salt keys the hash of tokens so that cache keys cannot be linked
+	// to tokens without it.
+	salt []byte
+
+	// partition is mixed into every key so that caches sharing a salt but
+	// validating against different organizations never share entries.
+	partition string
 
 	mu      sync.RWMutex
 	entries map[string]Entry
 
 	stop chan struct{}
 
-	hits       metric.Int64Counter
-	misses     metric.Int64Counter
-	evictions  metric.Int64Counter
-	entryGauge metric.Int64UpDownCounter
+	hits      metric.Int64Counter
+	misses    metric.Int64Counter
+	evictions metric.Int64Counter
+
+	// attrs holds the attributes recorded with every measurement.
+	attrs metric.MeasurementOption
+
+	// entriesReg is the registration of the entry count, max size, and
+	// utilization gauge callback.
+	// It is unregistered by Stop.
+	entriesReg metric.Registration
+
+	// hitCount and missCount mirror the hits and misses counters for
+	// Stats.
+	hitCount  atomic.Int64
+	missCount atomic.Int64
+}
+
+// Stats holds cumulative cache counters.
+type Stats struct {
+	Hits   int64
+	Misses int64
+}
+
+// Option configures a Cache.
+type Option func(*Cache)
+
+// WithMaxEntryLifetime caps how long an entry may live, measured from when
+// it was first stored, regardless of how often it is refreshed by Set.
+// Once the lifetime has elapsed the entry expires and the token must be
+// fully re-validated. A value of 0 disables the cap.
+func WithMaxEntryLifetime(d time.Duration) Option {
+	return func(c *Cache) {
+		c.maxLifetime = d
+	}
+}
+
+// WithTTLJitter randomizes the lifetime of each successful entry by up to
+// ±fraction of its TTL (e.g. 0.1 for ±10%) so that entries stored together,
+// such as during a startup stampede, do not all expire at the same moment.
+// Negative entries are not jittered so that their deliberately short TTLs
+// are never extended. The default of 0 disables jitter. Values outside
+// [0, 1) are clamped.
+func WithTTLJitter(fraction float64) Option {
+	return func(c *Cache) {
+		c.jitter = min(max(fraction, 0), 0.99)
+	}
+}
+
+// WithName records the cache's metrics with a cache.name attribute set to
+// name so that several caches in one process can be told apart. Unnamed
+// caches record no attributes.
+func WithName(name string) Option {
+	return func(c *Cache) {
+		c.name = name
+	}
+}
+
+// WithoutCleanup disables the background goroutine that removes expired
+// entries. Expired entries are still never returned by Get, but they remain
+// in memory until RemoveExpired is called or they are evicted. This suits
+// short-lived processes and tests that would otherwise need to call Stop.
+func WithoutCleanup() Option {
+	return func(c *Cache) {
+		c.noCleanup = true
+	}
+}
+
+// WithClock overrides the function used to read the current time, which
+// defaults to time.Now. It allows tests to control expiry deterministically.
+// The background cleanup interval is still measured in real time.
+func WithClock(now func() time.Time) Option {
+	return func(c *Cache) {
+		c.now = now
+	}
+}
+
+// WithKeySalt sets the salt used to derive cache keys from tokens. By
+// default a random salt is generated for each Cache, which makes keys
+// unlinkable across restarts; set a fixed salt only when keys must match
+// across processes.
+func WithKeySalt(salt []byte) Option {
+	return func(c *Cache) {
+		c.salt = salt
+	}
+}
+
+// WithKeyPartition mixes partition, typically the configured organization
+// list, into every cache key. A token's result depends on the organizations
+// it was validated against, so caches whose keys must match across
+// processes (see WithKeySalt) must not serve a result computed for a
+// different organization configuration.
+func WithKeyPartition(partition string) Option {
+	return func(c *Cache) {
+		c.partition = partition
+	}
+}
+
+// hashToken returns the hex-encoded HMAC-SHA256 of the raw token keyed by
+// salt. Without the salt, a key cannot be used to confirm that a given
+// token is cached. The raw token is never stored.
+func hashToken(salt []byte, token string) string {
+	m := hmac.New(sha256.New, salt)
+	m.Write([]byte(token))
+	return hex.EncodeToString(m.Sum(nil))
 }
 
-// hashToken returns the hex-encoded SHA-256 hash of the raw token.
-// The raw token is never stored.
-func hashToken(token string) string {
-	h := sha256.Sum256([]byte(token))
-	return hex.EncodeToString(h[:])
+// key returns the cache key for token within the cache's partition.
+func (c *Cache) key(token string) string {
+	if c.partition == "" {
+		return hashToken(c.salt, token)
+	}
+	// The separator cannot appear in an organization name, so distinct
+	// partition and token pairs cannot produce the same input.
+	return hashToken(c.salt, c.partition+"\x00"+token)
 }
 
 // New creates a new Cache with the specified TTL and maximum number of entries.
-// A background goroutine is started to periodically remove expired entries.
-// Call Stop to terminate the background goroutine.
+// Unless WithoutCleanup is given, a background goroutine is started to
+// periodically remove expired entries. Call Stop to terminate the background
+// goroutine.
 //
 // If ttl is 0, the cache is effectively disabled: Get always returns false
 // and Set is a no-op. The maxSize parameter limits the number of entries;
 // when the cache is full, the entry closest to expiry is evicted.
 // A maxSize of 0 or less means no limit (not recommended for production).
-func New(ttl time.Duration, maxSize int) *Cache {
+func New(ttl time.Duration, maxSize int, opts ...Option) *Cache {
 	meter := otel.Meter("github_auth.cache")
 
 	hits, _ := meter.Int64Counter("github_auth.cache.hits",
@@ -72,22 +204,53 @@ func New(ttl time.Duration, maxSize int) *Cache {
 	evictions, _ := meter.Int64Counter("github_auth.cache.evictions",
 		metric.WithDescription("Number of cache evictions"),
 	)
-	entryGauge, _ := meter.Int64UpDownCounter("github_auth.cache.entries",
-		metric.WithDescription("Current number of cache entries"),
-	)
 
 	c := &Cache{
-		ttl:        ttl,
-		maxSize:    maxSize,
-		entries:    make(map[string]Entry),
-		stop:       make(chan struct{}),
-		hits:       hits,
-		misses:     misses,
-		evictions:  evictions,
-		entryGauge: entryGauge,
+		ttl:       ttl,
+		maxSize:   maxSize,
+		now:       time.Now,
+		entries:   make(map[string]Entry),
+		stop:      make(chan struct{}),
+		hits:      hits,
+		misses:    misses,
+		evictions: evictions,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.salt == nil {
+		c.salt = make([]byte, 32)
+		rand.Read(c.salt)
+	}
+	var attrs []attribute.KeyValue
+	if c.name != "" {
+		attrs = append(attrs, attribute.String("cache.name", c.name))
+	}
+	c.attrs = metric.WithAttributeSet(attribute.NewSet(attrs...))
+
+	// The entry count is observed at collection time so that it cannot
+	// drift from the contents of the map.
+	entries, _ := meter.Int64ObservableGauge("github_auth.cache.entries",
+		metric.WithDescription("Current number of cache entries"),
+	)
+	maxEntries, _ := meter.Int64ObservableGauge("github_auth.cache.max_size",
+		metric.WithDescription("Configured maximum number of cache entries"),
+	)
+	utilization, _ := meter.Float64ObservableGauge("github_auth.cache.utilization",
+		metric.WithDescription("Fraction of the maximum number of cache entries in use"),
+	)
+	c.entriesReg, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		n := c.Len()
+		o.ObserveInt64(entries, int64(n), c.attrs)
+		// Unbounded caches have no meaningful capacity to report.
+		if c.maxSize > 0 {
+			o.ObserveInt64(maxEntries, int64(c.maxSize), c.attrs)
+			o.ObserveFloat64(utilization, float64(n)/float64(c.maxSize), c.attrs)
+		}
+		return nil
+	}, entries, maxEntries, utilization)
 
-	if ttl > 0 {
+	if ttl > 0 && !c.noCleanup {
 		go c.cleanupLoop()
 	}
 
@@ -113,21 +276,22 @@ func (c *Cache) cleanupLoop() {
 		case <-c.stop:
 			return
 		case <-ticker.C:
-			c.removeExpired()
+			c.RemoveExpired()
 		}
 	}
 }
 
-// removeExpired removes all entries that have passed their expiration time.
-func (c *Cache) removeExpired() {
-	now := time.Now()
+// RemoveExpired removes all entries that have passed their expiration time.
+// It is called periodically by the background cleanup goroutine and may be
+// called directly when the cache was created with WithoutCleanup.
+func (c *Cache) RemoveExpired() {
+	now := c.now()
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	for key, entry := range c.entries {
 		if now.After(entry.ExpiresAt) {
 			delete(c.entries, key)
-			c.entryGauge.Add(nil, -1)
 		}
 	}
 }
@@ -139,63 +303,119 @@ func (c *Cache) removeExpired() {
 // If the cache was created with a zero TTL, Get always returns a miss.
 func (c *Cache) Get(token string) (validator.ValidationResult, error, bool) {
 	if c.ttl == 0 {
-		c.misses.Add(nil, 1)
+		c.recordMiss()
 		return validator.ValidationResult{}, nil, false
 	}
 
-	key := hashToken(token)
+	key := c.key(token)
 
 	c.mu.RLock()
 	entry, ok := c.entries[key]
 	c.mu.RUnlock()
 
 	if !ok {
-		c.misses.Add(nil, 1)
+		c.recordMiss()
 		return validator.ValidationResult{}, nil, false
 	}
 
-	if time.Now().After(entry.ExpiresAt) {
-		c.misses.Add(nil, 1)
+	if c.now().After(entry.ExpiresAt) {
+		c.recordMiss()
 		return validator.ValidationResult{}, nil, false
 	}
 
-	c.hits.Add(nil, 1)
+	c.recordHit()
 	return entry.Result, entry.Err, true
 }
 
+// recordHit counts a cache hit.
+func (c *Cache) recordHit() {
+	c.hits.Add(nil, 1, c.attrs)
+	c.hitCount.Add(1)
+}
+
+// recordMiss counts a cache miss.
+func (c *Cache) recordMiss() {
+	c.misses.Add(nil, 1, c.attrs)
+	c.missCount.Add(1)
+}
+
+// Stats returns the cumulative number of hits and misses.
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Hits:   c.hitCount.Load(),
+		Misses: c.missCount.Load(),
+	}
+}
+
 // Set stores a validation result for the given token.
 // Pass a non-nil err to cache a negative result (e.g., unauthorized).
-// The entry expires after the cache's TTL has elapsed.
+// The entry expires after the cache's TTL has elapsed, or earlier if the
+// maximum entry lifetime would be exceeded or the result's TokenExpiresAt
+// is sooner.
 //
 // If the cache is full (maxSize > 0 and len(entries) >= maxSize),
 // the entry closest to expiry is evicted before inserting the new entry.
 //
 // If the cache was created with a zero TTL, Set is a no-op.
 func (c *Cache) Set(token string, result validator.ValidationResult, err error) {
-	if c.ttl == 0 {
+	c.SetWithTTL(token, result, err, c.ttl)
+}
+
+// SetWithTTL is like Set but expires the entry after ttl instead of the
+// cache's TTL. It is used to briefly cache transient errors. A ttl of 0 or
+// less is a no-op, as is any call when the cache was created with a zero
+// TTL.
+func (c *Cache) SetWithTTL(token string, result validator.ValidationResult, err error, ttl time.Duration) {
+	if c.ttl == 0 || ttl <= 0 {
 		return
 	}
 
-	key := hashToken(token)
+	key := c.key(token)
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	_, exists := c.entries[key]
+	prev, exists := c.entries[key]
 
 	// Evict the entry closest to expiry if we're at capacity and this is a new key.
 	if !exists && c.maxSize > 0 && len(c.entries) >= c.maxSize {
 		c.evictOldest()
 	}
 
+	now := c.now()
+	createdAt := now
+	if exists && now.Before(prev.ExpiresAt) {
+		createdAt = prev.CreatedAt
+	}
+	if err == nil {
+		ttl = c.jitterTTL(ttl)
+	}
+	expiresAt := now.Add(ttl)
+	if c.maxLifetime > 0 {
+		if deadline := createdAt.Add(c.maxLifetime); deadline.Before(expiresAt) {
+			expiresAt = deadline
+		}
+	}
+	// Never serve a result after the token itself has expired.
+	if !result.TokenExpiresAt.IsZero() && result.TokenExpiresAt.Before(expiresAt) {
+		expiresAt = result.TokenExpiresAt
+	}
+
 	c.entries[key] = Entry{
 		Result:    result,
 		Err:       err,
-		ExpiresAt: time.Now().Add(c.ttl),
+		ExpiresAt: expiresAt,
+		CreatedAt: createdAt,
 	}
-	if !exists {
-		c.entryGauge.Add(nil, 1)
+}
+
+// jitterTTL returns ttl adjusted by a uniformly random amount within
+// ±c.jitter of ttl.
+func (c *Cache) jitterTTL(ttl time.Duration) time.Duration {
+	if c.jitter == 0 {
+		return ttl
 	}
+	return ttl + time.Duration((mathrand.Float64()*2-1)*c.jitter*float64(ttl))
 }
 
 // evictOldest removes the entry with the earliest ExpiresAt time.
@@ -215,32 +435,34 @@ func (c *Cache) evictOldest() {
 
 	if !first {
 		delete(c.entries, oldestKey)
-		c.entryGauge.Add(nil, -1)
-		c.evictions.Add(nil, 1)
+		c.evictions.Add(nil, 1, c.attrs)
 	}
 }
 
 // Delete removes a cached entry for the given token.
 // This is useful for cache invalidation on errors.
 func (c *Cache) Delete(token string) {
-	key := hashToken(token)
+	key := c.key(token)
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if _, exists := c.entries[key]; exists {
 		delete(c.entries, key)
-		c.entryGauge.Add(nil, -1)
 	}
 }
 
-// Stop terminates the background cleanup goroutine.
+// Stop terminates the background cleanup goroutine and stops reporting
+// the entry count gauge.
 func (c *Cache) Stop() {
 	select {
 	case <-c.stop:
 		// Already stopped.
 	default:
 		close(c.stop)
+		if c.entriesReg != nil {
+			c.entriesReg.Unregister()
+		}
 	}
 }
 
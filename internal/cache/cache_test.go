@@ -5,17 +5,46 @@
 package cache
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
 	"github.com/andrewkroh/traefik-github-auth/internal/validator"
 )
 
 // Compile-time check that *Cache satisfies validator.Cache.
 var _ validator.Cache = (*Cache)(nil)
 
+// fakeClock is a manually advanced clock for use with WithClock.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
 func TestCache_ImplementsInterface(t *testing.T) {
 	// This is a compile-time check enforced by the var declaration above.
 	// If *Cache does not satisfy validator.Cache, this file will not compile.
@@ -101,8 +130,9 @@ func TestCache_NegativeEntry(t *testing.T) {
 }
 
 func TestCache_Expiry(t *testing.T) {
-	ttl := 50 * time.Millisecond
-	c := New(ttl, 1000)
+	clock := newFakeClock()
+	ttl := time.Minute
+	c := New(ttl, 1000, WithClock(clock.Now))
 	defer c.Stop()
 
 	c.Set("test-token-1", validator.ValidationResult{Login: "testuser"}, nil)
@@ -112,14 +142,61 @@ func TestCache_Expiry(t *testing.T) {
 		t.Fatal("expected cache hit immediately after Set")
 	}
 
-	// Wait for expiry.
-	time.Sleep(ttl + 20*time.Millisecond)
+	// Still a hit at the TTL boundary.
+	clock.Advance(ttl)
+	if _, _, ok := c.Get("test-token-1"); !ok {
+		t.Fatal("expected cache hit at the TTL")
+	}
 
+	clock.Advance(time.Nanosecond)
 	if _, _, ok := c.Get("test-token-1"); ok {
 		t.Fatal("expected cache miss after TTL expiry")
 	}
 }
 
+func TestCache_TTLJitter(t *testing.T) {
+	ttl := time.Hour
+	tests := []struct {
+		name   string
+		jitter float64
+	}{
+		{name: "disabled", jitter: 0},
+		{name: "ten percent", jitter: 0.1},
+		{name: "half", jitter: 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			c := New(ttl, 0, WithTTLJitter(tt.jitter), WithoutCleanup(), WithClock(clock.Now))
+
+			for i := range 200 {
+				c.Set(fmt.Sprintf("token-%d", i), validator.ValidationResult{}, nil)
+			}
+
+			band := time.Duration(tt.jitter * float64(ttl))
+			lo, hi := clock.Now().Add(ttl-band), clock.Now().Add(ttl+band)
+			distinct := map[time.Time]struct{}{}
+			for _, e := range c.entries {
+				if e.ExpiresAt.Before(lo) || e.ExpiresAt.After(hi) {
+					t.Fatalf("ExpiresAt %v outside [%v, %v]", e.ExpiresAt, lo, hi)
+				}
+				distinct[e.ExpiresAt] = struct{}{}
+			}
+			// Entries set at the same instant expire at different times
+			// only when jitter is enabled.
+			if spread := len(distinct) > 1; spread != (tt.jitter > 0) {
+				t.Errorf("got %d distinct expiry times with jitter %v", len(distinct), tt.jitter)
+			}
+
+			// Negative entries always expire after exactly their TTL.
+			c.SetWithTTL("negative", validator.ValidationResult{}, errors.New("forbidden"), time.Minute)
+			if got, want := c.entries[c.key("negative")].ExpiresAt, clock.Now().Add(time.Minute); !got.Equal(want) {
+				t.Errorf("negative entry ExpiresAt = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
 func TestCache_Delete(t *testing.T) {
 	c := New(time.Minute, 1000)
 	defer c.Stop()
@@ -191,8 +268,9 @@ func TestCache_ConcurrentAccess(t *testing.T) {
 }
 
 func TestCache_Cleanup(t *testing.T) {
+	clock := newFakeClock()
 	ttl := 50 * time.Millisecond
-	c := New(ttl, 1000)
+	c := New(ttl, 1000, WithClock(clock.Now))
 	defer c.Stop()
 
 	c.Set("test-token-1", validator.ValidationResult{Login: "user1"}, nil)
@@ -203,12 +281,37 @@ func TestCache_Cleanup(t *testing.T) {
 		t.Fatalf("expected 3 entries, got %d", c.Len())
 	}
 
-	// Wait long enough for entries to expire and cleanup to run.
-	// Cleanup interval is TTL/2 = 25ms. We wait for TTL + a few cleanup cycles.
-	time.Sleep(ttl + 100*time.Millisecond)
+	// Expire the entries and wait for the cleanup loop, which runs every
+	// TTL/2 = 25ms of real time, to remove them.
+	clock.Advance(ttl + time.Nanosecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 0 entries after cleanup, got %d", c.Len())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCache_WithoutCleanup(t *testing.T) {
+	clock := newFakeClock()
+	before := runtime.NumGoroutine()
+	ttl := time.Minute
+	c := New(ttl, 1000, WithoutCleanup(), WithClock(clock.Now))
+	if n := runtime.NumGoroutine(); n != before {
+		t.Fatalf("expected no goroutine to be started, goroutines went from %d to %d", before, n)
+	}
+
+	c.Set("test-token-1", validator.ValidationResult{Login: "user1"}, nil)
+	c.Set("test-token-2", validator.ValidationResult{Login: "user2"}, nil)
+	clock.Advance(ttl + time.Nanosecond)
 
+	if n := c.Len(); n != 2 {
+		t.Fatalf("expected expired entries to remain until cleanup, got %d", n)
+	}
+	c.RemoveExpired()
 	if n := c.Len(); n != 0 {
-		t.Fatalf("expected 0 entries after cleanup, got %d", n)
+		t.Fatalf("expected 0 entries after RemoveExpired, got %d", n)
 	}
 }
 
@@ -351,9 +454,10 @@ func TestCache_StopIdempotent(t *testing.T) {
 
 func TestCache_HashToken(t *testing.T) {
 	// Verify that hashToken produces consistent, distinct results.
-	h1 := hashToken("test-token-1")
-	h2 := hashToken("test-token-2")
-	h1Again := hashToken("test-token-1")
+	salt := []byte("salt")
+	h1 := hashToken(salt, "test-token-1")
+	h2 := hashToken(salt, "test-token-2")
+	h1Again := hashToken(salt, "test-token-1")
 
 	if h1 != h1Again {
 		t.Fatal("hashToken is not deterministic")
@@ -367,13 +471,77 @@ func TestCache_HashToken(t *testing.T) {
 	}
 }
 
+func TestCache_KeySalt(t *testing.T) {
+	if hashToken([]byte("salt-1"), "token") == hashToken([]byte("salt-2"), "token") {
+		t.Fatal("expected different salts to produce different keys")
+	}
+
+	// Each cache generates its own salt unless one is configured.
+	c1 := New(time.Minute, 10)
+	defer c1.Stop()
+	c2 := New(time.Minute, 10)
+	defer c2.Stop()
+	if c1.key("token") == c2.key("token") {
+		t.Error("expected caches with generated salts to use different keys")
+	}
+
+	c3 := New(time.Minute, 10, WithKeySalt([]byte("shared")))
+	defer c3.Stop()
+	c4 := New(time.Minute, 10, WithKeySalt([]byte("shared")))
+	defer c4.Stop()
+	if c3.key("token") != c4.key("token") {
+		t.Error("expected caches with the same salt to use the same keys")
+	}
+
+	// Entries are still found under a generated salt.
+	c1.Set("token", validator.ValidationResult{Login: "a"}, nil)
+	if result, _, ok := c1.Get("token"); !ok || result.Login != "a" {
+		t.Fatalf("expected cache hit, got ok=%v result=%+v", ok, result)
+	}
+}
+
+func TestCache_KeyPartition(t *testing.T) {
+	salt := WithKeySalt([]byte("shared"))
+	tests := []struct {
+		name     string
+		a, b     string
+		wantSame bool
+	}{
+		{name: "unpartitioned", a: "", b: "", wantSame: true},
+		{name: "same orgs", a: "org-a,org-b", b: "org-a,org-b", wantSame: true},
+		{name: "different orgs", a: "org-a", b: "org-b", wantSame: false},
+		{name: "partitioned and unpartitioned", a: "org-a", b: "", wantSame: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(time.Minute, 10, salt, WithKeyPartition(tt.a))
+			defer a.Stop()
+			b := New(time.Minute, 10, salt, WithKeyPartition(tt.b))
+			defer b.Stop()
+
+			if same := a.key("token") == b.key("token"); same != tt.wantSame {
+				t.Errorf("keys equal = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+
+	// Entries are found under a partitioned key.
+	c := New(time.Minute, 10, WithKeyPartition("org-a"))
+	defer c.Stop()
+	c.Set("token", validator.ValidationResult{Login: "a", Org: "org-a"}, nil)
+	if result, _, ok := c.Get("token"); !ok || result.Org != "org-a" {
+		t.Fatalf("expected cache hit, got ok=%v result=%+v", ok, result)
+	}
+}
+
 func TestCache_MaxSize_EvictsOldest(t *testing.T) {
 	// Create a cache with maxSize=2.
-	c := New(time.Minute, 2)
+	clock := newFakeClock()
+	c := New(time.Minute, 2, WithClock(clock.Now))
 	defer c.Stop()
 
 	c.Set("token-a", validator.ValidationResult{Login: "userA"}, nil)
-	time.Sleep(time.Millisecond) // Ensure distinct expiry times.
+	clock.Advance(time.Millisecond) // Ensure distinct expiry times.
 	c.Set("token-b", validator.ValidationResult{Login: "userB"}, nil)
 
 	if c.Len() != 2 {
@@ -381,7 +549,7 @@ func TestCache_MaxSize_EvictsOldest(t *testing.T) {
 	}
 
 	// Adding a third entry should evict token-a (earliest expiry).
-	time.Sleep(time.Millisecond)
+	clock.Advance(time.Millisecond)
 	c.Set("token-c", validator.ValidationResult{Login: "userC"}, nil)
 
 	if c.Len() != 2 {
@@ -452,3 +620,246 @@ func TestCache_MaxSize_One(t *testing.T) {
 		t.Fatal("expected token-b to still be cached")
 	}
 }
+
+func TestCache_MaxEntryLifetime(t *testing.T) {
+	clock := newFakeClock()
+	ttl := 4 * time.Minute
+	c := New(ttl, 1000, WithMaxEntryLifetime(6*time.Minute), WithClock(clock.Now))
+	defer c.Stop()
+
+	result := validator.ValidationResult{Login: "lifetime-user", ID: 9}
+	c.Set("token-lifetime", result, nil)
+
+	// Refresh the entry before it expires. Without the lifetime cap each
+	// refresh would extend the entry by another TTL.
+	clock.Advance(3 * time.Minute)
+	c.Set("token-lifetime", result, nil)
+	clock.Advance(2 * time.Minute)
+	c.Set("token-lifetime", result, nil)
+
+	if _, _, ok := c.Get("token-lifetime"); !ok {
+		t.Fatal("expected entry to still be present before its maximum lifetime")
+	}
+
+	clock.Advance(2 * time.Minute)
+
+	if _, _, ok := c.Get("token-lifetime"); ok {
+		t.Fatal("expected entry to be expired after its maximum lifetime despite refreshes")
+	}
+
+	// Once expired, storing the token starts a new lifetime.
+	c.Set("token-lifetime", result, nil)
+	if _, _, ok := c.Get("token-lifetime"); !ok {
+		t.Fatal("expected re-validated entry to be cached")
+	}
+}
+
+func TestCache_TokenExpiration(t *testing.T) {
+	clock := newFakeClock()
+	c := New(time.Hour, 1000, WithClock(clock.Now))
+	defer c.Stop()
+
+	soon := clock.Now().Add(time.Minute)
+	c.Set("token-expiring", validator.ValidationResult{Login: "a", TokenExpiresAt: soon}, nil)
+	if got := c.entries[c.key("token-expiring")].ExpiresAt; !got.Equal(soon) {
+		t.Errorf("expected entry to expire with the token at %v, got %v", soon, got)
+	}
+
+	// A token that outlives the TTL does not extend the entry.
+	later := clock.Now().Add(24 * time.Hour)
+	c.Set("token-long-lived", validator.ValidationResult{Login: "b", TokenExpiresAt: later}, nil)
+	if got, want := c.entries[c.key("token-long-lived")].ExpiresAt, clock.Now().Add(time.Hour); !got.Equal(want) {
+		t.Errorf("expected entry to expire after the TTL at %v, got %v", want, got)
+	}
+
+	// A token that has already expired is never served.
+	c.Set("token-expired", validator.ValidationResult{Login: "c", TokenExpiresAt: clock.Now().Add(-time.Second)}, nil)
+	if _, _, ok := c.Get("token-expired"); ok {
+		t.Error("expected a result for an expired token not to be served")
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	c := New(time.Minute, 1000)
+	defer c.Stop()
+
+	c.Get("token-a")
+	c.Set("token-a", validator.ValidationResult{Login: "octocat"}, nil)
+	c.Get("token-a")
+	c.Get("token-a")
+	c.Get("token-b")
+
+	want := Stats{Hits: 2, Misses: 2}
+	if got := c.Stats(); got != want {
+		t.Fatalf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCache_SetWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := New(time.Minute, 1000, WithClock(clock.Now))
+	defer c.Stop()
+
+	transient := errors.New("github: executing request: connection refused")
+	c.SetWithTTL("token-a", validator.ValidationResult{}, transient, 20*time.Millisecond)
+
+	_, err, ok := c.Get("token-a")
+	if !ok || !errors.Is(err, transient) {
+		t.Fatalf("expected a negative hit with the transient error, got ok=%v err=%v", ok, err)
+	}
+
+	clock.Advance(30 * time.Millisecond)
+	if _, _, ok := c.Get("token-a"); ok {
+		t.Fatal("expected the short-lived entry to have expired")
+	}
+
+	// A non-positive TTL is a no-op.
+	c.SetWithTTL("token-b", validator.ValidationResult{}, transient, 0)
+	if _, _, ok := c.Get("token-b"); ok {
+		t.Fatal("expected no entry for a zero TTL")
+	}
+}
+
+func TestCache_EntriesGauge(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	defer otel.SetMeterProvider(prev)
+
+	c := New(time.Minute, 2)
+	defer c.Stop()
+
+	c.Set("token-1", validator.ValidationResult{Login: "a"}, nil)
+	c.Set("token-1", validator.ValidationResult{Login: "a"}, nil) // Overwrite.
+	c.Set("token-2", validator.ValidationResult{Login: "b"}, nil)
+	c.Set("token-3", validator.ValidationResult{Login: "c"}, nil) // Evicts one.
+	c.Delete("token-3")
+	c.Delete("token-3") // Already deleted.
+
+	collect := func() int64 {
+		t.Helper()
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Fatalf("failed to collect metrics: %v", err)
+		}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != "github_auth.cache.entries" {
+					continue
+				}
+				gauge, ok := m.Data.(metricdata.Gauge[int64])
+				if !ok || len(gauge.DataPoints) != 1 {
+					t.Fatalf("unexpected gauge data %+v", m.Data)
+				}
+				return gauge.DataPoints[0].Value
+			}
+		}
+		t.Fatal("expected a github_auth.cache.entries metric")
+		return 0
+	}
+
+	if got, want := collect(), int64(c.Len()); got != want {
+		t.Fatalf("github_auth.cache.entries = %d, want Len() = %d", got, want)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("expected 1 entry, got %d", c.Len())
+	}
+}
+
+func TestCache_UtilizationGauge(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	defer otel.SetMeterProvider(prev)
+
+	c := New(time.Minute, 4)
+	defer c.Stop()
+
+	c.Set("token-1", validator.ValidationResult{Login: "a"}, nil)
+
+	collect := func() (maxSize int64, utilization float64) {
+		t.Helper()
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Fatalf("failed to collect metrics: %v", err)
+		}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				switch m.Name {
+				case "github_auth.cache.max_size":
+					maxSize = m.Data.(metricdata.Gauge[int64]).DataPoints[0].Value
+				case "github_auth.cache.utilization":
+					utilization = m.Data.(metricdata.Gauge[float64]).DataPoints[0].Value
+				}
+			}
+		}
+		return maxSize, utilization
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  float64
+	}{
+		{name: "one entry", want: 0.25},
+		{name: "two entries", token: "token-2", want: 0.5},
+		{name: "three entries", token: "token-3", want: 0.75},
+	}
+	for _, tt := range tests {
+		if tt.token != "" {
+			c.Set(tt.token, validator.ValidationResult{Login: tt.token}, nil)
+		}
+		maxSize, utilization := collect()
+		if maxSize != 4 {
+			t.Errorf("%s: github_auth.cache.max_size = %d, want 4", tt.name, maxSize)
+		}
+		if utilization != tt.want {
+			t.Errorf("%s: github_auth.cache.utilization = %v, want %v", tt.name, utilization, tt.want)
+		}
+	}
+}
+
+func TestCache_WithName(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	defer otel.SetMeterProvider(prev)
+
+	tokens := New(time.Minute, 10)
+	defer tokens.Stop()
+	identities := New(time.Minute, 10, WithName("identity"))
+	defer identities.Stop()
+
+	tokens.Set("token-1", validator.ValidationResult{Login: "a"}, nil)
+	identities.Set("token-1", validator.ValidationResult{Login: "a"}, nil)
+	identities.Set("token-2", validator.ValidationResult{Login: "b"}, nil)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+	got := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "github_auth.cache.entries" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+				name, _ := dp.Attributes.Value("cache.name")
+				got[name.AsString()] = dp.Value
+			}
+		}
+	}
+	if len(got) != 2 || got[""] != 1 || got["identity"] != 2 {
+		t.Fatalf("expected entries {\"\": 1, identity: 2} by cache.name, got %v", got)
+	}
+}
@@ -0,0 +1,95 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package denylist provides a reloadable set of denied token hashes, used
+// to block known-bad tokens without waiting for GitHub to revoke them.
+package denylist
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// List is a set of hex-encoded SHA-256 token hashes loaded from a file.
+// The file contains one hash per line. Blank lines and lines starting with
+// "#" are ignored. Raw tokens never appear in the file.
+type List struct {
+	path string
+
+	mu     sync.RWMutex
+	hashes map[string]struct{}
+}
+
+// Load reads the denylist file at path.
+func Load(path string) (*List, error) {
+	l := &List{path: path}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload re-reads the denylist file. On error the previous contents are
+// kept.
+func (l *List) Reload() error {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return fmt.Errorf("reading token denylist: %w", err)
+	}
+	hashes, err := parse(data)
+	if err != nil {
+		return fmt.Errorf("parsing token denylist %s: %w", l.path, err)
+	}
+
+	l.mu.Lock()
+	l.hashes = hashes
+	l.mu.Unlock()
+	return nil
+}
+
+// parse parses the denylist file contents.
+func parse(data []byte) (map[string]struct{}, error) {
+	hashes := make(map[string]struct{})
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.ToLower(line)
+		if b, err := hex.DecodeString(line); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("line %d: not a hex-encoded SHA-256 hash", n)
+		}
+		hashes[line] = struct{}{}
+	}
+	return hashes, s.Err()
+}
+
+// Len returns the number of denied hashes.
+func (l *List) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.hashes)
+}
+
+// Denied reports whether the SHA-256 hash of token is on the list.
+func (l *List) Denied(token string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.hashes[HashToken(token)]
+	return ok
+}
+
+// HashToken returns the hex-encoded SHA-256 hash of token, in the form
+// expected in the denylist file.
+func HashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
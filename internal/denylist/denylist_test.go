@@ -0,0 +1,66 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package denylist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist")
+	writeFile(t, path, "# Leaked in incident 42.\n\n"+strings.ToUpper(HashToken("bad-token"))+"\n")
+
+	l, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if !l.Denied("bad-token") {
+		t.Error("expected bad-token to be denied")
+	}
+	if l.Denied("good-token") {
+		t.Error("expected good-token not to be denied")
+	}
+
+	// Reload picks up changes.
+	writeFile(t, path, HashToken("good-token")+"\n")
+	if err := l.Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if l.Denied("bad-token") || !l.Denied("good-token") {
+		t.Error("expected reload to replace the denied hashes")
+	}
+
+	// A failed reload keeps the previous contents.
+	writeFile(t, path, "bad-token\n")
+	if err := l.Reload(); err == nil {
+		t.Fatal("expected an error for a raw token in the file")
+	}
+	if !l.Denied("good-token") || l.Len() != 1 {
+		t.Error("expected the previous contents to be kept after a failed reload")
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Load(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	path := filepath.Join(dir, "short")
+	writeFile(t, path, "abc123\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected a line 1 error for a short hash, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package github
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// breakerState is the state of a CircuitBreaker.
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// CircuitBreaker is a Client that stops calling GitHub after a number of
+// consecutive failures. While open, calls fail fast with ErrCircuitOpen
+// for the cooldown period. After the cooldown a single probe call is let
+// through (half-open); its success closes the circuit and its failure
+// re-opens it for another cooldown.
+//
+// Only unexpected errors such as network failures and 5xx responses count
+// as failures. Definitive answers (unauthorized, not a member, rate
+// limited) and canceled requests do not.
+type CircuitBreaker struct {
+	next      Client
+	threshold int
+	cooldown  time.Duration
+	log       *slog.Logger
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+var _ Client = (*CircuitBreaker)(nil)
+
+// NewCircuitBreaker wraps next with a circuit breaker that opens after
+// threshold consecutive failures and stays open for cooldown. A threshold
+// below 1 is treated as 1.
+func NewCircuitBreaker(next Client, threshold int, cooldown time.Duration, log *slog.Logger) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{
+		next:      next,
+		threshold: threshold,
+		cooldown:  cooldown,
+		log:       log,
+		now:       time.Now,
+	}
+}
+
+// GetUser implements Client.
+func (b *CircuitBreaker) GetUser(ctx context.Context, token string) (*User, bool, error) {
+	if err := b.allow(); err != nil {
+		return nil, false, err
+	}
+	user, isClassicPAT, err := b.next.GetUser(ctx, token)
+	b.record(err)
+	return user, isClassicPAT, err
+}
+
+// CheckOrgMembership implements Client.
+func (b *CircuitBreaker) CheckOrgMembership(ctx context.Context, token, org, username string) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := b.next.CheckOrgMembership(ctx, token, org, username)
+	b.record(err)
+	return err
+}
+
+// GetOrgMembership implements Client.
+func (b *CircuitBreaker) GetOrgMembership(ctx context.Context, token, org, username string) (*OrgMembership, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	m, err := b.next.GetOrgMembership(ctx, token, org, username)
+	b.record(err)
+	return m, err
+}
+
+// ListUserTeams implements Client.
+func (b *CircuitBreaker) ListUserTeams(ctx context.Context, token, org string) ([]Team, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	teams, err := b.next.ListUserTeams(ctx, token, org)
+	b.record(err)
+	return teams, err
+}
+
+// CheckTeamMembership implements Client.
+func (b *CircuitBreaker) CheckTeamMembership(ctx context.Context, token, org, teamSlug, username string) (*TeamMembership, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	m, err := b.next.CheckTeamMembership(ctx, token, org, teamSlug, username)
+	b.record(err)
+	return m, err
+}
+
+// allow returns ErrCircuitOpen if a call must not be made. Once the
+// cooldown has elapsed it lets a single probe call through.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = breakerHalfOpen
+		b.log.Info("GitHub circuit breaker half-open, probing")
+	case breakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+	default:
+		return nil
+	}
+	b.probing = true
+	return nil
+}
+
+// record updates the breaker with the outcome of a call.
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if errors.Is(err, context.Canceled) {
+		// Says nothing about GitHub; let another call probe.
+		b.probing = false
+		return
+	}
+
+	if !isBreakerFailure(err) {
+		if b.state != breakerClosed {
+			b.log.Info("GitHub circuit breaker closed")
+		}
+		b.state = breakerClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		if b.state != breakerOpen {
+			b.log.Error("GitHub circuit breaker opened",
+				slog.Int("consecutive_failures", b.failures),
+				slog.Duration("cooldown", b.cooldown),
+				slog.String("error", err.Error()),
+			)
+		}
+		b.state = breakerOpen
+		b.openedAt = b.now()
+		b.probing = false
+	}
+}
+
+// isBreakerFailure reports whether err indicates that GitHub is unhealthy.
+func isBreakerFailure(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, ErrUnauthorized),
+		errors.Is(err, ErrForbiddenToken),
+		errors.Is(err, ErrTokenExpired),
+		errors.Is(err, ErrNotOrgMember),
+		errors.Is(err, ErrNotTeamMember),
+		errors.Is(err, ErrRateLimited),
+		errors.Is(err, ErrTooManyTeamPages):
+		return false
+	default:
+		return true
+	}
+}
@@ -11,9 +11,27 @@ import (
 
 // Sentinel errors for GitHub API operations.
 var (
-	ErrUnauthorized = errors.New("github: unauthorized (invalid or revoked token)")
-	ErrNotOrgMember = errors.New("github: user is not a member of the organization")
-	ErrRateLimited  = errors.New("github: API rate limit exceeded")
+	ErrUnauthorized   = errors.New("github: unauthorized (invalid or revoked token)")
+	ErrForbiddenToken = errors.New("github: token is not permitted to read the user profile")
+	ErrTokenExpired   = errors.New("github: token has expired or been revoked")
+	ErrNotOrgMember   = errors.New("github: user is not a member of the organization")
+	ErrRateLimited    = errors.New("github: API rate limit exceeded")
+	ErrNotTeamMember  = errors.New("github: user is not a member of the team")
+
+	ErrTooManyTeamPages = errors.New("github: too many team pages")
+
+	// ErrCircuitOpen is returned without calling GitHub while a
+	// CircuitBreaker is open.
+	ErrCircuitOpen = errors.New("github: circuit breaker is open")
+
+	// ErrUpstreamUnavailable is returned when a request could not be sent
+	// to GitHub or no response was received (e.g. DNS failures or refused
+	// connections).
+	ErrUpstreamUnavailable = errors.New("github: upstream unavailable")
+
+	// ErrBadUpstreamResponse is returned when GitHub (or something in front
+	// of it) answers with a body that is not the expected JSON.
+	ErrBadUpstreamResponse = errors.New("github: bad upstream response")
 )
 
 // Client defines the interface for interacting with the GitHub API.
@@ -27,6 +45,16 @@ type Client interface {
 	// Returns nil if the user is a member (HTTP 204), ErrNotOrgMember if not (HTTP 404).
 	CheckOrgMembership(ctx context.Context, token, org, username string) error
 
+	// GetOrgMembership retrieves the user's membership in the given org,
+	// including their role. Returns ErrNotOrgMember if the user is not a
+	// member (HTTP 404).
+	GetOrgMembership(ctx context.Context, token, org, username string) (*OrgMembership, error)
+
 	// ListUserTeams lists teams for the authenticated user, filtered to the given org.
 	ListUserTeams(ctx context.Context, token, org string) ([]Team, error)
+
+	// CheckTeamMembership retrieves the user's membership in a single team.
+	// Returns the membership (whose State may be active or pending), or
+	// ErrNotTeamMember if the user is not a member (HTTP 404).
+	CheckTeamMembership(ctx context.Context, token, org, teamSlug, username string) (*TeamMembership, error)
 }
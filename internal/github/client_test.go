@@ -5,13 +5,33 @@
 package github
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/andrewkroh/traefik-github-auth/internal/requestid"
 )
 
 const testToken = "test-token-for-unit-tests"
@@ -21,7 +41,7 @@ const testToken = "test-token-for-unit-tests"
 var _ Client = (*HTTPClient)(nil)
 
 func TestHTTPClient_GetUser_Success(t *testing.T) {
-	user := User{Login: "octocat", ID: 1}
+	user := User{Login: "octocat", ID: 1, Email: "octocat@github.com"}
 
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/user" {
@@ -46,6 +66,49 @@ func TestHTTPClient_GetUser_Success(t *testing.T) {
 	if got.ID != user.ID {
 		t.Errorf("ID: got %d, want %d", got.ID, user.ID)
 	}
+	if got.Email != user.Email {
+		t.Errorf("Email: got %q, want %q", got.Email, user.Email)
+	}
+}
+
+func TestHTTPClient_GetUser_ExtraFields(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"login":"octocat","id":1,"node_id":"MDQ6VXNlcjE=","avatar_url":"https://github.com/images/error/octocat_happy.gif"}`)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	got, _, err := client.GetUser(context.Background(), testToken)
+	if err != nil {
+		t.Fatalf("GetUser returned error: %v", err)
+	}
+	if got.NodeID != "MDQ6VXNlcjE=" {
+		t.Errorf("NodeID: got %q, want %q", got.NodeID, "MDQ6VXNlcjE=")
+	}
+	if got.AvatarURL != "https://github.com/images/error/octocat_happy.gif" {
+		t.Errorf("AvatarURL: got %q", got.AvatarURL)
+	}
+}
+
+func TestHTTPClient_GetUser_WithoutEmail(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"login":"octocat","id":1,"email":"octocat@github.com"}`)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL), WithoutEmail())
+	got, _, err := client.GetUser(context.Background(), testToken)
+	if err != nil {
+		t.Fatalf("GetUser returned error: %v", err)
+	}
+	if got.Email != "" {
+		t.Errorf("Email: got %q, want empty", got.Email)
+	}
+	if got.Login != "octocat" {
+		t.Errorf("Login: got %q, want %q", got.Login, "octocat")
+	}
 }
 
 func TestHTTPClient_GetUser_ClassicPAT(t *testing.T) {
@@ -88,6 +151,259 @@ func TestHTTPClient_GetUser_Unauthorized(t *testing.T) {
 	}
 }
 
+func TestHTTPClient_GetUser_Forbidden(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message":"Resource not accessible by personal access token"}`)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	_, _, err := client.GetUser(context.Background(), testToken)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !errors.Is(err, ErrForbiddenToken) {
+		t.Errorf("expected ErrForbiddenToken, got: %v", err)
+	}
+}
+
+func TestHTTPClient_RequestID(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(requestid.Header)
+		fmt.Fprint(w, `{"login":"octocat","id":1}`)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	ctx := requestid.NewContext(context.Background(), "req-123")
+	if _, _, err := client.GetUser(ctx, testToken); err != nil {
+		t.Fatalf("GetUser returned error: %v", err)
+	}
+	if got != "req-123" {
+		t.Errorf("%s = %q, want %q", requestid.Header, got, "req-123")
+	}
+}
+
+func TestHTTPClient_Accept(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Accept"))
+		switch r.URL.Path {
+		case "/user":
+			fmt.Fprint(w, `{"login":"octocat","id":1}`)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"default", nil, "application/vnd.github+json"},
+		{"override", []Option{WithAccept("application/vnd.github.v3+json")}, "application/vnd.github.v3+json"},
+		{"empty ignored", []Option{WithAccept("")}, "application/vnd.github+json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			client := NewHTTPClient(append([]Option{WithBaseURL(srv.URL)}, tt.opts...)...)
+			if _, _, err := client.GetUser(context.Background(), testToken); err != nil {
+				t.Fatalf("GetUser returned error: %v", err)
+			}
+			if err := client.CheckOrgMembership(context.Background(), testToken, "my-org", "octocat"); err != nil {
+				t.Fatalf("CheckOrgMembership returned error: %v", err)
+			}
+			if want := []string{tt.want, tt.want}; !slices.Equal(got, want) {
+				t.Errorf("Accept headers = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_GitHubRequestID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-GitHub-Request-Id", "CAFE:1234:5678:9ABC:DEF0")
+		switch r.URL.Path {
+		case "/user":
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, `{"message":"Server Error"}`)
+		case "/user/teams":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	var logBuf bytes.Buffer
+	client := NewHTTPClient(WithBaseURL(srv.URL), WithLogger(slog.New(slog.NewJSONHandler(&logBuf, nil))))
+	ctx := context.Background()
+
+	_, _, err := client.GetUser(ctx, testToken)
+	if err == nil || !strings.Contains(err.Error(), "CAFE:1234:5678:9ABC:DEF0") {
+		t.Errorf("expected the GitHub request id in the GetUser error, got: %v", err)
+	}
+	err = client.CheckOrgMembership(ctx, testToken, "my-org", "octocat")
+	if err == nil || !strings.Contains(err.Error(), "CAFE:1234:5678:9ABC:DEF0") {
+		t.Errorf("expected the GitHub request id in the CheckOrgMembership error, got: %v", err)
+	}
+	_, err = client.ListUserTeams(ctx, testToken, "my-org")
+	if !errors.Is(err, ErrUnauthorized) || !strings.Contains(err.Error(), "CAFE:1234:5678:9ABC:DEF0") {
+		t.Errorf("expected ErrUnauthorized with the GitHub request id, got: %v", err)
+	}
+	_, err = client.CheckTeamMembership(ctx, testToken, "my-org", "platform", "octocat")
+	if err == nil || !strings.Contains(err.Error(), "CAFE:1234:5678:9ABC:DEF0") {
+		t.Errorf("expected the GitHub request id in the CheckTeamMembership error, got: %v", err)
+	}
+
+	if n := strings.Count(logBuf.String(), `"github.request_id":"CAFE:1234:5678:9ABC:DEF0"`); n != 4 {
+		t.Errorf("expected the GitHub request id in 4 log records, got %d: %s", n, logBuf.String())
+	}
+}
+
+func TestHTTPClient_ErrorBodyLimit(t *testing.T) {
+	body := strings.Repeat("x", 1<<20)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	ctx := context.Background()
+	_, _, userErr := client.GetUser(ctx, testToken)
+	orgErr := client.CheckOrgMembership(ctx, testToken, "my-org", "octocat")
+	_, teamsErr := client.ListUserTeams(ctx, testToken, "my-org")
+
+	want := "github: unexpected status 500: " + body[:errorBodyLimit]
+	for name, err := range map[string]error{"GetUser": userErr, "CheckOrgMembership": orgErr, "ListUserTeams": teamsErr} {
+		if err == nil || err.Error() != want {
+			t.Errorf("%s: expected the error body truncated to %d bytes, got %d-byte error", name, errorBodyLimit, len(fmt.Sprint(err)))
+		}
+	}
+}
+
+func TestHTTPClient_HTMLResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body>Service Unavailable</body></html>")
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	ctx := context.Background()
+	_, _, userErr := client.GetUser(ctx, testToken)
+	_, teamsErr := client.ListUserTeams(ctx, testToken, "my-org")
+	_, teamErr := client.CheckTeamMembership(ctx, testToken, "my-org", "platform", "octocat")
+
+	const want = "github: bad upstream response: expected JSON, got text/html"
+	for name, err := range map[string]error{"GetUser": userErr, "ListUserTeams": teamsErr, "CheckTeamMembership": teamErr} {
+		if err == nil || err.Error() != want {
+			t.Errorf("%s: expected %q, got: %v", name, want, err)
+		}
+		if !errors.Is(err, ErrBadUpstreamResponse) {
+			t.Errorf("%s: expected ErrBadUpstreamResponse, got: %v", name, err)
+		}
+	}
+}
+
+func TestHTTPClient_DecodeError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"login":`)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	ctx := context.Background()
+	_, _, userErr := client.GetUser(ctx, testToken)
+	_, teamsErr := client.ListUserTeams(ctx, testToken, "my-org")
+
+	for name, err := range map[string]error{"GetUser": userErr, "ListUserTeams": teamsErr} {
+		if !errors.Is(err, ErrBadUpstreamResponse) {
+			t.Errorf("%s: expected ErrBadUpstreamResponse, got: %v", name, err)
+		}
+	}
+}
+
+func TestHTTPClient_GetUser_ConnectionError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	_, _, err := client.GetUser(context.Background(), testToken)
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("expected ErrUpstreamUnavailable, got: %v", err)
+	}
+
+	// A canceled request is not reported as GitHub being unavailable.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = client.GetUser(ctx, testToken)
+	if err == nil || errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("expected a non-ErrUpstreamUnavailable error, got: %v", err)
+	}
+}
+
+func TestHTTPClient_GetUser_TokenExpired(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiration string
+		body       string
+		wantErr    error
+	}{
+		{
+			name:    "expired message without header",
+			body:    `{"message":"Token expired","documentation_url":"https://docs.github.com/rest"}`,
+			wantErr: ErrForbiddenToken,
+		},
+		{
+			name:    "unrelated message mentioning expiry",
+			body:    `{"message":"Although you appear to have the correct authorization credentials, the SAML session has expired or the grant was revoked."}`,
+			wantErr: ErrForbiddenToken,
+		},
+		{
+			name:       "past expiration header",
+			expiration: time.Now().Add(-time.Hour).UTC().Format("2006-01-02 15:04:05 MST"),
+			body:       `{"message":"Forbidden"}`,
+			wantErr:    ErrTokenExpired,
+		},
+		{
+			name:       "future expiration header",
+			expiration: time.Now().Add(time.Hour).UTC().Format("2006-01-02 15:04:05 MST"),
+			body:       `{"message":"Resource not accessible by personal access token"}`,
+			wantErr:    ErrForbiddenToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-RateLimit-Remaining", "4999")
+				if tt.expiration != "" {
+					w.Header().Set("GitHub-Authentication-Token-Expiration", tt.expiration)
+				}
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			client := NewHTTPClient(WithBaseURL(srv.URL))
+			_, _, err := client.GetUser(context.Background(), testToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
 func TestHTTPClient_GetUser_ServerError(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -126,6 +442,81 @@ func TestHTTPClient_CheckOrgMembership_IsMember(t *testing.T) {
 	}
 }
 
+func TestHTTPClient_CheckOrgMembership_EscapesPath(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.EscapedPath(), "/orgs/my-org/members/octo%20cat"; got != want {
+			t.Errorf("unexpected path: got %s, want %s", got, want)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	err := client.CheckOrgMembership(context.Background(), testToken, "my-org", "octo cat")
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+}
+
+func TestHTTPClient_PathSegmentEscaping(t *testing.T) {
+	tests := []struct {
+		name     string
+		call     func(c *HTTPClient) error
+		wantPath string
+	}{
+		{
+			name: "org membership",
+			call: func(c *HTTPClient) error {
+				return c.CheckOrgMembership(context.Background(), testToken, "my-org", "octo/cat")
+			},
+			wantPath: "/orgs/my-org/members/octo%2Fcat",
+		},
+		{
+			name: "org role",
+			call: func(c *HTTPClient) error {
+				_, err := c.GetOrgMembership(context.Background(), testToken, "my-org", "octo cat/../admin")
+				return err
+			},
+			wantPath: "/orgs/my-org/memberships/octo%20cat%2F..%2Fadmin",
+		},
+		{
+			name: "team membership",
+			call: func(c *HTTPClient) error {
+				_, err := c.CheckTeamMembership(context.Background(), testToken, "my-org", "team?x=1", "octo cat")
+				return err
+			},
+			wantPath: "/orgs/my-org/teams/team%3Fx=1/memberships/octo%20cat",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.RequestURI
+				if r.URL.RawQuery != "" {
+					t.Errorf("unexpected query: %s", r.URL.RawQuery)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				if strings.Contains(r.URL.Path, "/members/") {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				fmt.Fprint(w, `{"state":"active","role":"member"}`)
+			}))
+			defer srv.Close()
+
+			client := NewHTTPClient(WithBaseURL(srv.URL))
+			if err := tt.call(client); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("request path: got %s, want %s", gotPath, tt.wantPath)
+			}
+		})
+	}
+}
+
 func TestHTTPClient_CheckOrgMembership_NotMember(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -160,6 +551,66 @@ func TestHTTPClient_CheckOrgMembership_Unauthorized(t *testing.T) {
 	}
 }
 
+func TestHTTPClient_CheckOrgMembership_RetryOn404(t *testing.T) {
+	newServer := func(calls *int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*calls++
+			if *calls == 1 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	}
+
+	t.Run("retry enabled", func(t *testing.T) {
+		var calls int
+		srv := newServer(&calls)
+		defer srv.Close()
+
+		client := NewHTTPClient(WithBaseURL(srv.URL), WithMembershipRetryOn404(time.Millisecond))
+		if err := client.CheckOrgMembership(context.Background(), testToken, "myorg", "octocat"); err != nil {
+			t.Fatalf("expected success after retry, got: %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("expected 2 requests, got %d", calls)
+		}
+	})
+
+	t.Run("retry disabled", func(t *testing.T) {
+		var calls int
+		srv := newServer(&calls)
+		defer srv.Close()
+
+		client := NewHTTPClient(WithBaseURL(srv.URL))
+		err := client.CheckOrgMembership(context.Background(), testToken, "myorg", "octocat")
+		if !errors.Is(err, ErrNotOrgMember) {
+			t.Fatalf("expected ErrNotOrgMember, got: %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 request, got %d", calls)
+		}
+	})
+
+	t.Run("retried once only", func(t *testing.T) {
+		var calls int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer srv.Close()
+
+		client := NewHTTPClient(WithBaseURL(srv.URL), WithMembershipRetryOn404(time.Millisecond))
+		err := client.CheckOrgMembership(context.Background(), testToken, "myorg", "octocat")
+		if !errors.Is(err, ErrNotOrgMember) {
+			t.Fatalf("expected ErrNotOrgMember, got: %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("expected 2 requests, got %d", calls)
+		}
+	})
+}
+
 func TestHTTPClient_ListUserTeams_Success(t *testing.T) {
 	teams := []Team{
 		{Slug: "backend", Organization: Organization{Login: "my-org"}},
@@ -197,6 +648,30 @@ func TestHTTPClient_ListUserTeams_Success(t *testing.T) {
 	}
 }
 
+func TestHTTPClient_ListUserTeams_Names(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[
+			{"id":1,"slug":"platform-team","name":"Platform Team","organization":{"login":"my-org"}},
+			{"id":2,"slug":"sre","name":"SRE, On-Call","organization":{"login":"my-org"}}
+		]`)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	got, err := client.ListUserTeams(context.Background(), testToken, "my-org")
+	if err != nil {
+		t.Fatalf("ListUserTeams returned error: %v", err)
+	}
+	want := []Team{
+		{Slug: "platform-team", Name: "Platform Team", Organization: Organization{Login: "my-org"}},
+		{Slug: "sre", Name: "SRE, On-Call", Organization: Organization{Login: "my-org"}},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ListUserTeams() = %+v, want %+v", got, want)
+	}
+}
+
 func TestHTTPClient_ListUserTeams_Pagination(t *testing.T) {
 	page1Teams := []Team{
 		{Slug: "backend", Organization: Organization{Login: "my-org"}},
@@ -251,6 +726,85 @@ func TestHTTPClient_ListUserTeams_Pagination(t *testing.T) {
 	}
 }
 
+func TestHTTPClient_ListUserTeams_ConditionalRequests(t *testing.T) {
+	teams := []Team{{Slug: "backend", Organization: Organization{Login: "my-org"}}}
+	const etag = `"abc123"`
+
+	var requests, notModified int
+	var lastIfNoneMatch string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		lastIfNoneMatch = r.Header.Get("If-None-Match")
+		if lastIfNoneMatch == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(teams)
+	}))
+	defer srv.Close()
+
+	t.Run("enabled", func(t *testing.T) {
+		requests, notModified = 0, 0
+		client := NewHTTPClient(WithBaseURL(srv.URL), WithConditionalRequests(true))
+
+		// The first request stores the ETag; the second reuses the page.
+		for i := range 2 {
+			got, err := client.ListUserTeams(context.Background(), testToken, "my-org")
+			if err != nil {
+				t.Fatalf("ListUserTeams returned error: %v", err)
+			}
+			if len(got) != 1 || got[0].Slug != "backend" {
+				t.Fatalf("request %d: unexpected teams %+v", i+1, got)
+			}
+		}
+		if requests != 2 || notModified != 1 {
+			t.Fatalf("expected 2 requests with 1 not modified, got %d and %d", requests, notModified)
+		}
+
+		// A different token does not reuse the stored ETag.
+		if _, err := client.ListUserTeams(context.Background(), "other-token", "my-org"); err != nil {
+			t.Fatalf("ListUserTeams returned error: %v", err)
+		}
+		if lastIfNoneMatch != "" {
+			t.Errorf("expected no If-None-Match for a different token, got %q", lastIfNoneMatch)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		requests, notModified = 0, 0
+		client := NewHTTPClient(WithBaseURL(srv.URL))
+
+		for range 2 {
+			if _, err := client.ListUserTeams(context.Background(), testToken, "my-org"); err != nil {
+				t.Fatalf("ListUserTeams returned error: %v", err)
+			}
+		}
+		if notModified != 0 {
+			t.Fatalf("expected no conditional requests, got %d", notModified)
+		}
+	})
+}
+
+func TestHTTPClient_TeamsPageKey(t *testing.T) {
+	const url = "https://api.github.com/user/teams?per_page=100"
+	a, b := NewHTTPClient(), NewHTTPClient()
+
+	if a.teamsPageKey(url, testToken) != a.teamsPageKey(url, testToken) {
+		t.Error("expected a stable key within one client")
+	}
+	if a.teamsPageKey(url, testToken) == b.teamsPageKey(url, testToken) {
+		t.Error("expected keys to differ across clients")
+	}
+
+	sum := sha256.Sum256([]byte(testToken))
+	if key := a.teamsPageKey(url, testToken); strings.Contains(key, testToken) || strings.Contains(key, hex.EncodeToString(sum[:])) {
+		t.Errorf("key %q is derivable from the token alone", key)
+	}
+}
+
 func TestHTTPClient_ListUserTeams_Empty(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -322,6 +876,35 @@ func TestHTTPClient_ListUserTeams_CaseInsensitiveFilter(t *testing.T) {
 	}
 }
 
+func TestHTTPClient_ListUserTeams_LogsDiscardedTeams(t *testing.T) {
+	teams := []Team{
+		{Slug: "backend", Organization: Organization{Login: "my-org"}},
+		{Slug: "infra", Organization: Organization{Login: "other-org"}},
+		{Slug: "docs", Organization: Organization{Login: "third-org"}},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(teams)
+	}))
+	defer srv.Close()
+
+	var logBuf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&logBuf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	client := NewHTTPClient(WithBaseURL(srv.URL), WithLogger(log))
+	if _, err := client.ListUserTeams(context.Background(), testToken, "my-org"); err != nil {
+		t.Fatalf("ListUserTeams returned error: %v", err)
+	}
+
+	if !contains(logBuf.String(), `"msg":"discarded teams from other orgs"`) {
+		t.Fatalf("expected discarded teams log, got: %s", logBuf.String())
+	}
+	if !contains(logBuf.String(), `"discarded_teams":2`) {
+		t.Errorf("expected discarded_teams=2, got: %s", logBuf.String())
+	}
+}
+
 func TestHTTPClient_GetUser_RateLimited_429(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusTooManyRequests)
@@ -360,6 +943,72 @@ func TestHTTPClient_GetUser_RateLimited_Header(t *testing.T) {
 	}
 }
 
+func TestHTTPClient_GetUser_SecondaryRateLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		body       string
+		wantErr    error
+	}{
+		{
+			name:       "retry after",
+			retryAfter: "60",
+			body:       `{"message":"You have exceeded a secondary rate limit."}`,
+			wantErr:    ErrRateLimited,
+		},
+		{
+			name:       "retry after without message",
+			retryAfter: "30",
+			body:       `{"message":"Forbidden"}`,
+			wantErr:    ErrRateLimited,
+		},
+		{
+			name:    "message without retry after",
+			body:    `{"message":"You have exceeded a secondary rate limit. Please wait a few minutes before you try again."}`,
+			wantErr: ErrRateLimited,
+		},
+		{
+			name:    "plain forbidden",
+			body:    `{"message":"Resource not accessible by personal access token"}`,
+			wantErr: ErrForbiddenToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.Header().Set("X-RateLimit-Remaining", "4000")
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			client := NewHTTPClient(WithBaseURL(srv.URL))
+			_, _, err := client.GetUser(context.Background(), testToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_CheckOrgMembership_SecondaryRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message":"You have exceeded a secondary rate limit."}`)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	err := client.CheckOrgMembership(context.Background(), testToken, "my-org", "octocat")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got: %v", err)
+	}
+}
+
 func TestHTTPClient_CheckOrgMembership_RateLimited(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusTooManyRequests)
@@ -389,3 +1038,748 @@ func searchString(s, substr string) bool {
 	}
 	return false
 }
+
+// staticTokenSource is a TokenSource that always returns the same token.
+type staticTokenSource string
+
+func (s staticTokenSource) Token(_ context.Context, _ string) (string, error) {
+	return string(s), nil
+}
+
+func TestHTTPClient_OrgTokenSource(t *testing.T) {
+	const appToken = "app-installation-token"
+
+	gotAuth := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth[r.URL.Path] = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/user":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(User{Login: "octocat", ID: 1})
+		case "/orgs/my-org/members/octocat":
+			w.WriteHeader(http.StatusNoContent)
+		case "/user/teams":
+			// Installation tokens have no user, so GitHub rejects them here.
+			if r.Header.Get("Authorization") != "Bearer "+testToken {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"message":"Resource not accessible by integration"}`)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"slug":"backend","organization":{"login":"my-org"}}]`)
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL), WithOrgTokenSource(staticTokenSource(appToken)))
+	ctx := context.Background()
+	if _, _, err := client.GetUser(ctx, testToken); err != nil {
+		t.Fatalf("GetUser returned error: %v", err)
+	}
+	if err := client.CheckOrgMembership(ctx, testToken, "my-org", "octocat"); err != nil {
+		t.Fatalf("CheckOrgMembership returned error: %v", err)
+	}
+	teams, err := client.ListUserTeams(ctx, testToken, "my-org")
+	if err != nil {
+		t.Fatalf("ListUserTeams returned error: %v", err)
+	}
+	if len(teams) != 1 || teams[0].Slug != "backend" {
+		t.Errorf("ListUserTeams: got %+v, want the backend team", teams)
+	}
+
+	if got, want := gotAuth["/user"], "Bearer "+testToken; got != want {
+		t.Errorf("GetUser Authorization: got %q, want %q", got, want)
+	}
+	if got, want := gotAuth["/orgs/my-org/members/octocat"], "Bearer "+appToken; got != want {
+		t.Errorf("CheckOrgMembership Authorization: got %q, want %q", got, want)
+	}
+	if got, want := gotAuth["/user/teams"], "Bearer "+testToken; got != want {
+		t.Errorf("ListUserTeams Authorization: got %q, want %q", got, want)
+	}
+}
+
+func TestAppTokenSource_MintsAndCaches(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+
+		// Verify the JWT is signed with the app key and issued by the app.
+		jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		parts := strings.Split(jwt, ".")
+		if len(parts) != 3 {
+			t.Fatalf("expected 3 JWT segments, got %d", len(parts))
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+			t.Errorf("JWT signature did not verify: %v", err)
+		}
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims struct {
+			Iss string `json:"iss"`
+		}
+		json.Unmarshal(payload, &claims)
+		if claims.Iss != "7" {
+			t.Errorf("iss claim: got %q, want %q", claims.Iss, "7")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{
+			"token":      "ghs_installation",
+			"expires_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		})
+	}))
+	defer srv.Close()
+
+	ts := NewAppTokenSource(7, 42, key, srv.URL, nil)
+	for range 2 {
+		got, err := ts.Token(context.Background(), testToken)
+		if err != nil {
+			t.Fatalf("Token returned error: %v", err)
+		}
+		if got != "ghs_installation" {
+			t.Errorf("Token: got %q, want %q", got, "ghs_installation")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 mint call (cached), got %d", calls)
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	got, err := ParsePrivateKey(pemBytes)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey returned error: %v", err)
+	}
+	if !got.Equal(key) {
+		t.Error("parsed key does not match original")
+	}
+
+	if _, err := ParsePrivateKey([]byte("not a key")); err == nil {
+		t.Error("expected error for non-PEM input, got nil")
+	}
+}
+
+func TestHTTPClient_GetUser_ClassicPATDetection(t *testing.T) {
+	tests := []struct {
+		name      string
+		detection ClassicPATDetection
+		token     string
+		scopes    bool
+		want      bool
+	}{
+		{"header/scopes present", DetectByHeader, "github_pat_abc", true, true},
+		{"header/classic prefix without scopes", DetectByHeader, "ghp_abc", false, false},
+		{"prefix/fine-grained prefix with scopes", DetectByPrefix, "github_pat_abc", true, false},
+		{"prefix/classic prefix without scopes", DetectByPrefix, "ghp_abc", false, true},
+		{"prefix/oauth prefix", DetectByPrefix, "gho_abc", false, true},
+		{"prefix/unknown prefix falls back to header", DetectByPrefix, "opaque-token", true, true},
+		{"prefix/unknown prefix without scopes", DetectByPrefix, "opaque-token", false, false},
+		{"any/classic prefix without scopes", DetectByAny, "ghp_abc", false, true},
+		{"any/fine-grained prefix with scopes", DetectByAny, "github_pat_abc", true, true},
+		{"any/fine-grained prefix without scopes", DetectByAny, "github_pat_abc", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if tt.scopes {
+					w.Header().Set("X-OAuth-Scopes", "repo")
+				}
+				json.NewEncoder(w).Encode(User{Login: "octocat", ID: 1})
+			}))
+			defer srv.Close()
+
+			client := NewHTTPClient(WithBaseURL(srv.URL), WithClassicPATDetection(tt.detection))
+			_, isClassic, err := client.GetUser(context.Background(), tt.token)
+			if err != nil {
+				t.Fatalf("GetUser returned error: %v", err)
+			}
+			if isClassic != tt.want {
+				t.Errorf("isClassicPAT: got %v, want %v", isClassic, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_GetUser_ClassicPATDetectionDefault(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		scopes bool
+		want   bool
+	}{
+		{"header present", "github_pat_abc", true, true},
+		{"classic prefix only", "ghp_abc", false, true},
+		{"oauth prefix only", "gho_abc", false, true},
+		{"neither", "github_pat_abc", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if tt.scopes {
+					w.Header().Set("X-OAuth-Scopes", "repo")
+				}
+				json.NewEncoder(w).Encode(User{Login: "octocat", ID: 1})
+			}))
+			defer srv.Close()
+
+			client := NewHTTPClient(WithBaseURL(srv.URL))
+			_, isClassic, err := client.GetUser(context.Background(), tt.token)
+			if err != nil {
+				t.Fatalf("GetUser returned error: %v", err)
+			}
+			if isClassic != tt.want {
+				t.Errorf("isClassicPAT: got %v, want %v", isClassic, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseClassicPATDetection(t *testing.T) {
+	for _, s := range []string{"header", "prefix", "any"} {
+		if _, err := ParseClassicPATDetection(s); err != nil {
+			t.Errorf("ParseClassicPATDetection(%q) returned error: %v", s, err)
+		}
+	}
+	if _, err := ParseClassicPATDetection("rate_limit"); err == nil {
+		t.Error("expected error for unknown strategy, got nil")
+	}
+}
+
+func TestHTTPClient_ListUserTeams_MaxPages(t *testing.T) {
+	callCount := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		// Always advertise another page.
+		nextURL := fmt.Sprintf("http://%s/user/teams?per_page=100&page=%d", r.Host, callCount+1)
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextURL))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]Team{{Slug: "backend", Organization: Organization{Login: "my-org"}}})
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL), WithMaxTeamPages(3))
+	_, err := client.ListUserTeams(context.Background(), testToken, "my-org")
+	if !errors.Is(err, ErrTooManyTeamPages) {
+		t.Fatalf("expected ErrTooManyTeamPages, got: %v", err)
+	}
+	if callCount != 3 {
+		t.Errorf("expected 3 HTTP calls before stopping, got %d", callCount)
+	}
+}
+
+func TestHTTPClient_CheckTeamMembership(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantState string
+		wantErr   error
+	}{
+		{"active", http.StatusOK, `{"role":"member","state":"active"}`, TeamMembershipActive, nil},
+		{"pending", http.StatusOK, `{"role":"member","state":"pending"}`, TeamMembershipPending, nil},
+		{"not member", http.StatusNotFound, `{"message":"Not Found"}`, "", ErrNotTeamMember},
+		{"unauthorized", http.StatusUnauthorized, `{"message":"Bad credentials"}`, "", ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/orgs/my-org/teams/platform/memberships/octocat" {
+					t.Errorf("unexpected path: %s", r.URL.Path)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			client := NewHTTPClient(WithBaseURL(srv.URL))
+			got, err := client.CheckTeamMembership(context.Background(), testToken, "my-org", "platform", "octocat")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckTeamMembership returned error: %v", err)
+			}
+			if got.State != tt.wantState {
+				t.Errorf("State: got %q, want %q", got.State, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_GetOrgMembership(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantRole string
+		wantErr  error
+	}{
+		{"admin", http.StatusOK, `{"url":"https://api.github.com/orgs/my-org/memberships/octocat","state":"active","role":"admin"}`, OrgRoleAdmin, nil},
+		{"member", http.StatusOK, `{"state":"active","role":"member"}`, OrgRoleMember, nil},
+		{"not member", http.StatusNotFound, `{"message":"Not Found"}`, "", ErrNotOrgMember},
+		{"unauthorized", http.StatusUnauthorized, `{"message":"Bad credentials"}`, "", ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/orgs/my-org/memberships/octocat" {
+					t.Errorf("unexpected path: %s", r.URL.Path)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			client := NewHTTPClient(WithBaseURL(srv.URL))
+			got, err := client.GetOrgMembership(context.Background(), testToken, "my-org", "octocat")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetOrgMembership returned error: %v", err)
+			}
+			if got.Role != tt.wantRole {
+				t.Errorf("Role: got %q, want %q", got.Role, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_Stats(t *testing.T) {
+	remaining := 4999
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		remaining--
+		switch r.URL.Path {
+		case "/user":
+			fmt.Fprint(w, `{"login":"octocat","id":1}`)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	if got := client.Stats(); got.Requests != 0 || got.RateLimitRemaining != -1 {
+		t.Fatalf("initial Stats() = %+v, want no requests and unknown remaining", got)
+	}
+
+	if _, _, err := client.GetUser(context.Background(), testToken); err != nil {
+		t.Fatalf("GetUser() error: %v", err)
+	}
+	if err := client.CheckOrgMembership(context.Background(), testToken, "myorg", "octocat"); err != nil {
+		t.Fatalf("CheckOrgMembership() error: %v", err)
+	}
+
+	// Pings are unauthenticated and are not counted.
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() error: %v", err)
+	}
+
+	want := Stats{Requests: 2, RateLimitRemaining: 4998}
+	if got := client.Stats(); got != want {
+		t.Fatalf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHTTPClient_GetUser_TokenExpiration(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Time
+	}{
+		{"absent", "", time.Time{}},
+		{"utc", "2024-06-30 23:59:59 UTC", time.Date(2024, 6, 30, 23, 59, 59, 0, time.UTC)},
+		{"offset", "2024-06-30 16:59:59 -0700", time.Date(2024, 6, 30, 23, 59, 59, 0, time.UTC)},
+		{"invalid", "next tuesday", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("GitHub-Authentication-Token-Expiration", tt.header)
+				}
+				fmt.Fprint(w, `{"login":"octocat","id":1}`)
+			}))
+			defer srv.Close()
+
+			client := NewHTTPClient(WithBaseURL(srv.URL))
+			user, _, err := client.GetUser(context.Background(), testToken)
+			if err != nil {
+				t.Fatalf("GetUser() error: %v", err)
+			}
+			if !user.TokenExpiresAt.Equal(tt.want) {
+				t.Errorf("TokenExpiresAt = %v, want %v", user.TokenExpiresAt, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxiedHTTPClient(t *testing.T) {
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute target URL.
+		proxiedHost = r.URL.Host
+		fmt.Fprint(w, `{"login":"octocat","id":1}`)
+	}))
+	defer proxy.Close()
+
+	hc, err := ProxiedHTTPClient(&http.Client{Timeout: 5 * time.Second}, proxy.URL)
+	if err != nil {
+		t.Fatalf("ProxiedHTTPClient() error: %v", err)
+	}
+	client := NewHTTPClient(WithBaseURL("http://github.invalid"), WithHTTPClient(hc))
+	user, _, err := client.GetUser(context.Background(), testToken)
+	if err != nil {
+		t.Fatalf("GetUser() error: %v", err)
+	}
+	if user.Login != "octocat" {
+		t.Errorf("expected login octocat, got %q", user.Login)
+	}
+	if proxiedHost != "github.invalid" {
+		t.Errorf("expected the request for github.invalid to go through the proxy, got host %q", proxiedHost)
+	}
+	if hc.Timeout != 5*time.Second {
+		t.Errorf("expected the client timeout to be kept, got %v", hc.Timeout)
+	}
+}
+
+func TestProxiedHTTPClient_Invalid(t *testing.T) {
+	_, err := ProxiedHTTPClient(http.DefaultClient, "not a url")
+	if err == nil || !strings.Contains(err.Error(), "invalid proxy URL") {
+		t.Fatalf("expected an invalid proxy URL error, got %v", err)
+	}
+}
+
+func TestHTTPClient_RequestDurationMetric(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	defer otel.SetMeterProvider(prev)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"login":"octocat","id":1}`)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	if _, _, err := client.GetUser(context.Background(), testToken); err != nil {
+		t.Fatalf("GetUser() error: %v", err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "github.api.request.duration" {
+				continue
+			}
+			hist, ok := m.Data.(metricdata.Histogram[float64])
+			if !ok {
+				t.Fatalf("unexpected data type %T", m.Data)
+			}
+			for _, dp := range hist.DataPoints {
+				op, _ := dp.Attributes.Value("operation")
+				status, _ := dp.Attributes.Value("http.response.status_code")
+				if op.AsString() == "github.get_user" && status.AsInt64() == http.StatusOK && dp.Count == 1 {
+					return
+				}
+			}
+			t.Fatalf("no github.get_user measurement with status 200 in %+v", hist.DataPoints)
+		}
+	}
+	t.Fatal("expected a github.api.request.duration metric")
+}
+
+func TestHTTPClient_Ping(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"ok", http.StatusOK, false},
+		{"not found is reachable", http.StatusNotFound, false},
+		{"server error", http.StatusBadGateway, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/rate_limit" {
+					t.Errorf("expected path /rate_limit, got %s", r.URL.Path)
+				}
+				if auth := r.Header.Get("Authorization"); auth != "" {
+					t.Errorf("expected no Authorization header, got %q", auth)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			client := NewHTTPClient(WithBaseURL(srv.URL))
+			err := client.Ping(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+
+		client := NewHTTPClient(WithBaseURL(srv.URL))
+		if err := client.Ping(context.Background()); err == nil {
+			t.Fatal("expected error for an unreachable server")
+		}
+	})
+}
+
+func TestHTTPClient_WithEndpointPaths(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(
+		WithBaseURL(srv.URL),
+		WithEndpointPaths(EndpointPaths{
+			OrgMembership: "/api/v3/organizations/{org}/public_members/{username}",
+		}),
+	)
+	if err := client.CheckOrgMembership(context.Background(), testToken, "myorg", "octocat"); err != nil {
+		t.Fatalf("CheckOrgMembership returned error: %v", err)
+	}
+	if want := "/api/v3/organizations/myorg/public_members/octocat"; gotPath != want {
+		t.Errorf("path: got %q, want %q", gotPath, want)
+	}
+
+	// Fields left empty keep their defaults.
+	if client.paths.User != "/user" {
+		t.Errorf("User path: got %q, want %q", client.paths.User, "/user")
+	}
+}
+
+func TestHTTPClient_WithEndpointPaths_OrgRole(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"state":"active","role":"admin"}`)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(
+		WithBaseURL(srv.URL),
+		WithEndpointPaths(EndpointPaths{
+			OrgRole: "/api/v3/organizations/{org}/memberships/{username}",
+		}),
+	)
+	membership, err := client.GetOrgMembership(context.Background(), testToken, "myorg", "octocat")
+	if err != nil {
+		t.Fatalf("GetOrgMembership returned error: %v", err)
+	}
+	if want := "/api/v3/organizations/myorg/memberships/octocat"; gotPath != want {
+		t.Errorf("path: got %q, want %q", gotPath, want)
+	}
+	if membership.Role != "admin" {
+		t.Errorf("Role: got %q, want %q", membership.Role, "admin")
+	}
+}
+
+func TestHTTPClient_GetOrgMembership_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		wantErr     error
+	}{
+		{name: "not member", status: http.StatusNotFound, wantErr: ErrNotOrgMember},
+		{name: "unauthorized", status: http.StatusUnauthorized, wantErr: ErrUnauthorized},
+		{name: "html", status: http.StatusOK, contentType: "text/html", body: "<html></html>", wantErr: ErrBadUpstreamResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-GitHub-Request-Id", "ABCD:1234")
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			client := NewHTTPClient(WithBaseURL(srv.URL))
+			_, err := client.GetOrgMembership(context.Background(), testToken, "myorg", "octocat")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got: %v", tt.wantErr, err)
+			}
+			if !strings.Contains(err.Error(), "ABCD:1234") {
+				t.Errorf("expected error to include the GitHub request ID, got: %v", err)
+			}
+		})
+	}
+}
+
+// breakerTestClient is a Client whose GetUser returns err.
+type breakerTestClient struct {
+	err   error
+	calls int
+}
+
+func (c *breakerTestClient) GetUser(context.Context, string) (*User, bool, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, false, c.err
+	}
+	return &User{Login: "octocat"}, false, nil
+}
+
+func (c *breakerTestClient) CheckOrgMembership(context.Context, string, string, string) error {
+	c.calls++
+	return c.err
+}
+
+func (c *breakerTestClient) GetOrgMembership(context.Context, string, string, string) (*OrgMembership, error) {
+	c.calls++
+	return nil, c.err
+}
+
+func (c *breakerTestClient) ListUserTeams(context.Context, string, string) ([]Team, error) {
+	c.calls++
+	return nil, c.err
+}
+
+func (c *breakerTestClient) CheckTeamMembership(context.Context, string, string, string, string) (*TeamMembership, error) {
+	c.calls++
+	return nil, c.err
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	next := &breakerTestClient{err: errors.New("github: executing request: connection refused")}
+	now := time.Unix(1700000000, 0)
+	b := NewCircuitBreaker(next, 3, time.Minute, slog.Default())
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Failures below the threshold are passed through.
+	for i := 0; i < 3; i++ {
+		if _, _, err := b.GetUser(ctx, testToken); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: circuit opened before the threshold", i+1)
+		}
+	}
+	if next.calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", next.calls)
+	}
+
+	// The circuit is now open and fails fast.
+	if err := b.CheckOrgMembership(ctx, testToken, "my-org", "octocat"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if next.calls != 3 {
+		t.Fatalf("expected no call while open, got %d calls", next.calls)
+	}
+
+	// After the cooldown a failed probe re-opens the circuit.
+	now = now.Add(time.Minute)
+	if _, _, err := b.GetUser(ctx, testToken); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("expected a probe call after the cooldown")
+	}
+	if _, _, err := b.GetUser(ctx, testToken); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen after a failed probe, got %v", err)
+	}
+
+	// After another cooldown a successful probe closes the circuit.
+	now = now.Add(time.Minute)
+	next.err = nil
+	if _, _, err := b.GetUser(ctx, testToken); err != nil {
+		t.Fatalf("expected a successful probe, got %v", err)
+	}
+	next.err = errors.New("github: unexpected status 502")
+	if _, err := b.ListUserTeams(ctx, testToken, "my-org"); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("expected the circuit to be closed after a successful probe")
+	}
+}
+
+func TestCircuitBreaker_HalfOpenSingleProbe(t *testing.T) {
+	next := &breakerTestClient{err: errors.New("connection refused")}
+	now := time.Unix(1700000000, 0)
+	b := NewCircuitBreaker(next, 1, time.Minute, slog.Default())
+	b.now = func() time.Time { return now }
+
+	b.GetUser(context.Background(), testToken)
+	now = now.Add(time.Minute)
+
+	// Only one caller may probe while half-open.
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected the first probe to be allowed, got %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen for a concurrent probe, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_IgnoresDefinitiveErrors(t *testing.T) {
+	for _, err := range []error{
+		ErrUnauthorized,
+		ErrForbiddenToken,
+		ErrNotOrgMember,
+		ErrNotTeamMember,
+		ErrRateLimited,
+		context.Canceled,
+	} {
+		t.Run(err.Error(), func(t *testing.T) {
+			next := &breakerTestClient{err: err}
+			b := NewCircuitBreaker(next, 1, time.Minute, slog.Default())
+			for i := 0; i < 3; i++ {
+				if _, _, got := b.GetUser(context.Background(), testToken); !errors.Is(got, err) {
+					t.Fatalf("expected %v, got %v", err, got)
+				}
+			}
+		})
+	}
+}
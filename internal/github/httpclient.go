@@ -5,28 +5,114 @@
 package github
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/andrewkroh/traefik-github-auth/internal/requestid"
+)
+
+const (
+	defaultBaseURL      = "https://api.github.com"
+	acceptHeader        = "application/vnd.github+json"
+	tracerName          = "github.com/andrewkroh/traefik-github-auth/internal/github"
+	meterName           = "github.com/andrewkroh/traefik-github-auth/internal/github"
+	defaultMaxTeamPages = 50
 )
 
+// ClassicPATDetection selects how GetUser decides whether a token is a
+// classic PAT.
+type ClassicPATDetection string
+
 const (
-	defaultBaseURL = "https://api.github.com"
-	acceptHeader   = "application/vnd.github+json"
-	tracerName     = "github.com/andrewkroh/traefik-github-auth/internal/github"
+	// DetectByHeader treats a token as classic when the /user response
+	// includes the X-OAuth-Scopes header.
+	DetectByHeader ClassicPATDetection = "header"
+
+	// DetectByPrefix decides based on the token prefix (ghp_/gho_ are
+	// classic, github_pat_ is fine-grained). Tokens with an unrecognized
+	// prefix fall back to the X-OAuth-Scopes header.
+	DetectByPrefix ClassicPATDetection = "prefix"
+
+	// DetectByAny treats a token as classic when either the header or the
+	// token prefix indicates a classic token, so that a classic PAT is
+	// still detected if a proxy strips the header. This is the default.
+	DetectByAny ClassicPATDetection = "any"
+)
+
+// Token prefixes documented by GitHub.
+var (
+	classicTokenPrefixes     = []string{"ghp_", "gho_"}
+	fineGrainedTokenPrefixes = []string{"github_pat_"}
 )
 
+// ParseClassicPATDetection parses a ClassicPATDetection from its string form.
+func ParseClassicPATDetection(s string) (ClassicPATDetection, error) {
+	switch d := ClassicPATDetection(s); d {
+	case DetectByHeader, DetectByPrefix, DetectByAny:
+		return d, nil
+	default:
+		return "", fmt.Errorf("github: unknown classic PAT detection strategy %q", s)
+	}
+}
+
+// EndpointPaths holds the URL path templates used for each API operation.
+// Templates may reference {org}, {username}, and {team}, which are replaced
+// with the corresponding call arguments. Empty fields use the default
+// GitHub paths.
+type EndpointPaths struct {
+	User           string // Default: /user
+	UserTeams      string // Default: /user/teams
+	OrgMembership  string // Default: /orgs/{org}/members/{username}
+	OrgRole        string // Default: /orgs/{org}/memberships/{username}
+	TeamMembership string // Default: /orgs/{org}/teams/{team}/memberships/{username}
+	RateLimit      string // Default: /rate_limit
+}
+
+// defaultEndpointPaths are the paths used by api.github.com and GHES.
+var defaultEndpointPaths = EndpointPaths{
+	User:           "/user",
+	UserTeams:      "/user/teams",
+	OrgMembership:  "/orgs/{org}/members/{username}",
+	OrgRole:        "/orgs/{org}/memberships/{username}",
+	TeamMembership: "/orgs/{org}/teams/{team}/memberships/{username}",
+	RateLimit:      "/rate_limit",
+}
+
+// expandPath substitutes placeholders in a path template. kv holds
+// alternating placeholder names (without braces) and values. Values are
+// escaped so they always occupy a single path segment.
+func expandPath(tmpl string, kv ...string) string {
+	oldnew := make([]string, 0, len(kv))
+	for i := 0; i+1 < len(kv); i += 2 {
+		oldnew = append(oldnew, "{"+kv[i]+"}", url.PathEscape(kv[i+1]))
+	}
+	return strings.NewReplacer(oldnew...).Replace(tmpl)
+}
+
 // linkNextRE matches the "next" relation in a Link header value.
 var linkNextRE = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
 
@@ -36,6 +122,73 @@ type HTTPClient struct {
 	httpClient *http.Client
 	baseURL    string
 	log        *slog.Logger
+
+	// accept is the Accept header sent with API requests.
+	accept string
+
+	// orgTokens supplies the token for org-scoped calls.
+	orgTokens TokenSource
+
+	// classicDetection selects how classic PATs are detected in GetUser.
+	classicDetection ClassicPATDetection
+
+	// omitEmail discards the profile email returned by GetUser.
+	omitEmail bool
+
+	// maxTeamPages limits how many pages ListUserTeams will follow.
+	maxTeamPages int
+
+	// paths holds the endpoint path templates.
+	paths EndpointPaths
+
+	// membershipRetryDelay, if positive, is the delay before retrying an
+	// org membership check that returned 404.
+	membershipRetryDelay time.Duration
+
+	// requests counts authenticated API requests issued.
+	requests atomic.Int64
+
+	// rateLimitRemaining is the most recently observed
+	// X-RateLimit-Remaining value, or -1 if none has been seen.
+	rateLimitRemaining atomic.Int64
+
+	// requestDuration records the duration of each API request.
+	requestDuration metric.Float64Histogram
+
+	// conditional enables If-None-Match requests for team list pages.
+	conditional bool
+
+	// etagMu guards etags.
+	etagMu sync.Mutex
+
+	// etags holds the last ETag and decoded page for each team list page,
+	// keyed by teamsPageKey.
+	etags map[string]teamsPage
+
+	// etagKey keys the token hash in teamsPageKey. It is random for each
+	// client so that store keys cannot be linked to tokens.
+	etagKey []byte
+}
+
+// teamsPage is a decoded team list page and the ETag it was served with.
+type teamsPage struct {
+	etag  string
+	teams []Team
+	next  string
+}
+
+// maxETagEntries bounds the number of stored team list pages. When it is
+// reached the store is cleared.
+const maxETagEntries = 10000
+
+// Stats holds cumulative API usage counters for an HTTPClient.
+type Stats struct {
+	// Requests is the number of authenticated API requests issued.
+	Requests int64
+
+	// RateLimitRemaining is the most recently observed
+	// X-RateLimit-Remaining value, or -1 if none has been seen.
+	RateLimitRemaining int64
 }
 
 // Option configures an HTTPClient.
@@ -48,6 +201,18 @@ func WithBaseURL(url string) Option {
 	}
 }
 
+// WithAccept sets the Accept header sent with API requests. The default is
+// "application/vnd.github+json"; older GitHub Enterprise Server versions
+// may need "application/vnd.github.v3+json". An empty mediaType is
+// ignored.
+func WithAccept(mediaType string) Option {
+	return func(c *HTTPClient) {
+		if mediaType != "" {
+			c.accept = mediaType
+		}
+	}
+}
+
 // WithHTTPClient sets the underlying HTTP client.
 func WithHTTPClient(hc *http.Client) Option {
 	return func(c *HTTPClient) {
@@ -62,21 +227,191 @@ func WithLogger(l *slog.Logger) Option {
 	}
 }
 
+// WithOrgTokenSource sets the TokenSource used to authenticate the
+// org-scoped calls (CheckOrgMembership, GetOrgMembership, and
+// CheckTeamMembership). GetUser and ListUserTeams act on the authenticated
+// user, so they always use the user's token. By default the user's token
+// is used for every call.
+func WithOrgTokenSource(ts TokenSource) Option {
+	return func(c *HTTPClient) {
+		c.orgTokens = ts
+	}
+}
+
+// WithClassicPATDetection sets the strategy used by GetUser to detect
+// classic PATs. The default is DetectByAny.
+func WithClassicPATDetection(d ClassicPATDetection) Option {
+	return func(c *HTTPClient) {
+		c.classicDetection = d
+	}
+}
+
+// WithoutEmail makes GetUser discard the user's profile email so that it
+// never leaves the client. GET /user always includes the email field and
+// reading the public profile email needs no extra scope, so there is
+// nothing to avoid requesting; the field is blanked right after decoding,
+// before the User is returned, cached, or logged.
+func WithoutEmail() Option {
+	return func(c *HTTPClient) {
+		c.omitEmail = true
+	}
+}
+
+// WithMaxTeamPages sets the maximum number of pages ListUserTeams will
+// follow before giving up with ErrTooManyTeamPages. The default is 50.
+// Values less than 1 are ignored; there is no way to disable the limit.
+func WithMaxTeamPages(n int) Option {
+	return func(c *HTTPClient) {
+		if n > 0 {
+			c.maxTeamPages = n
+		}
+	}
+}
+
+// WithMembershipRetryOn404 retries an org membership check once, after
+// delay, when GitHub responds 404. This avoids spuriously denying users
+// who were just added to the org while GitHub's replicas catch up. Note
+// that genuine non-members pay the delay for each org checked. A delay of
+// 0 disables the retry.
+func WithMembershipRetryOn404(delay time.Duration) Option {
+	return func(c *HTTPClient) {
+		c.membershipRetryDelay = delay
+	}
+}
+
+// WithConditionalRequests enables conditional requests for team list
+// pages. The ETag of each page is stored per URL and token, and sent as
+// If-None-Match on the next request. A 304 Not Modified response, which
+// does not count against the primary rate limit, reuses the stored page.
+func WithConditionalRequests(enabled bool) Option {
+	return func(c *HTTPClient) {
+		c.conditional = enabled
+	}
+}
+
+// WithEndpointPaths overrides the URL path templates for individual API
+// operations. Only non-empty fields of p replace the defaults. This is
+// useful for testing and for proxies or GHES deployments that expose the
+// API under non-standard paths.
+func WithEndpointPaths(p EndpointPaths) Option {
+	return func(c *HTTPClient) {
+		if p.User != "" {
+			c.paths.User = p.User
+		}
+		if p.UserTeams != "" {
+			c.paths.UserTeams = p.UserTeams
+		}
+		if p.OrgMembership != "" {
+			c.paths.OrgMembership = p.OrgMembership
+		}
+		if p.OrgRole != "" {
+			c.paths.OrgRole = p.OrgRole
+		}
+		if p.TeamMembership != "" {
+			c.paths.TeamMembership = p.TeamMembership
+		}
+		if p.RateLimit != "" {
+			c.paths.RateLimit = p.RateLimit
+		}
+	}
+}
+
 // NewHTTPClient creates a new HTTPClient with the given options.
 // By default it uses https://api.github.com as the base URL,
 // http.DefaultClient, and slog.Default() as the logger.
 func NewHTTPClient(opts ...Option) *HTTPClient {
 	c := &HTTPClient{
-		httpClient: http.DefaultClient,
-		baseURL:    defaultBaseURL,
-		log:        slog.Default(),
+		httpClient:       http.DefaultClient,
+		baseURL:          defaultBaseURL,
+		accept:           acceptHeader,
+		log:              slog.Default(),
+		orgTokens:        userTokenSource{},
+		classicDetection: DetectByAny,
+		maxTeamPages:     defaultMaxTeamPages,
+		paths:            defaultEndpointPaths,
 	}
+	c.rateLimitRemaining.Store(-1)
+	c.etags = make(map[string]teamsPage)
+	c.etagKey = make([]byte, 32)
+	rand.Read(c.etagKey)
+	c.requestDuration, _ = otel.Meter(meterName).Float64Histogram("github.api.request.duration",
+		metric.WithDescription("Duration of GitHub API requests"),
+		metric.WithUnit("s"),
+	)
 	for _, opt := range opts {
 		opt(c)
 	}
 	return c
 }
 
+// ProxiedHTTPClient returns a copy of hc that sends requests through the
+// HTTP proxy at proxyURL (e.g. "http://proxy.example.com:3128"), keeping
+// hc's other settings. Pass the result to both WithHTTPClient and
+// NewAppTokenSource so that API calls and installation token minting take
+// the same route. A transport that is not an *http.Transport is replaced by
+// a copy of http.DefaultTransport. Without a proxy client, the HTTPS_PROXY,
+// HTTP_PROXY, and NO_PROXY environment variables are honored.
+func ProxiedHTTPClient(hc *http.Client, proxyURL string) (*http.Client, error) {
+	u, err := url.Parse(proxyURL)
+	if err == nil && (u.Scheme == "" || u.Host == "") {
+		err = fmt.Errorf("missing scheme or host in %q", proxyURL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("github: invalid proxy URL: %w", err)
+	}
+
+	base, ok := hc.Transport.(*http.Transport)
+	if !ok {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	transport := base.Clone()
+	transport.Proxy = http.ProxyURL(u)
+
+	proxied := *hc
+	proxied.Transport = transport
+	return &proxied, nil
+}
+
+// Stats returns the client's cumulative API usage.
+func (c *HTTPClient) Stats() Stats {
+	return Stats{
+		Requests:           c.requests.Load(),
+		RateLimitRemaining: c.rateLimitRemaining.Load(),
+	}
+}
+
+// do sends an authenticated API request, recording it and the rate limit
+// remaining for Stats. The request duration is recorded under operation,
+// which matches the name of the caller's span. Errors sending the request
+// are wrapped by transportError.
+func (c *HTTPClient) do(req *http.Request, operation string) (*http.Response, error) {
+	c.requests.Add(1)
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	attrs := []attribute.KeyValue{attribute.String("operation", operation)}
+	if err == nil {
+		attrs = append(attrs, attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	c.requestDuration.Record(req.Context(), time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+	if err != nil {
+		return nil, transportError(req.Context(), err)
+	}
+	if n, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Remaining"), 10, 64); err == nil {
+		c.rateLimitRemaining.Store(n)
+	}
+	return resp, nil
+}
+
+// transportError wraps an error from sending a request to GitHub. Unless
+// the request's context has ended, GitHub could not be reached and the
+// error wraps ErrUpstreamUnavailable.
+func transportError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("github: executing request: %w", err)
+	}
+	return fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+}
+
 // tracer returns the OTel tracer for this package.
 func (c *HTTPClient) tracer() trace.Tracer {
 	return otel.Tracer(tracerName)
@@ -91,32 +426,116 @@ func (c *HTTPClient) newRequest(ctx context.Context, method, url string) (*http.
 	return req, nil
 }
 
-// setHeaders sets the standard GitHub API headers on a request.
-func setHeaders(req *http.Request, token string) {
+// setHeaders sets the standard GitHub API headers on a request, plus the
+// request ID from its context, if any.
+func setHeaders(req *http.Request, token, accept string) {
 	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", acceptHeader)
+	req.Header.Set("Accept", accept)
+	if id := requestid.FromContext(req.Context()); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+}
+
+// githubRequestIDHeader is the response header identifying a request to
+// GitHub support.
+const githubRequestIDHeader = "X-GitHub-Request-Id"
+
+// withRequestID annotates err with the X-GitHub-Request-Id of resp, if
+// present, so that failures can be traced by GitHub support.
+func withRequestID(err error, resp *http.Response) error {
+	if id := resp.Header.Get(githubRequestIDHeader); id != "" {
+		return fmt.Errorf("%w (github request id %s)", err, id)
+	}
+	return err
+}
+
+// requestIDAttr returns the X-GitHub-Request-Id of resp as a log attribute.
+func requestIDAttr(resp *http.Response) slog.Attr {
+	return slog.String("github.request_id", resp.Header.Get(githubRequestIDHeader))
 }
 
 // checkRateLimit inspects the response for GitHub rate limit exhaustion.
-// Returns ErrRateLimited if HTTP 429 or X-RateLimit-Remaining is "0".
+// Returns ErrRateLimited if HTTP 429, X-RateLimit-Remaining is "0", or the
+// response is a 403 secondary rate limit.
 func checkRateLimit(resp *http.Response) error {
 	if resp.StatusCode == http.StatusTooManyRequests {
 		return ErrRateLimited
 	}
-	remaining := resp.Header.Get("X-RateLimit-Remaining")
-	if remaining == "" {
-		return nil
+	if n, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil && n == 0 {
+		return ErrRateLimited
+	}
+	if resp.StatusCode == http.StatusForbidden && isSecondaryRateLimit(resp) {
+		return ErrRateLimited
 	}
-	n, err := strconv.Atoi(remaining)
+	return nil
+}
+
+// forbiddenPeek bounds how much of a 403 response body is inspected for a
+// secondary rate limit or token expiration message.
+const forbiddenPeek = 4096
+
+// peekBody returns up to forbiddenPeek bytes from the start of the
+// response body and restores them so that the body can still be read in
+// full by the caller.
+func peekBody(resp *http.Response) []byte {
+	peek, _ := io.ReadAll(io.LimitReader(resp.Body, forbiddenPeek))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peek), resp.Body), resp.Body}
+	return peek
+}
+
+// errorBodyLimit bounds how much of an unexpected response body is read
+// into an error message.
+const errorBodyLimit = 4096
+
+// errorBody returns up to errorBodyLimit bytes of the response body, with
+// surrounding whitespace trimmed, for use in an error message.
+func errorBody(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, errorBodyLimit))
+	return strings.TrimSpace(string(body))
+}
+
+// checkJSON returns an error if a successful response is an HTML page
+// rather than JSON, as happens when a proxy or captive portal in front of
+// GitHub answers with its own 200 page. A missing or otherwise unexpected
+// Content-Type is tolerated so that decoding can report the real problem.
+func checkJSON(resp *http.Response) error {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
 	if err != nil {
 		return nil
 	}
-	if n == 0 {
-		return ErrRateLimited
+	if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		return fmt.Errorf("%w: expected JSON, got %s", ErrBadUpstreamResponse, mediaType)
 	}
 	return nil
 }
 
+// isSecondaryRateLimit reports whether a 403 response is a secondary
+// (abuse) rate limit. GitHub signals these with a Retry-After header or a
+// message mentioning "secondary rate limit" rather than by exhausting
+// X-RateLimit-Remaining.
+func isSecondaryRateLimit(resp *http.Response) bool {
+	if resp.Header.Get("Retry-After") != "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(string(peekBody(resp))), "secondary rate limit")
+}
+
+// isTokenExpired reports whether a 403 response is due to the token having
+// expired, as signaled by a past GitHub-Authentication-Token-Expiration
+// header. The message body is not consulted because its wording is not
+// stable and unrelated 403s may mention expiry.
+func isTokenExpired(resp *http.Response) bool {
+	v := resp.Header.Get("GitHub-Authentication-Token-Expiration")
+	if v == "" {
+		return false
+	}
+	t, err := parseTokenExpiration(v)
+	return err == nil && !t.After(time.Now())
+}
+
 // GetUser retrieves the authenticated user's profile.
 // Returns the user and whether the response included X-OAuth-Scopes header
 // (which indicates a classic PAT rather than a fine-grained PAT).
@@ -124,7 +543,7 @@ func (c *HTTPClient) GetUser(ctx context.Context, token string) (*User, bool, er
 	ctx, span := c.tracer().Start(ctx, "github.get_user")
 	defer span.End()
 
-	urlPath := "/user"
+	urlPath := c.paths.User
 	fullURL := c.baseURL + urlPath
 
 	span.SetAttributes(
@@ -139,14 +558,14 @@ func (c *HTTPClient) GetUser(ctx context.Context, token string) (*User, bool, er
 		c.log.ErrorContext(ctx, "failed to create request", slog.String("method", "GetUser"), slog.String("error", err.Error()))
 		return nil, false, fmt.Errorf("github: creating request: %w", err)
 	}
-	setHeaders(req, token)
+	setHeaders(req, token, c.accept)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req, "github.get_user")
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		c.log.ErrorContext(ctx, "request failed", slog.String("method", "GetUser"), slog.String("error", err.Error()))
-		return nil, false, fmt.Errorf("github: executing request: %w", err)
+		return nil, false, err
 	}
 	defer resp.Body.Close()
 
@@ -154,26 +573,47 @@ func (c *HTTPClient) GetUser(ctx context.Context, token string) (*User, bool, er
 
 	// Check for rate limiting before other status checks.
 	if err := checkRateLimit(resp); err != nil {
-		c.log.WarnContext(ctx, "rate limited by GitHub API", slog.String("method", "GetUser"))
+		c.log.WarnContext(ctx, "rate limited by GitHub API", slog.String("method", "GetUser"), requestIDAttr(resp))
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return nil, false, err
+		return nil, false, withRequestID(err, resp)
 	}
 
 	switch {
 	case resp.StatusCode == http.StatusUnauthorized:
-		c.log.WarnContext(ctx, "unauthorized token", slog.String("method", "GetUser"))
+		c.log.WarnContext(ctx, "unauthorized token", slog.String("method", "GetUser"), requestIDAttr(resp))
 		span.RecordError(ErrUnauthorized)
 		span.SetStatus(codes.Error, ErrUnauthorized.Error())
-		return nil, false, ErrUnauthorized
+		return nil, false, withRequestID(ErrUnauthorized, resp)
+
+	case resp.StatusCode == http.StatusForbidden && isTokenExpired(resp):
+		c.log.WarnContext(ctx, "expired token", slog.String("method", "GetUser"), requestIDAttr(resp))
+		span.RecordError(ErrTokenExpired)
+		span.SetStatus(codes.Error, ErrTokenExpired.Error())
+		return nil, false, withRequestID(ErrTokenExpired, resp)
+
+	case resp.StatusCode == http.StatusForbidden:
+		// Rate limiting and expiration were ruled out above, so the token
+		// itself lacks access (e.g. a fine-grained PAT on some GHES
+		// configurations).
+		c.log.WarnContext(ctx, "forbidden token", slog.String("method", "GetUser"), requestIDAttr(resp))
+		span.RecordError(ErrForbiddenToken)
+		span.SetStatus(codes.Error, ErrForbiddenToken.Error())
+		return nil, false, withRequestID(ErrForbiddenToken, resp)
 
 	case resp.StatusCode < 200 || resp.StatusCode >= 300:
-		body, _ := io.ReadAll(resp.Body)
-		err := fmt.Errorf("github: unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
-		c.log.ErrorContext(ctx, "unexpected response", slog.String("method", "GetUser"), slog.Int("status", resp.StatusCode))
+		err := fmt.Errorf("github: unexpected status %d: %s", resp.StatusCode, errorBody(resp))
+		c.log.ErrorContext(ctx, "unexpected response", slog.String("method", "GetUser"), slog.Int("status", resp.StatusCode), requestIDAttr(resp))
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return nil, false, err
+		return nil, false, withRequestID(err, resp)
+	}
+
+	if err := checkJSON(resp); err != nil {
+		c.log.ErrorContext(ctx, "unexpected content type", slog.String("method", "GetUser"), slog.String("error", err.Error()), requestIDAttr(resp))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, false, withRequestID(err, resp)
 	}
 
 	var user User
@@ -181,23 +621,154 @@ func (c *HTTPClient) GetUser(ctx context.Context, token string) (*User, bool, er
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		c.log.ErrorContext(ctx, "failed to decode response", slog.String("method", "GetUser"), slog.String("error", err.Error()))
-		return nil, false, fmt.Errorf("github: decoding user response: %w", err)
+		return nil, false, fmt.Errorf("%w: decoding user response: %w", ErrBadUpstreamResponse, err)
+	}
+	if c.omitEmail {
+		user.Email = ""
 	}
 
-	// X-OAuth-Scopes is present for classic PATs but absent for fine-grained PATs.
-	isClassicPAT := resp.Header.Get("X-OAuth-Scopes") != ""
+	isClassicPAT := c.isClassicPAT(token, resp)
+
+	if v := resp.Header.Get("GitHub-Authentication-Token-Expiration"); v != "" {
+		expiresAt, err := parseTokenExpiration(v)
+		if err != nil {
+			c.log.WarnContext(ctx, "ignoring invalid token expiration header", slog.String("method", "GetUser"), slog.String("value", v))
+		} else {
+			user.TokenExpiresAt = expiresAt
+			span.SetAttributes(attribute.String("github.token.expires_at", expiresAt.Format(time.RFC3339)))
+		}
+	}
 
 	c.log.InfoContext(ctx, "fetched user", slog.String("login", user.Login), slog.Int64("id", user.ID), slog.Bool("is_classic_pat", isClassicPAT))
 	return &user, isClassicPAT, nil
 }
 
+// tokenExpirationLayouts are the formats GitHub uses for the
+// GitHub-Authentication-Token-Expiration header, e.g.
+// "2024-06-30 23:59:59 UTC" or "2024-06-30 16:59:59 -0700".
+var tokenExpirationLayouts = []string{
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+}
+
+// parseTokenExpiration parses a GitHub-Authentication-Token-Expiration
+// header value.
+func parseTokenExpiration(v string) (time.Time, error) {
+	var err error
+	for _, layout := range tokenExpirationLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, strings.TrimSpace(v)); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// isClassicPAT applies the configured detection strategy to the token and
+// the /user response.
+func (c *HTTPClient) isClassicPAT(token string, resp *http.Response) bool {
+	// X-OAuth-Scopes is present for classic PATs but absent for fine-grained PATs.
+	byHeader := resp.Header.Get("X-OAuth-Scopes") != ""
+
+	switch c.classicDetection {
+	case DetectByPrefix:
+		switch {
+		case hasAnyPrefix(token, classicTokenPrefixes):
+			return true
+		case hasAnyPrefix(token, fineGrainedTokenPrefixes):
+			return false
+		default:
+			return byHeader
+		}
+	case DetectByAny:
+		return byHeader || hasAnyPrefix(token, classicTokenPrefixes)
+	default:
+		return byHeader
+	}
+}
+
+// hasAnyPrefix reports whether s begins with any of the given prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ping checks that the GitHub API is reachable by making an
+// unauthenticated request to the rate limit endpoint, which does not count
+// against the rate limit. Any response below 500 is treated as reachable.
+func (c *HTTPClient) Ping(ctx context.Context) error {
+	ctx, span := c.tracer().Start(ctx, "github.ping")
+	defer span.End()
+
+	urlPath := c.paths.RateLimit
+	span.SetAttributes(
+		attribute.String("http.request.method", "GET"),
+		attribute.String("url.path", urlPath),
+	)
+
+	req, err := c.newRequest(ctx, http.MethodGet, c.baseURL+urlPath)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("github: creating request: %w", err)
+	}
+	req.Header.Set("Accept", c.accept)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("github: executing request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	if resp.StatusCode >= 500 {
+		err := fmt.Errorf("github: unexpected status %d", resp.StatusCode)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
 // CheckOrgMembership checks if the user is a member of the given org.
 // Returns nil if the user is a member (HTTP 204), ErrNotOrgMember if not (HTTP 404).
+// If a membership retry delay is configured, a 404 is retried once after
+// the delay.
 func (c *HTTPClient) CheckOrgMembership(ctx context.Context, token, org, username string) error {
+	err := c.checkOrgMembership(ctx, token, org, username)
+	if c.membershipRetryDelay <= 0 || !errors.Is(err, ErrNotOrgMember) {
+		return err
+	}
+
+	c.log.DebugContext(ctx, "retrying org membership check after 404",
+		slog.String("org", org),
+		slog.String("username", username),
+		slog.Duration("delay", c.membershipRetryDelay),
+	)
+	t := time.NewTimer(c.membershipRetryDelay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+	return c.checkOrgMembership(ctx, token, org, username)
+}
+
+// checkOrgMembership makes a single org membership request.
+func (c *HTTPClient) checkOrgMembership(ctx context.Context, token, org, username string) error {
 	ctx, span := c.tracer().Start(ctx, "github.check_org_membership")
 	defer span.End()
 
-	urlPath := fmt.Sprintf("/orgs/%s/members/%s", org, username)
+	urlPath := expandPath(c.paths.OrgMembership, "org", org, "username", username)
 	fullURL := c.baseURL + urlPath
 
 	span.SetAttributes(
@@ -205,6 +776,14 @@ func (c *HTTPClient) CheckOrgMembership(ctx context.Context, token, org, usernam
 		attribute.String("url.path", urlPath),
 	)
 
+	authToken, err := c.orgTokens.Token(ctx, token)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.log.ErrorContext(ctx, "failed to obtain token", slog.String("method", "CheckOrgMembership"), slog.String("error", err.Error()))
+		return fmt.Errorf("github: obtaining token: %w", err)
+	}
+
 	req, err := c.newRequest(ctx, http.MethodGet, fullURL)
 	if err != nil {
 		span.RecordError(err)
@@ -212,14 +791,14 @@ func (c *HTTPClient) CheckOrgMembership(ctx context.Context, token, org, usernam
 		c.log.ErrorContext(ctx, "failed to create request", slog.String("method", "CheckOrgMembership"), slog.String("error", err.Error()))
 		return fmt.Errorf("github: creating request: %w", err)
 	}
-	setHeaders(req, token)
+	setHeaders(req, authToken, c.accept)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req, "github.check_org_membership")
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		c.log.ErrorContext(ctx, "request failed", slog.String("method", "CheckOrgMembership"), slog.String("error", err.Error()))
-		return fmt.Errorf("github: executing request: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
@@ -227,10 +806,10 @@ func (c *HTTPClient) CheckOrgMembership(ctx context.Context, token, org, usernam
 
 	// Check for rate limiting before other status checks.
 	if err := checkRateLimit(resp); err != nil {
-		c.log.WarnContext(ctx, "rate limited by GitHub API", slog.String("method", "CheckOrgMembership"))
+		c.log.WarnContext(ctx, "rate limited by GitHub API", slog.String("method", "CheckOrgMembership"), requestIDAttr(resp))
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return err
+		return withRequestID(err, resp)
 	}
 
 	switch resp.StatusCode {
@@ -239,43 +818,241 @@ func (c *HTTPClient) CheckOrgMembership(ctx context.Context, token, org, usernam
 		return nil
 
 	case http.StatusNotFound:
-		c.log.WarnContext(ctx, "user is not org member", slog.String("org", org), slog.String("username", username))
+		c.log.WarnContext(ctx, "user is not org member", slog.String("org", org), slog.String("username", username), requestIDAttr(resp))
 		span.RecordError(ErrNotOrgMember)
 		span.SetStatus(codes.Error, ErrNotOrgMember.Error())
-		return ErrNotOrgMember
+		return withRequestID(ErrNotOrgMember, resp)
 
 	case http.StatusUnauthorized:
-		c.log.WarnContext(ctx, "unauthorized token", slog.String("method", "CheckOrgMembership"))
+		c.log.WarnContext(ctx, "unauthorized token", slog.String("method", "CheckOrgMembership"), requestIDAttr(resp))
 		span.RecordError(ErrUnauthorized)
 		span.SetStatus(codes.Error, ErrUnauthorized.Error())
-		return ErrUnauthorized
+		return withRequestID(ErrUnauthorized, resp)
 
 	default:
-		body, _ := io.ReadAll(resp.Body)
-		err := fmt.Errorf("github: unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
-		c.log.ErrorContext(ctx, "unexpected response", slog.String("method", "CheckOrgMembership"), slog.Int("status", resp.StatusCode))
+		err := fmt.Errorf("github: unexpected status %d: %s", resp.StatusCode, errorBody(resp))
+		c.log.ErrorContext(ctx, "unexpected response", slog.String("method", "CheckOrgMembership"), slog.Int("status", resp.StatusCode), requestIDAttr(resp))
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return err
+		return withRequestID(err, resp)
 	}
 }
 
+// GetOrgMembership retrieves the user's membership in the given org,
+// including their role. Returns ErrNotOrgMember if the user is not a
+// member (HTTP 404).
+func (c *HTTPClient) GetOrgMembership(ctx context.Context, token, org, username string) (*OrgMembership, error) {
+	ctx, span := c.tracer().Start(ctx, "github.get_org_membership")
+	defer span.End()
+
+	urlPath := expandPath(c.paths.OrgRole, "org", org, "username", username)
+	fullURL := c.baseURL + urlPath
+
+	span.SetAttributes(
+		attribute.String("http.request.method", "GET"),
+		attribute.String("url.path", urlPath),
+	)
+
+	authToken, err := c.orgTokens.Token(ctx, token)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.log.ErrorContext(ctx, "failed to obtain token", slog.String("method", "GetOrgMembership"), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("github: obtaining token: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, fullURL)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.log.ErrorContext(ctx, "failed to create request", slog.String("method", "GetOrgMembership"), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("github: creating request: %w", err)
+	}
+	setHeaders(req, authToken, c.accept)
+
+	resp, err := c.do(req, "github.get_org_membership")
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.log.ErrorContext(ctx, "request failed", slog.String("method", "GetOrgMembership"), slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	// Check for rate limiting before other status checks.
+	if err := checkRateLimit(resp); err != nil {
+		c.log.WarnContext(ctx, "rate limited by GitHub API", slog.String("method", "GetOrgMembership"), requestIDAttr(resp))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, withRequestID(err, resp)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		c.log.WarnContext(ctx, "user is not org member", slog.String("org", org), slog.String("username", username), requestIDAttr(resp))
+		span.RecordError(ErrNotOrgMember)
+		span.SetStatus(codes.Error, ErrNotOrgMember.Error())
+		return nil, withRequestID(ErrNotOrgMember, resp)
+
+	case resp.StatusCode == http.StatusUnauthorized:
+		c.log.WarnContext(ctx, "unauthorized token", slog.String("method", "GetOrgMembership"), requestIDAttr(resp))
+		span.RecordError(ErrUnauthorized)
+		span.SetStatus(codes.Error, ErrUnauthorized.Error())
+		return nil, withRequestID(ErrUnauthorized, resp)
+
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		err := fmt.Errorf("github: unexpected status %d: %s", resp.StatusCode, errorBody(resp))
+		c.log.ErrorContext(ctx, "unexpected response", slog.String("method", "GetOrgMembership"), slog.Int("status", resp.StatusCode), requestIDAttr(resp))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, withRequestID(err, resp)
+	}
+
+	if err := checkJSON(resp); err != nil {
+		c.log.ErrorContext(ctx, "unexpected content type", slog.String("method", "GetOrgMembership"), slog.String("error", err.Error()), requestIDAttr(resp))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, withRequestID(err, resp)
+	}
+
+	var membership OrgMembership
+	if err := json.NewDecoder(resp.Body).Decode(&membership); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.log.ErrorContext(ctx, "failed to decode response", slog.String("method", "GetOrgMembership"), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%w: decoding org membership response: %w", ErrBadUpstreamResponse, err)
+	}
+
+	c.log.InfoContext(ctx, "fetched org membership",
+		slog.String("org", org),
+		slog.String("username", username),
+		slog.String("role", membership.Role),
+		slog.String("state", membership.State),
+	)
+	return &membership, nil
+}
+
+// CheckTeamMembership retrieves the user's membership in a single team.
+// Returns the membership (whose State may be active or pending), or
+// ErrNotTeamMember if the user is not a member (HTTP 404).
+func (c *HTTPClient) CheckTeamMembership(ctx context.Context, token, org, teamSlug, username string) (*TeamMembership, error) {
+	ctx, span := c.tracer().Start(ctx, "github.check_team_membership")
+	defer span.End()
+
+	urlPath := expandPath(c.paths.TeamMembership, "org", org, "team", teamSlug, "username", username)
+	fullURL := c.baseURL + urlPath
+
+	span.SetAttributes(
+		attribute.String("http.request.method", "GET"),
+		attribute.String("url.path", urlPath),
+	)
+
+	authToken, err := c.orgTokens.Token(ctx, token)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.log.ErrorContext(ctx, "failed to obtain token", slog.String("method", "CheckTeamMembership"), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("github: obtaining token: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, fullURL)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.log.ErrorContext(ctx, "failed to create request", slog.String("method", "CheckTeamMembership"), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("github: creating request: %w", err)
+	}
+	setHeaders(req, authToken, c.accept)
+
+	resp, err := c.do(req, "github.check_team_membership")
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.log.ErrorContext(ctx, "request failed", slog.String("method", "CheckTeamMembership"), slog.String("error", err.Error()))
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	// Check for rate limiting before other status checks.
+	if err := checkRateLimit(resp); err != nil {
+		c.log.WarnContext(ctx, "rate limited by GitHub API", slog.String("method", "CheckTeamMembership"), requestIDAttr(resp))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, withRequestID(err, resp)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		c.log.InfoContext(ctx, "user is not team member", slog.String("org", org), slog.String("team", teamSlug), slog.String("username", username), requestIDAttr(resp))
+		return nil, withRequestID(ErrNotTeamMember, resp)
+
+	case resp.StatusCode == http.StatusUnauthorized:
+		c.log.WarnContext(ctx, "unauthorized token", slog.String("method", "CheckTeamMembership"), requestIDAttr(resp))
+		span.RecordError(ErrUnauthorized)
+		span.SetStatus(codes.Error, ErrUnauthorized.Error())
+		return nil, withRequestID(ErrUnauthorized, resp)
+
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		err := fmt.Errorf("github: unexpected status %d: %s", resp.StatusCode, errorBody(resp))
+		c.log.ErrorContext(ctx, "unexpected response", slog.String("method", "CheckTeamMembership"), slog.Int("status", resp.StatusCode), requestIDAttr(resp))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, withRequestID(err, resp)
+	}
+
+	if err := checkJSON(resp); err != nil {
+		c.log.ErrorContext(ctx, "unexpected content type", slog.String("method", "CheckTeamMembership"), slog.String("error", err.Error()), requestIDAttr(resp))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, withRequestID(err, resp)
+	}
+
+	var membership TeamMembership
+	if err := json.NewDecoder(resp.Body).Decode(&membership); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.log.ErrorContext(ctx, "failed to decode response", slog.String("method", "CheckTeamMembership"), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%w: decoding team membership response: %w", ErrBadUpstreamResponse, err)
+	}
+
+	c.log.InfoContext(ctx, "fetched team membership",
+		slog.String("org", org),
+		slog.String("team", teamSlug),
+		slog.String("username", username),
+		slog.String("state", membership.State),
+	)
+	return &membership, nil
+}
+
 // ListUserTeams lists teams for the authenticated user, filtered to the given org.
 func (c *HTTPClient) ListUserTeams(ctx context.Context, token, org string) ([]Team, error) {
 	ctx, span := c.tracer().Start(ctx, "github.list_user_teams")
 	defer span.End()
 
-	urlPath := "/user/teams"
+	urlPath := c.paths.UserTeams
 
 	span.SetAttributes(
 		attribute.String("http.request.method", "GET"),
 		attribute.String("url.path", urlPath),
 	)
 
+	// /user/teams describes the token's user, so it must be called with
+	// the user's token even when org calls use an installation token.
 	var allTeams []Team
 	nextURL := c.baseURL + urlPath + "?per_page=100"
 
-	for nextURL != "" {
+	for page := 0; nextURL != ""; page++ {
+		if page >= c.maxTeamPages {
+			c.log.ErrorContext(ctx, "too many team pages", slog.String("method", "ListUserTeams"), slog.Int("max_pages", c.maxTeamPages))
+			span.RecordError(ErrTooManyTeamPages)
+			span.SetStatus(codes.Error, ErrTooManyTeamPages.Error())
+			return nil, ErrTooManyTeamPages
+		}
+
 		teams, next, err := c.fetchTeamsPage(ctx, token, nextURL)
 		if err != nil {
 			span.RecordError(err)
@@ -294,6 +1071,15 @@ func (c *HTTPClient) ListUserTeams(ctx context.Context, token, org string) ([]Te
 		}
 	}
 
+	// Teams in other orgs are expected when a user belongs to several orgs,
+	// but a large count may indicate a misconfigured org list.
+	if discarded := len(allTeams) - len(filtered); discarded > 0 {
+		c.log.DebugContext(ctx, "discarded teams from other orgs",
+			slog.String("org", org),
+			slog.Int("discarded_teams", discarded),
+		)
+	}
+
 	c.log.InfoContext(ctx, "listed user teams",
 		slog.String("org", org),
 		slog.Int("total_teams", len(allTeams)),
@@ -311,45 +1097,98 @@ func (c *HTTPClient) fetchTeamsPage(ctx context.Context, token, url string) ([]T
 		c.log.ErrorContext(ctx, "failed to create request", slog.String("method", "ListUserTeams"), slog.String("error", err.Error()))
 		return nil, "", fmt.Errorf("github: creating request: %w", err)
 	}
-	setHeaders(req, token)
+	setHeaders(req, token, c.accept)
 
-	resp, err := c.httpClient.Do(req)
+	var (
+		key    string
+		stored teamsPage
+		ok     bool
+	)
+	if c.conditional {
+		key = c.teamsPageKey(url, token)
+		if stored, ok = c.loadTeamsPage(key); ok {
+			req.Header.Set("If-None-Match", stored.etag)
+		}
+	}
+
+	resp, err := c.do(req, "github.list_user_teams")
 	if err != nil {
 		c.log.ErrorContext(ctx, "request failed", slog.String("method", "ListUserTeams"), slog.String("error", err.Error()))
-		return nil, "", fmt.Errorf("github: executing request: %w", err)
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 
+	// The page is unchanged since it was stored.
+	if ok && resp.StatusCode == http.StatusNotModified {
+		c.log.DebugContext(ctx, "team list page not modified", slog.String("method", "ListUserTeams"))
+		return stored.teams, stored.next, nil
+	}
+
 	// Check for rate limiting before other status checks.
 	if err := checkRateLimit(resp); err != nil {
-		c.log.WarnContext(ctx, "rate limited by GitHub API", slog.String("method", "ListUserTeams"))
-		return nil, "", err
+		c.log.WarnContext(ctx, "rate limited by GitHub API", slog.String("method", "ListUserTeams"), requestIDAttr(resp))
+		return nil, "", withRequestID(err, resp)
 	}
 
 	switch {
 	case resp.StatusCode == http.StatusUnauthorized:
-		c.log.WarnContext(ctx, "unauthorized token", slog.String("method", "ListUserTeams"))
-		return nil, "", ErrUnauthorized
+		c.log.WarnContext(ctx, "unauthorized token", slog.String("method", "ListUserTeams"), requestIDAttr(resp))
+		return nil, "", withRequestID(ErrUnauthorized, resp)
 
 	case resp.StatusCode < 200 || resp.StatusCode >= 300:
-		body, _ := io.ReadAll(resp.Body)
-		err := fmt.Errorf("github: unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
-		c.log.ErrorContext(ctx, "unexpected response", slog.String("method", "ListUserTeams"), slog.Int("status", resp.StatusCode))
-		return nil, "", err
+		err := fmt.Errorf("github: unexpected status %d: %s", resp.StatusCode, errorBody(resp))
+		c.log.ErrorContext(ctx, "unexpected response", slog.String("method", "ListUserTeams"), slog.Int("status", resp.StatusCode), requestIDAttr(resp))
+		return nil, "", withRequestID(err, resp)
+	}
+
+	if err := checkJSON(resp); err != nil {
+		c.log.ErrorContext(ctx, "unexpected content type", slog.String("method", "ListUserTeams"), slog.String("error", err.Error()), requestIDAttr(resp))
+		return nil, "", withRequestID(err, resp)
 	}
 
 	var teams []Team
 	if err := json.NewDecoder(resp.Body).Decode(&teams); err != nil {
 		c.log.ErrorContext(ctx, "failed to decode response", slog.String("method", "ListUserTeams"), slog.String("error", err.Error()))
-		return nil, "", fmt.Errorf("github: decoding teams response: %w", err)
+		return nil, "", fmt.Errorf("%w: decoding teams response: %w", ErrBadUpstreamResponse, err)
 	}
 
 	// Parse Link header for pagination.
 	nextURL := parseLinkNext(resp.Header.Get("Link"))
 
+	if etag := resp.Header.Get("ETag"); c.conditional && etag != "" {
+		c.storeTeamsPage(key, teamsPage{etag: etag, teams: teams, next: nextURL})
+	}
+
 	return teams, nextURL, nil
 }
 
+// teamsPageKey returns the ETag store key for a team list page requested
+// with token. The token is hashed with an HMAC keyed by etagKey; the raw
+// token is never stored.
+func (c *HTTPClient) teamsPageKey(url, token string) string {
+	m := hmac.New(sha256.New, c.etagKey)
+	m.Write([]byte(token))
+	return url + " " + hex.EncodeToString(m.Sum(nil))
+}
+
+// loadTeamsPage returns the stored team list page for key.
+func (c *HTTPClient) loadTeamsPage(key string) (teamsPage, bool) {
+	c.etagMu.Lock()
+	defer c.etagMu.Unlock()
+	p, ok := c.etags[key]
+	return p, ok
+}
+
+// storeTeamsPage stores a team list page under key.
+func (c *HTTPClient) storeTeamsPage(key string, p teamsPage) {
+	c.etagMu.Lock()
+	defer c.etagMu.Unlock()
+	if _, exists := c.etags[key]; !exists && len(c.etags) >= maxETagEntries {
+		clear(c.etags)
+	}
+	c.etags[key] = p
+}
+
 // parseLinkNext extracts the URL for the "next" relation from a Link header.
 // Returns "" if no "next" relation is found.
 func parseLinkNext(header string) string {
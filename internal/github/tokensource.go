@@ -0,0 +1,205 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies the token used to authenticate a GitHub API request.
+// It receives the token presented by the end user so that implementations
+// may choose to pass it through unchanged.
+type TokenSource interface {
+	Token(ctx context.Context, userToken string) (string, error)
+}
+
+// userTokenSource is the default TokenSource. It returns the user's token.
+type userTokenSource struct{}
+
+// Token returns userToken unchanged.
+func (userTokenSource) Token(_ context.Context, userToken string) (string, error) {
+	return userToken, nil
+}
+
+const (
+	// appJWTLifetime is the validity period of the JWT used to authenticate
+	// as the GitHub App. GitHub allows at most 10 minutes.
+	appJWTLifetime = 9 * time.Minute
+
+	// appJWTClockSkew is subtracted from the JWT issued-at time to allow
+	// for clock drift between this host and GitHub.
+	appJWTClockSkew = 60 * time.Second
+
+	// installationTokenRefreshMargin is how long before expiry a cached
+	// installation token is considered stale and re-minted.
+	installationTokenRefreshMargin = 5 * time.Minute
+)
+
+// AppTokenSource is a TokenSource that ignores the user's token and instead
+// returns a GitHub App installation token. Installation tokens are minted
+// on demand and cached until shortly before they expire.
+type AppTokenSource struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	baseURL        string
+	httpClient     *http.Client
+	now            func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewAppTokenSource creates an AppTokenSource for the given GitHub App and
+// installation. If baseURL is empty, https://api.github.com is used. If hc
+// is nil, http.DefaultClient is used.
+func NewAppTokenSource(appID, installationID int64, key *rsa.PrivateKey, baseURL string, hc *http.Client) *AppTokenSource {
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &AppTokenSource{
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		httpClient:     hc,
+		now:            time.Now,
+	}
+}
+
+// ParsePrivateKey parses a PEM-encoded RSA private key as downloaded from
+// the GitHub App settings page. Both PKCS#1 and PKCS#8 encodings are accepted.
+func ParsePrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("github: no PEM data found in private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("github: parsing private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("github: private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// Token returns a valid installation token, minting a new one if the cached
+// token is missing or close to expiry. The user's token is ignored.
+func (s *AppTokenSource) Token(ctx context.Context, _ string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && s.now().Add(installationTokenRefreshMargin).Before(s.expiresAt) {
+		return s.token, nil
+	}
+
+	token, expiresAt, err := s.mintInstallationToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	s.expiresAt = expiresAt
+	return token, nil
+}
+
+// mintInstallationToken exchanges an app JWT for an installation token.
+func (s *AppTokenSource) mintInstallationToken(ctx context.Context) (string, time.Time, error) {
+	jwt, err := s.appJWT()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", s.baseURL, s.installationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("github: creating request: %w", err)
+	}
+	setHeaders(req, jwt, acceptHeader)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkRateLimit(resp); err != nil {
+		return "", time.Time{}, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return "", time.Time{}, fmt.Errorf("github: minting installation token: unexpected status %d: %s", resp.StatusCode, errorBody(resp))
+	}
+
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", time.Time{}, fmt.Errorf("github: decoding installation token response: %w", err)
+	}
+	if body.Token == "" {
+		return "", time.Time{}, errors.New("github: installation token response did not contain a token")
+	}
+	return body.Token, body.ExpiresAt, nil
+}
+
+// appJWT builds and signs the RS256 JWT used to authenticate as the app.
+func (s *AppTokenSource) appJWT() (string, error) {
+	now := s.now()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-appJWTClockSkew).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(s.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(header))
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(claims))
+
+	digest := sha256.Sum256(buf.Bytes())
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("github: signing app JWT: %w", err)
+	}
+
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(sig))
+	return buf.String(), nil
+}
@@ -5,15 +5,36 @@
 // Package github provides types and a client for interacting with the GitHub API.
 package github
 
+import "time"
+
 // User represents a GitHub user profile.
 type User struct {
 	Login string `json:"login"`
 	ID    int64  `json:"id"`
+
+	// Email is the user's public profile email. It is empty if the user
+	// has not made an email address public.
+	Email string `json:"email"`
+
+	// NodeID is the user's global GraphQL node ID.
+	NodeID string `json:"node_id"`
+
+	// AvatarURL is the URL of the user's avatar image.
+	AvatarURL string `json:"avatar_url"`
+
+	// TokenExpiresAt is when the token used to fetch the profile expires,
+	// from the GitHub-Authentication-Token-Expiration response header. It
+	// is zero if the token does not expire or the header was absent.
+	TokenExpiresAt time.Time `json:"-"`
 }
 
 // Team represents a GitHub team.
 type Team struct {
-	Slug         string       `json:"slug"`
+	Slug string `json:"slug"`
+
+	// Name is the team's display name, e.g. "Platform Team" for slug
+	// "platform-team".
+	Name         string       `json:"name"`
 	Organization Organization `json:"organization"`
 }
 
@@ -21,3 +42,33 @@ type Team struct {
 type Organization struct {
 	Login string `json:"login"`
 }
+
+// OrgMembership represents a user's membership in an organization.
+type OrgMembership struct {
+	// Role is "admin" for organization owners and "member" otherwise.
+	Role string `json:"role"`
+
+	// State is "active" or "pending" (invited but not yet accepted).
+	State string `json:"state"`
+}
+
+// Organization membership roles.
+const (
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// TeamMembership represents a user's membership in a team.
+type TeamMembership struct {
+	// Role is "member" or "maintainer".
+	Role string `json:"role"`
+
+	// State is "active" or "pending" (invited but not yet accepted).
+	State string `json:"state"`
+}
+
+// Team membership states.
+const (
+	TeamMembershipActive  = "active"
+	TeamMembershipPending = "pending"
+)
@@ -13,9 +13,19 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"net/http/pprof"
+	"net/netip"
+	"runtime"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/andrewkroh/traefik-github-auth/internal/logattr"
+	"github.com/andrewkroh/traefik-github-auth/internal/requestid"
 	"github.com/andrewkroh/traefik-github-auth/internal/validator"
+	"github.com/andrewkroh/traefik-github-auth/signature"
 )
 
 // TokenValidator defines the interface for token validation.
@@ -24,150 +34,879 @@ type TokenValidator interface {
 	Validate(ctx context.Context, token string) (*validator.ValidationResult, error)
 }
 
+// ReadinessChecker reports whether the service is ready to serve traffic.
+type ReadinessChecker interface {
+	Ready() bool
+}
+
 // Handler provides HTTP handlers for the ForwardAuth service.
 type Handler struct {
 	validator TokenValidator
 	log       *slog.Logger
+
+	// metrics, if non-nil, serves GET /metrics.
+	metrics http.Handler
+
+	// teamsFormat selects how the teams header value is encoded.
+	teamsFormat TeamsHeaderFormat
+
+	// headerPrefix is prepended to the identity header names (Login, Id,
+	// Org, Teams). Requests carrying headers with this prefix are rejected.
+	headerPrefix string
+
+	// trustedProxies are the prefixes of proxies whose X-Forwarded-For
+	// entries are believed when determining the source IP.
+	trustedProxies []netip.Prefix
+
+	// readiness, if non-nil, gates GET /ready.
+	readiness ReadinessChecker
+
+	// forwardedAuthHeader, if set, names a header carrying the original
+	// "Bearer <token>" credentials when Authorization is absent.
+	forwardedAuthHeader string
+
+	// teamHeaders maps canonical header names to the team slug whose
+	// membership each reports as "true" or "false".
+	teamHeaders map[string]string
+
+	// tokenCookie, if set, names a cookie that holds the token when the
+	// Authorization header is absent.
+	tokenCookie string
+
+	// version is reported by GET /version.
+	version string
+
+	// pprof registers the net/http/pprof handlers with AdminRoutes.
+	pprof bool
+
+	// successStatus is the status code written when a token is valid.
+	successStatus int
+
+	// maxTokenLength is the longest token passed to the validator, or 0
+	// for no limit.
+	maxTokenLength int
+
+	// signingKey, if set, is the HMAC key used to sign the identity
+	// headers in the signature.Header response header.
+	signingKey []byte
+
+	// corsAllowOrigin, if set, is the origin allowed by CORS preflight
+	// responses, or "*" for any origin.
+	corsAllowOrigin string
+
+	// suppressEmail omits the email header even when an email is known.
+	suppressEmail bool
+
+	// teamNames adds a header with the display names of the user's teams.
+	teamNames bool
+
+	// userFields are the optional user attributes forwarded in identity
+	// headers.
+	userFields []UserField
+
+	// cacheStatus adds headers reporting whether the result was cached.
+	cacheStatus bool
+
+	// auditLog emits one audit record per /validate decision.
+	auditLog bool
+
+	// allowInjectedHeaders disables the rejection of requests that already
+	// carry identity headers.
+	allowInjectedHeaders bool
+
+	// separateAdmin moves the health, readiness, and metrics routes from
+	// Routes to AdminRoutes.
+	separateAdmin bool
+
+	// draining is set once shutdown begins. New validations are rejected
+	// with 503 while in-flight ones are allowed to complete.
+	draining atomic.Bool
+
+	// shuttingDown is set as soon as a shutdown signal is received. It
+	// fails readiness so that load balancers stop routing new traffic,
+	// while liveness and validation are unaffected.
+	shuttingDown atomic.Bool
+}
+
+// TeamsHeaderFormat selects the encoding of the teams header value.
+type TeamsHeaderFormat string
+
+const (
+	// TeamsFormatCSV joins team slugs with commas. This is the default.
+	TeamsFormatCSV TeamsHeaderFormat = "csv"
+
+	// TeamsFormatJSON encodes team slugs as a JSON array of strings.
+	TeamsFormatJSON TeamsHeaderFormat = "json"
+)
+
+// UserField is an optional user attribute that can be forwarded in an
+// identity header with WithUserFields.
+type UserField string
+
+const (
+	// UserFieldNodeID forwards the user's GraphQL node ID in the Node-Id
+	// identity header (e.g. X-Auth-User-Node-Id).
+	UserFieldNodeID UserField = "node_id"
+
+	// UserFieldAvatarURL forwards the user's avatar URL in the Avatar-Url
+	// identity header (e.g. X-Auth-User-Avatar-Url).
+	UserFieldAvatarURL UserField = "avatar_url"
+)
+
+// header returns the identity header name suffix for f.
+func (f UserField) header() string {
+	switch f {
+	case UserFieldNodeID:
+		return "Node-Id"
+	case UserFieldAvatarURL:
+		return "Avatar-Url"
+	default:
+		return ""
+	}
+}
+
+// value returns the value of f in result.
+func (f UserField) value(result *validator.ValidationResult) string {
+	switch f {
+	case UserFieldNodeID:
+		return result.NodeID
+	case UserFieldAvatarURL:
+		return result.AvatarURL
+	default:
+		return ""
+	}
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithTeamsHeaderFormat sets the encoding of the teams header value.
+func WithTeamsHeaderFormat(f TeamsHeaderFormat) Option {
+	return func(h *Handler) {
+		h.teamsFormat = f
+	}
+}
+
+// WithUserFields forwards the given optional user attributes in identity
+// headers. Fields that are empty for a user are omitted, as are unknown
+// fields. By default none are forwarded.
+func WithUserFields(fields []UserField) Option {
+	return func(h *Handler) {
+		h.userFields = fields
+	}
+}
+
+// WithTeamNames adds a Team-Names identity header (e.g.
+// X-Auth-User-Team-Names) holding the display names of the user's teams,
+// in the same order and format as the teams header.
+func WithTeamNames() Option {
+	return func(h *Handler) {
+		h.teamNames = true
+	}
+}
+
+// WithCacheStatus adds an X-Auth-Cache header set to "hit" or "miss" to
+// successful responses, and on a hit an X-Auth-Cache-Age header holding
+// the age of the cached result in seconds. It is intended for debugging.
+func WithCacheStatus() Option {
+	return func(h *Handler) {
+		h.cacheStatus = true
+	}
+}
+
+// WithAuditLog logs one "Audit" record at info level for every /validate
+// decision, with a fixed set of fields (see audit) suitable for ingestion
+// by a SIEM. CORS preflight requests are not audited.
+func WithAuditLog() Option {
+	return func(h *Handler) {
+		h.auditLog = true
+	}
+}
+
+// WithMetricsHandler exposes mh at GET /metrics.
+func WithMetricsHandler(mh http.Handler) Option {
+	return func(h *Handler) {
+		h.metrics = mh
+	}
+}
+
+// WithHeaderPrefix sets the prefix of the identity response headers. The
+// default is "X-Auth-User-". An empty prefix is ignored.
+func WithHeaderPrefix(prefix string) Option {
+	return func(h *Handler) {
+		if prefix != "" {
+			h.headerPrefix = http.CanonicalHeaderKey(prefix)
+		}
+	}
+}
+
+// WithTrustedProxies sets the prefixes of proxies whose X-Forwarded-For
+// entries are trusted. Without any, X-Forwarded-For is ignored and the
+// source IP is taken from the connection.
+func WithTrustedProxies(prefixes []netip.Prefix) Option {
+	return func(h *Handler) {
+		h.trustedProxies = prefixes
+	}
+}
+
+// WithReadinessChecker makes GET /ready return 503 Service Unavailable
+// whenever rc reports not ready.
+func WithReadinessChecker(rc ReadinessChecker) Option {
+	return func(h *Handler) {
+		h.readiness = rc
+	}
+}
+
+// WithForwardedAuthHeader reads the "Bearer <token>" credentials from the
+// named header (e.g. X-Forwarded-Authorization) when a request has no
+// Authorization header, for proxy chains that move the original
+// Authorization header aside. It takes precedence over the token cookie.
+func WithForwardedAuthHeader(name string) Option {
+	return func(h *Handler) {
+		h.forwardedAuthHeader = name
+	}
+}
+
+// WithTeamHeaders sets a boolean header for each entry of teams, which maps
+// a team slug to a header name. On success the header is "true" if the user
+// belongs to the team (compared case-insensitively) and "false" otherwise.
+// The headers are omitted when team membership is unknown. Requests that
+// already carry any of these headers are rejected.
+func WithTeamHeaders(teams map[string]string) Option {
+	return func(h *Handler) {
+		h.teamHeaders = make(map[string]string, len(teams))
+		for slug, name := range teams {
+			h.teamHeaders[http.CanonicalHeaderKey(name)] = slug
+		}
+	}
+}
+
+// WithTokenCookie reads the token from the named cookie when a request has
+// no Authorization header. The Authorization header always takes
+// precedence.
+func WithTokenCookie(name string) Option {
+	return func(h *Handler) {
+		h.tokenCookie = name
+	}
+}
+
+// WithSeparateAdminRoutes serves GET /healthz, GET /ready, GET /version, and
+// GET /metrics only from AdminRoutes, leaving /validate as the sole route of
+// Routes. It is intended for running the admin routes on a separate
+// listener.
+func WithSeparateAdminRoutes() Option {
+	return func(h *Handler) {
+		h.separateAdmin = true
+	}
+}
+
+// WithVersion sets the build version reported by GET /version.
+func WithVersion(version string) Option {
+	return func(h *Handler) {
+		h.version = version
+	}
+}
+
+// WithSuccessStatus sets the status code written when a token is valid.
+// The default is 200 OK. Traefik accepts any 2xx status; 204 No Content is
+// a common alternative.
+func WithSuccessStatus(code int) Option {
+	return func(h *Handler) {
+		h.successStatus = code
+	}
+}
+
+// WithMaxTokenLength rejects tokens longer than n bytes with 401 without
+// calling the validator. The default is 500; GitHub tokens are far
+// shorter. An n of 0 disables the limit.
+func WithMaxTokenLength(n int) Option {
+	return func(h *Handler) {
+		h.maxTokenLength = n
+	}
+}
+
+// WithHeaderSigningKey signs the identity headers of successful responses
+// with key, adding a signature.Header header that upstreams can check with
+// signature.Verify. Requests that already carry a signature header are
+// rejected as injected.
+func WithHeaderSigningKey(key []byte) Option {
+	return func(h *Handler) {
+		h.signingKey = key
+	}
+}
+
+// WithRejectInjectedHeaders controls whether requests that already carry
+// identity headers (those with the header prefix or a team header name)
+// are rejected with 403. Rejection is enabled by default. Disable it only
+// on trusted internal networks where the proxy may replay headers set by
+// this service, e.g. on retries. Note that headers this service does not
+// set for a given response (such as teams when disabled) are then passed
+// through unchanged.
+func WithRejectInjectedHeaders(reject bool) Option {
+	return func(h *Handler) {
+		h.allowInjectedHeaders = !reject
+	}
+}
+
+// WithCORSAllowOrigin answers CORS preflight requests from origin (or any
+// origin if "*") with 204 and Access-Control-Allow-* headers instead of
+// validating them. Browsers send preflights without credentials, so they
+// would otherwise be rejected with 401. A preflight is an OPTIONS request,
+// identified by the request method or Traefik's X-Forwarded-Method, that
+// carries an Access-Control-Request-Method header.
+func WithCORSAllowOrigin(origin string) Option {
+	return func(h *Handler) {
+		h.corsAllowOrigin = origin
+	}
+}
+
+// WithEmail controls whether the user's public email is sent in the email
+// identity header. It is enabled by default; disable it where email is
+// treated as PII that should not reach every upstream.
+func WithEmail(enabled bool) Option {
+	return func(h *Handler) {
+		h.suppressEmail = !enabled
+	}
+}
+
+// WithPprof serves the net/http/pprof profiling handlers under
+// /debug/pprof/ on AdminRoutes. They are never served by Routes, even
+// when it also serves the admin routes, so that profiling is not exposed
+// on the listener that receives client traffic.
+func WithPprof() Option {
+	return func(h *Handler) {
+		h.pprof = true
+	}
 }
 
 // New creates a new Handler with the given validator and logger.
-func New(v TokenValidator, log *slog.Logger) *Handler {
-	return &Handler{
-		validator: v,
-		log:       log,
+func New(v TokenValidator, log *slog.Logger, opts ...Option) *Handler {
+	h := &Handler{
+		validator:      v,
+		log:            log,
+		headerPrefix:   defaultHeaderPrefix,
+		teamsFormat:    TeamsFormatCSV,
+		successStatus:  http.StatusOK,
+		maxTokenLength: defaultMaxTokenLength,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
+}
+
+// StartDraining causes subsequent /validate requests to be rejected with
+// 503 Service Unavailable. Health checks are unaffected. It is intended
+// to be called when shutdown begins, before http.Server.Shutdown.
+func (h *Handler) StartDraining() {
+	h.draining.Store(true)
+}
+
+// StartShutdown causes GET /ready to return 503 Service Unavailable so that
+// load balancers stop sending new traffic. GET /healthz and /validate are
+// unaffected. It is intended to be called as soon as a shutdown signal is
+// received, before any drain delay.
+func (h *Handler) StartShutdown() {
+	h.shuttingDown.Store(true)
 }
 
-// Routes returns an http.Handler with all routes registered.
+// Routes returns an http.Handler serving /validate. Unless
+// WithSeparateAdminRoutes is used, it also serves the admin routes.
 func (h *Handler) Routes() http.Handler {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/validate", h.handleValidate)
+	// GET also matches HEAD, which is validated identically but has no
+	// response body. OPTIONS is accepted for CORS preflight requests sent
+	// directly to the service. Other methods get 405.
+	mux.HandleFunc("GET /validate", h.handleValidate)
+	mux.HandleFunc("OPTIONS /validate", h.handleValidate)
+	if !h.separateAdmin {
+		h.registerAdminRoutes(mux)
+	}
+	return mux
+}
+
+// AdminRoutes returns an http.Handler serving the health, readiness,
+// version, and metrics routes, and the profiling routes if WithPprof is
+// used.
+func (h *Handler) AdminRoutes() http.Handler {
+	mux := http.NewServeMux()
+	h.registerAdminRoutes(mux)
+	if h.pprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return mux
+}
+
+// registerAdminRoutes registers the health, readiness, version, and
+// metrics routes on mux.
+func (h *Handler) registerAdminRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /healthz", h.handleHealthz)
 	mux.HandleFunc("GET /ready", h.handleReady)
-	return mux
+	mux.HandleFunc("GET /version", h.handleVersion)
+	if h.metrics != nil {
+		mux.Handle("GET /metrics", h.metrics)
+	}
 }
 
 // getSourceIP extracts the client IP address from the request.
-// It first checks the X-Forwarded-For header (used when behind a proxy).
-// If X-Forwarded-For contains multiple IPs, it returns the leftmost (original client).
-// Otherwise, it falls back to RemoteAddr.
-func getSourceIP(r *http.Request) string {
-	// Check X-Forwarded-For header first.
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		// X-Forwarded-For can contain multiple IPs: "client, proxy1, proxy2"
-		// The leftmost is the original client.
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			clientIP := strings.TrimSpace(ips[0])
-			if clientIP != "" {
-				return clientIP
-			}
+// X-Forwarded-For is only honored when the immediate peer (RemoteAddr) is
+// within one of the trusted proxy prefixes. The list is then walked from
+// right to left, skipping trusted hops, and the first untrusted address is
+// returned. If every hop is trusted, the leftmost address is returned.
+// Otherwise, it falls back to RemoteAddr. Addresses are normalized with
+// normalizeIP.
+func getSourceIP(r *http.Request, trusted []netip.Prefix) string {
+	// RemoteAddr is in the format "IP:port"; normalizeIP strips the port.
+	remoteIP := normalizeIP(r.RemoteAddr)
+	if !isTrustedProxy(remoteIP, trusted) {
+		return remoteIP
+	}
+
+	// X-Forwarded-For can contain multiple IPs: "client, proxy1, proxy2",
+	// and may be split across several header lines. Each proxy appends the
+	// address of its peer, so only the entries to the right of the last
+	// untrusted hop can be believed.
+	xff := strings.Join(r.Header.Values("X-Forwarded-For"), ",")
+	clientIP := ""
+	ips := strings.Split(xff, ",")
+	for i := len(ips) - 1; i >= 0; i-- {
+		ip := normalizeIP(ips[i])
+		if ip == "" {
+			continue
 		}
+		if !isTrustedProxy(ip, trusted) {
+			return ip
+		}
+		clientIP = ip
+	}
+	if clientIP != "" {
+		return clientIP
 	}
+	return remoteIP
+}
+
+// normalizeIP returns the canonical form of an address taken from
+// RemoteAddr or X-Forwarded-For, removing surrounding whitespace, brackets,
+// any port, and any IPv6 zone. Values that do not parse as an IP address
+// are returned with only the whitespace, brackets, and port removed.
+func normalizeIP(s string) string {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return s
+	}
+	return addr.WithZone("").String()
+}
 
-	// Fall back to RemoteAddr.
-	// RemoteAddr is in the format "IP:port", so we need to strip the port.
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
+// isTrustedProxy reports whether ip is contained in any of the trusted
+// prefixes. Unparseable addresses are never trusted.
+func isTrustedProxy(ip string, trusted []netip.Prefix) bool {
+	if len(trusted) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
 	if err != nil {
-		// If SplitHostPort fails, return RemoteAddr as-is.
-		return r.RemoteAddr
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
 	}
-	return host
+	return false
 }
 
-// authHeaderPrefix is the prefix for all headers set by this service.
-// Incoming requests must not contain these headers to prevent injection attacks.
-const authHeaderPrefix = "X-Auth-User-"
+// statusClientClosedRequest is the non-standard status code (popularized by
+// nginx) used when the client disconnects before a response is written.
+const statusClientClosedRequest = 499
+
+// defaultMaxTokenLength is the default maximum token length in bytes.
+const defaultMaxTokenLength = 500
+
+// defaultHeaderPrefix is the default prefix for all identity headers set
+// by this service. Incoming requests must not contain headers with the
+// configured prefix to prevent injection attacks.
+const defaultHeaderPrefix = "X-Auth-User-"
 
 // handleValidate is the ForwardAuth handler that validates GitHub PATs.
 func (h *Handler) handleValidate(w http.ResponseWriter, r *http.Request) {
-	sourceIP := getSourceIP(r)
+	// Correlate log lines and GitHub requests with Traefik's request ID,
+	// generating one if Traefik did not send it.
+	r = r.WithContext(requestid.NewContext(r.Context(), requestid.Resolve(r.Header.Get(requestid.Header))))
+	// Every log line for this request carries the source IP.
+	sourceIP := getSourceIP(r, h.trustedProxies)
+	r = r.WithContext(logattr.NewContext(r.Context(), slog.String("source.ip", sourceIP)))
+
+	var (
+		result *validator.ValidationResult
+		aw     *auditWriter
+	)
+	if h.auditLog {
+		aw = &auditWriter{ResponseWriter: w, status: http.StatusOK}
+		w = aw
+		defer func() {
+			if aw != nil {
+				h.audit(r.Context(), aw, sourceIP, result)
+			}
+		}()
+	}
+
+	if h.draining.Load() {
+		h.log.InfoContext(r.Context(), "Rejecting request while draining")
+		writeJSONError(w, http.StatusServiceUnavailable, codeShuttingDown, "service is shutting down")
+		return
+	}
+
+	if h.isCORSPreflight(r) {
+		h.log.DebugContext(r.Context(), "Answering CORS preflight request",
+			slog.String("origin", r.Header.Get("Origin")),
+		)
+		// Preflights are not authentication decisions.
+		aw = nil
+		h.writeCORSPreflight(w, r)
+		return
+	}
 
 	// Reject requests with pre-set auth identity headers to prevent
 	// header injection attacks (spoofing user identity).
-	for name := range r.Header {
-		if strings.HasPrefix(name, authHeaderPrefix) {
-			h.log.WarnContext(r.Context(), "Request contains injected auth header",
-				slog.String("header", name),
-				slog.String("source.ip", sourceIP),
-			)
-			writeJSONError(w, http.StatusForbidden, "forbidden: request contains disallowed headers")
-			return
-		}
+	if name, ok := h.injectedHeader(r); ok {
+		h.log.WarnContext(r.Context(), "Request contains injected auth header",
+			slog.String("header", name),
+		)
+		writeJSONError(w, http.StatusForbidden, codeInjectedHeaders, "forbidden: request contains disallowed headers")
+		return
 	}
 
-	// Extract the Authorization header.
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		h.log.WarnContext(r.Context(), "Missing Authorization header",
-			slog.String("source.ip", sourceIP),
-		)
-		writeJSONError(w, http.StatusUnauthorized, "missing or malformed Authorization header")
+	// Extract the token from the Authorization header, falling back to
+	// the forwarded authorization header and then the token cookie, if
+	// configured, when it is absent.
+	var token string
+	if authHeader := h.authorizationHeader(r); authHeader != "" {
+		// Parse "Bearer <token>" or "token <token>".
+		var ok bool
+		token, ok = parseBearerToken(authHeader)
+		if !ok {
+			h.log.WarnContext(r.Context(), "Malformed Authorization header")
+			writeUnauthorized(w, codeMissingToken, "missing or malformed Authorization header")
+			return
+		}
+	} else if cookieToken, ok := h.tokenFromCookie(r); ok {
+		token = cookieToken
+	} else {
+		h.log.WarnContext(r.Context(), "Missing Authorization header")
+		writeUnauthorized(w, codeMissingToken, "missing or malformed Authorization header")
 		return
 	}
 
-	// Parse "Bearer <token>".
-	token, ok := parseBearerToken(authHeader)
-	if !ok {
-		h.log.WarnContext(r.Context(), "Malformed Authorization header",
-			slog.String("source.ip", sourceIP),
+	// Reject oversized tokens before hashing them for the cache key.
+	if h.maxTokenLength > 0 && len(token) > h.maxTokenLength {
+		h.log.WarnContext(r.Context(), "Token exceeds maximum length",
+			slog.Int("token.length", len(token)),
 		)
-		writeJSONError(w, http.StatusUnauthorized, "missing or malformed Authorization header")
+		writeUnauthorized(w, codeTokenTooLong, "token exceeds maximum length")
 		return
 	}
 
 	// Validate the token.
 	result, err := h.validator.Validate(r.Context(), token)
 	if err != nil {
-		h.handleValidationError(r.Context(), w, sourceIP, err)
+		if aw != nil {
+			aw.reason = err.Error()
+		}
+		h.handleValidationError(r.Context(), w, err)
 		return
 	}
 
 	// Success: set response headers with user info.
-	w.Header().Set("X-Auth-User-Login", result.Login)
-	w.Header().Set("X-Auth-User-Id", fmt.Sprintf("%d", result.ID))
-	w.Header().Set("X-Auth-User-Org", result.Org)
-	w.Header().Set("X-Auth-User-Teams", strings.Join(result.Teams, ","))
+	w.Header().Set(h.headerPrefix+"Login", result.Login)
+	w.Header().Set(h.headerPrefix+"Id", fmt.Sprintf("%d", result.ID))
+	w.Header().Set(h.headerPrefix+"Org", result.Org)
+	if result.OrgRole != "" {
+		w.Header().Set(h.headerPrefix+"Org-Role", result.OrgRole)
+	}
+	if result.Email != "" && !h.suppressEmail {
+		w.Header().Set(h.headerPrefix+"Email", result.Email)
+	}
+	for _, f := range h.userFields {
+		if name, v := f.header(), f.value(result); name != "" && v != "" {
+			w.Header().Set(h.headerPrefix+name, v)
+		}
+	}
+	if !result.TeamsDisabled {
+		w.Header().Set(h.headerPrefix+"Teams", h.formatTeams(result.Teams))
+		if h.teamNames {
+			w.Header().Set(h.headerPrefix+"Team-Names", h.formatTeams(result.TeamNames))
+		}
+		if result.TeamsDegraded {
+			w.Header().Set(signature.TeamsStatusHeader, "degraded")
+		} else {
+			w.Header().Set(signature.TeamsStatusHeader, "ok")
+			for name, slug := range h.teamHeaders {
+				w.Header().Set(name, strconv.FormatBool(containsFold(result.Teams, slug)))
+			}
+		}
+	}
+	if h.signingKey != nil {
+		signature.Sign(h.signingKey, w.Header(), h.identityHeaderNames())
+	}
+	if h.cacheStatus {
+		h.setCacheStatus(w, result)
+	}
 
 	h.log.InfoContext(r.Context(), "Authentication successful",
 		slog.String("login", result.Login),
 		slog.Int64("user_id", result.ID),
+	)
+
+	w.WriteHeader(h.successStatus)
+}
+
+// identityHeaderNames returns the names of every identity header the
+// handler may set on a successful response. All of them are signed when a
+// signing key is configured; names that were not set are skipped.
+func (h *Handler) identityHeaderNames() []string {
+	names := []string{
+		h.headerPrefix + "Login",
+		h.headerPrefix + "Id",
+		h.headerPrefix + "Org",
+		h.headerPrefix + "Org-Role",
+		h.headerPrefix + "Email",
+		h.headerPrefix + "Teams",
+		h.headerPrefix + "Team-Names",
+		signature.TeamsStatusHeader,
+	}
+	for _, f := range h.userFields {
+		if name := f.header(); name != "" {
+			names = append(names, h.headerPrefix+name)
+		}
+	}
+	for name := range h.teamHeaders {
+		names = append(names, name)
+	}
+	return names
+}
+
+// setCacheStatus sets the X-Auth-Cache and X-Auth-Cache-Age headers from
+// result.
+func (h *Handler) setCacheStatus(w http.ResponseWriter, result *validator.ValidationResult) {
+	if !result.FromCache {
+		w.Header().Set("X-Auth-Cache", "miss")
+		return
+	}
+	w.Header().Set("X-Auth-Cache", "hit")
+	if !result.ValidatedAt.IsZero() {
+		age := max(time.Since(result.ValidatedAt), 0)
+		w.Header().Set("X-Auth-Cache-Age", strconv.FormatInt(int64(age/time.Second), 10))
+	}
+}
+
+// auditWriter records the outcome of a /validate request for its audit
+// record.
+type auditWriter struct {
+	http.ResponseWriter
+	status int
+	code   string
+	reason string
+}
+
+// WriteHeader records the status code before writing it.
+func (w *auditWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// audit logs the audit record for a /validate decision. Every record has
+// the same fields; login and org are empty unless the token was accepted.
+func (h *Handler) audit(ctx context.Context, aw *auditWriter, sourceIP string, result *validator.ValidationResult) {
+	outcome, code, reason := "failure", aw.code, aw.reason
+	var login, org string
+	switch {
+	case result != nil && aw.code == "":
+		outcome, code, reason = "success", "ok", "authorized"
+		login, org = result.Login, result.Org
+	case aw.status == statusClientClosedRequest:
+		code = "client_closed_request"
+	}
+	h.log.LogAttrs(ctx, slog.LevelInfo, "Audit",
+		slog.String("event.action", "github_auth"),
+		slog.String("event.outcome", outcome),
 		slog.String("source.ip", sourceIP),
+		slog.String("user.name", login),
+		slog.String("org", org),
+		slog.String("result", code),
+		slog.String("reason", reason),
+		slog.Int("http.response.status_code", aw.status),
 	)
+}
 
-	w.WriteHeader(http.StatusOK)
+// isCORSPreflight reports whether r is a CORS preflight request from an
+// allowed origin.
+func (h *Handler) isCORSPreflight(r *http.Request) bool {
+	if h.corsAllowOrigin == "" {
+		return false
+	}
+	if r.Method != http.MethodOptions && r.Header.Get("X-Forwarded-Method") != http.MethodOptions {
+		return false
+	}
+	if r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	return origin != "" && (h.corsAllowOrigin == "*" || origin == h.corsAllowOrigin)
+}
+
+// writeCORSPreflight writes a 204 response allowing the method and headers
+// requested by the preflight request r.
+func (h *Handler) writeCORSPreflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", h.corsAllowOrigin)
+	w.Header().Set("Access-Control-Allow-Methods", r.Header.Get("Access-Control-Request-Method"))
+	if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+	}
+	if h.corsAllowOrigin != "*" {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Add("Vary", "Origin")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// injectedHeader returns the name of an identity header already present on
+// the request. It reports false if there is none or if injected headers
+// are allowed. Names are canonicalized and compared case-insensitively, so
+// header map keys that bypassed canonicalization cannot slip through.
+func (h *Handler) injectedHeader(r *http.Request) (string, bool) {
+	if h.allowInjectedHeaders {
+		return "", false
+	}
+	for name := range r.Header {
+		key := http.CanonicalHeaderKey(name)
+		_, isTeamHeader := h.teamHeaders[key]
+		isSignature := h.signingKey != nil && (strings.EqualFold(key, signature.Header) || strings.EqualFold(key, signature.SignedHeadersHeader))
+		if hasPrefixFold(key, h.headerPrefix) || isTeamHeader || isSignature {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// hasPrefixFold reports whether s begins with prefix, ignoring case.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// authorizationHeader returns the Authorization header value or, if it is
+// absent and a forwarded authorization header is configured, that
+// header's value.
+func (h *Handler) authorizationHeader(r *http.Request) string {
+	if v := r.Header.Get("Authorization"); v != "" {
+		return v
+	}
+	if h.forwardedAuthHeader != "" {
+		return r.Header.Get(h.forwardedAuthHeader)
+	}
+	return ""
+}
+
+// tokenFromCookie returns the token held in the configured token cookie.
+// It reports false if no cookie is configured or the cookie is absent or
+// empty.
+func (h *Handler) tokenFromCookie(r *http.Request) (string, bool) {
+	if h.tokenCookie == "" {
+		return "", false
+	}
+	c, err := r.Cookie(h.tokenCookie)
+	if err != nil || c.Value == "" {
+		return "", false
+	}
+	return c.Value, true
+}
+
+// containsFold reports whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatTeams encodes team slugs or names for a teams header using the
+// configured format.
+func (h *Handler) formatTeams(teams []string) string {
+	if h.teamsFormat == TeamsFormatJSON {
+		if teams == nil {
+			teams = []string{}
+		}
+		b, _ := json.Marshal(teams)
+		return string(b)
+	}
+	return strings.Join(teams, ",")
 }
 
 // handleValidationError maps validation errors to appropriate HTTP responses.
-func (h *Handler) handleValidationError(ctx context.Context, w http.ResponseWriter, sourceIP string, err error) {
+func (h *Handler) handleValidationError(ctx context.Context, w http.ResponseWriter, err error) {
 	switch {
+	case errors.Is(err, context.Canceled):
+		// The client went away; this is not a server error.
+		h.log.DebugContext(ctx, "Token validation canceled: client closed request")
+		w.WriteHeader(statusClientClosedRequest)
 	case errors.Is(err, validator.ErrUnauthorized):
-		h.log.WarnContext(ctx, "Token validation failed: unauthorized",
-			slog.String("source.ip", sourceIP),
-		)
-		writeJSONError(w, http.StatusUnauthorized, "access denied")
+		h.log.WarnContext(ctx, "Token validation failed: unauthorized")
+		writeUnauthorized(w, codeUnauthorized, "access denied")
+	case errors.Is(err, validator.ErrTokenExpired):
+		h.log.WarnContext(ctx, "Token validation failed: token expired")
+		writeUnauthorized(w, codeTokenExpired, "token has expired or been revoked, generate a new token")
+	case errors.Is(err, validator.ErrForbiddenToken):
+		h.log.WarnContext(ctx, "Token validation failed: token forbidden from reading user")
+		writeJSONError(w, http.StatusForbidden, codeForbiddenToken, "access denied")
 	case errors.Is(err, validator.ErrNotOrgMember):
-		h.log.WarnContext(ctx, "Token validation failed: not an org member",
-			slog.String("source.ip", sourceIP),
-		)
-		writeJSONError(w, http.StatusForbidden, "access denied")
+		h.log.WarnContext(ctx, "Token validation failed: not an org member")
+		writeJSONError(w, http.StatusForbidden, codeNotOrgMember, "access denied")
 	case errors.Is(err, validator.ErrClassicPAT):
-		h.log.WarnContext(ctx, "Token validation failed: classic PAT rejected",
-			slog.String("source.ip", sourceIP),
-		)
-		writeJSONError(w, http.StatusForbidden, "forbidden: classic PATs are not allowed")
+		h.log.WarnContext(ctx, "Token validation failed: classic PAT rejected")
+		writeJSONError(w, http.StatusForbidden, codeClassicPAT, "forbidden: classic PATs are not allowed")
+	case errors.Is(err, validator.ErrLoginNotAllowed):
+		h.log.WarnContext(ctx, "Token validation failed: login not allowed")
+		writeJSONError(w, http.StatusForbidden, codeLoginNotAllowed, "access denied")
+	case errors.Is(err, validator.ErrTeamNotAuthorized):
+		h.log.WarnContext(ctx, "Token validation failed: not in a required team")
+		writeJSONError(w, http.StatusForbidden, codeTeamNotAuthorized, "access denied")
 	case errors.Is(err, validator.ErrRateLimited):
-		h.log.WarnContext(ctx, "Token validation failed: rate limited",
-			slog.String("source.ip", sourceIP),
+		h.log.WarnContext(ctx, "Token validation failed: rate limited")
+		writeJSONError(w, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded, try again later")
+	case errors.Is(err, validator.ErrCircuitOpen):
+		h.log.WarnContext(ctx, "Token validation failed: GitHub circuit breaker open")
+		writeJSONError(w, http.StatusServiceUnavailable, codeUnavailable, "service unavailable, try again later")
+	case errors.Is(err, validator.ErrTimeout):
+		h.log.WarnContext(ctx, "Token validation failed: deadline exceeded",
+			slog.String("error", err.Error()),
+		)
+		writeJSONError(w, http.StatusGatewayTimeout, codeTimeout, "validation timed out, try again later")
+	case errors.Is(err, validator.ErrUpstreamUnavailable):
+		h.log.WarnContext(ctx, "Token validation failed: GitHub unreachable",
+			slog.String("error", err.Error()),
 		)
-		writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded, try again later")
+		writeJSONError(w, http.StatusBadGateway, codeUpstreamUnavailable, "GitHub API unavailable, try again later")
+	case errors.Is(err, validator.ErrBadUpstreamResponse):
+		h.log.WarnContext(ctx, "Token validation failed: malformed GitHub response",
+			slog.String("error", err.Error()),
+		)
+		writeJSONError(w, http.StatusBadGateway, codeBadUpstreamResponse, "GitHub API returned an invalid response, try again later")
 	default:
 		h.log.ErrorContext(ctx, "Token validation failed: internal error",
 			slog.String("error", err.Error()),
-			slog.String("source.ip", sourceIP),
 		)
-		writeJSONError(w, http.StatusInternalServerError, "internal server error")
+		writeJSONError(w, http.StatusInternalServerError, codeInternal, "internal server error")
 	}
 }
 
@@ -178,21 +917,62 @@ func (h *Handler) handleHealthz(w http.ResponseWriter, _ *http.Request) {
 	fmt.Fprint(w, "ok")
 }
 
-// handleReady responds with a simple readiness check.
+// handleReady responds with a readiness check. It returns 503 once
+// shutdown has started or when the configured ReadinessChecker reports not
+// ready.
 func (h *Handler) handleReady(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
+	if h.shuttingDown.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "shutting down")
+		return
+	}
+	if h.readiness != nil && !h.readiness.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "not ready")
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, "ok")
 }
 
-// parseBearerToken extracts the token from a "Bearer <token>" Authorization header.
-// Returns the token and true if valid, or empty string and false if malformed.
+// versionResponse is the JSON structure for GET /version.
+type versionResponse struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Revision  string `json:"vcs_revision,omitempty"`
+}
+
+// handleVersion responds with the build version, the Go version, and the
+// VCS revision if it was embedded at build time.
+func (h *Handler) handleVersion(w http.ResponseWriter, _ *http.Request) {
+	resp := versionResponse{
+		Version:   h.version,
+		GoVersion: runtime.Version(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				resp.Revision = s.Value
+				break
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseBearerToken extracts the token from a "Bearer <token>" or
+// "token <token>" Authorization header, as GitHub accepts both. The scheme
+// is matched case-insensitively. Returns the token and true if valid, or
+// empty string and false if malformed.
 func parseBearerToken(header string) (string, bool) {
-	const prefix = "Bearer "
-	if !strings.HasPrefix(header, prefix) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !(strings.EqualFold(scheme, "Bearer") || strings.EqualFold(scheme, "token")) {
 		return "", false
 	}
-	token := strings.TrimPrefix(header, prefix)
 	token = strings.TrimSpace(token)
 	if token == "" {
 		return "", false
@@ -200,14 +980,55 @@ func parseBearerToken(header string) (string, bool) {
 	return token, true
 }
 
-// errorResponse is the JSON structure for error responses.
+// errorResponse is the JSON structure for error responses. Code is a
+// stable machine-readable identifier; Error is a human-readable message
+// that may change.
 type errorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// Error codes returned in errorResponse.Code.
+const (
+	codeMissingToken        = "missing_token"
+	codeTokenTooLong        = "token_too_long"
+	codeUnauthorized        = "unauthorized"
+	codeTokenExpired        = "token_expired"
+	codeForbiddenToken      = "forbidden_token"
+	codeNotOrgMember        = "not_org_member"
+	codeClassicPAT          = "classic_pat"
+	codeLoginNotAllowed     = "login_not_allowed"
+	codeTeamNotAuthorized   = "team_not_authorized"
+	codeRateLimited         = "rate_limited"
+	codeUnavailable         = "unavailable"
+	codeTimeout             = "timeout"
+	codeUpstreamUnavailable = "upstream_unavailable"
+	codeBadUpstreamResponse = "bad_upstream_response"
+	codeInjectedHeaders     = "injected_headers"
+	codeShuttingDown        = "shutting_down"
+	codeInternal            = "internal"
+)
+
+// wwwAuthenticate is the challenge sent with 401 responses (RFC 6750).
+const wwwAuthenticate = `Bearer realm="github", error="invalid_token"`
+
+// writeUnauthorized writes a 401 JSON error response with a Bearer
+// WWW-Authenticate challenge.
+func writeUnauthorized(w http.ResponseWriter, code, message string) {
+	w.Header().Set("WWW-Authenticate", wwwAuthenticate)
+	writeJSONError(w, http.StatusUnauthorized, code, message)
 }
 
-// writeJSONError writes a JSON error response with the given status code and message.
-func writeJSONError(w http.ResponseWriter, statusCode int, message string) {
+// writeJSONError writes a JSON error response with the given status code,
+// error code, and message.
+func writeJSONError(w http.ResponseWriter, statusCode int, code, message string) {
+	if aw, ok := w.(*auditWriter); ok {
+		aw.code = code
+		if aw.reason == "" {
+			aw.reason = message
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(errorResponse{Error: message})
+	json.NewEncoder(w).Encode(errorResponse{Error: message, Code: code})
 }
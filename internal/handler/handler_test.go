@@ -5,16 +5,29 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/andrewkroh/traefik-github-auth/internal/cache"
+	"github.com/andrewkroh/traefik-github-auth/internal/github"
+	"github.com/andrewkroh/traefik-github-auth/internal/logattr"
+	"github.com/andrewkroh/traefik-github-auth/internal/otelsetup"
+	"github.com/andrewkroh/traefik-github-auth/internal/requestid"
 	"github.com/andrewkroh/traefik-github-auth/internal/validator"
+	"github.com/andrewkroh/traefik-github-auth/signature"
 )
 
 // mockValidator implements TokenValidator for testing.
@@ -127,6 +140,48 @@ func TestValidate_MalformedAuthHeader_BearerOnly(t *testing.T) {
 	}
 }
 
+func TestValidate_AuthorizationSchemes(t *testing.T) {
+	handler := newTestHandler(&mockValidator{
+		validateFunc: func(_ context.Context, token string) (*validator.ValidationResult, error) {
+			if token != "test-token" {
+				t.Errorf("expected token %q, got %q", "test-token", token)
+			}
+			return &validator.ValidationResult{Login: "octocat", ID: 1, Org: "test-org"}, nil
+		},
+	})
+
+	tests := []struct {
+		header     string
+		wantStatus int
+	}{
+		{"Bearer test-token", http.StatusOK},
+		{"bearer test-token", http.StatusOK},
+		{"token test-token", http.StatusOK},
+		{"Token test-token", http.StatusOK},
+		{"TOKEN  test-token ", http.StatusOK},
+		{"token ", http.StatusUnauthorized},
+		{"tokentest-token", http.StatusUnauthorized},
+		{"Basic test-token", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", tt.header)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Header().Get("X-Auth-User-Login") != "octocat" {
+				t.Errorf("expected X-Auth-User-Login %q, got %q", "octocat", rec.Header().Get("X-Auth-User-Login"))
+			}
+		})
+	}
+}
+
 func TestValidate_Success(t *testing.T) {
 	handler := newTestHandler(&mockValidator{
 		validateFunc: func(_ context.Context, token string) (*validator.ValidationResult, error) {
@@ -231,6 +286,32 @@ func TestValidate_NotOrgMember(t *testing.T) {
 	}
 }
 
+func TestValidate_ForbiddenToken(t *testing.T) {
+	handler := newTestHandler(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return nil, fmt.Errorf("%w", validator.ErrForbiddenToken)
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	var resp errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error != "access denied" {
+		t.Fatalf("expected error %q, got %q", "access denied", resp.Error)
+	}
+}
+
 func TestValidate_ClassicPAT(t *testing.T) {
 	handler := newTestHandler(&mockValidator{
 		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
@@ -262,6 +343,50 @@ func TestValidate_ClassicPAT(t *testing.T) {
 	}
 }
 
+func TestValidate_LoginNotAllowed(t *testing.T) {
+	handler := newTestHandler(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return nil, fmt.Errorf("%w", validator.ErrLoginNotAllowed)
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestValidate_TeamNotAuthorized(t *testing.T) {
+	handler := newTestHandler(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return nil, fmt.Errorf("%w", validator.ErrTeamNotAuthorized)
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	var resp errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error != "access denied" {
+		t.Fatalf("expected error %q, got %q", "access denied", resp.Error)
+	}
+}
+
 func TestValidate_InternalError(t *testing.T) {
 	handler := newTestHandler(&mockValidator{
 		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
@@ -299,6 +424,128 @@ func TestValidate_InternalError(t *testing.T) {
 	}
 }
 
+func TestValidate_ErrorCodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		header     http.Header
+		draining   bool
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "missing token", header: http.Header{}, wantStatus: http.StatusUnauthorized, wantCode: codeMissingToken},
+		{name: "unauthorized", err: validator.ErrUnauthorized, wantStatus: http.StatusUnauthorized, wantCode: codeUnauthorized},
+		{name: "token expired", err: validator.ErrTokenExpired, wantStatus: http.StatusUnauthorized, wantCode: codeTokenExpired},
+		{name: "forbidden token", err: validator.ErrForbiddenToken, wantStatus: http.StatusForbidden, wantCode: codeForbiddenToken},
+		{name: "not org member", err: validator.ErrNotOrgMember, wantStatus: http.StatusForbidden, wantCode: codeNotOrgMember},
+		{name: "classic PAT", err: validator.ErrClassicPAT, wantStatus: http.StatusForbidden, wantCode: codeClassicPAT},
+		{name: "login not allowed", err: validator.ErrLoginNotAllowed, wantStatus: http.StatusForbidden, wantCode: codeLoginNotAllowed},
+		{name: "team not authorized", err: validator.ErrTeamNotAuthorized, wantStatus: http.StatusForbidden, wantCode: codeTeamNotAuthorized},
+		{name: "rate limited", err: validator.ErrRateLimited, wantStatus: http.StatusTooManyRequests, wantCode: codeRateLimited},
+		{name: "circuit open", err: validator.ErrCircuitOpen, wantStatus: http.StatusServiceUnavailable, wantCode: codeUnavailable},
+		{name: "timeout", err: validator.ErrTimeout, wantStatus: http.StatusGatewayTimeout, wantCode: codeTimeout},
+		{name: "upstream unavailable", err: validator.ErrUpstreamUnavailable, wantStatus: http.StatusBadGateway, wantCode: codeUpstreamUnavailable},
+		{name: "bad upstream response", err: validator.ErrBadUpstreamResponse, wantStatus: http.StatusBadGateway, wantCode: codeBadUpstreamResponse},
+		{name: "internal", err: errors.New("boom"), wantStatus: http.StatusInternalServerError, wantCode: codeInternal},
+		{
+			name:       "injected headers",
+			header:     http.Header{"Authorization": {"Bearer test-token"}, "X-Auth-User-Login": {"admin"}},
+			wantStatus: http.StatusForbidden,
+			wantCode:   codeInjectedHeaders,
+		},
+		{name: "shutting down", draining: true, wantStatus: http.StatusServiceUnavailable, wantCode: codeShuttingDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(&mockValidator{
+				validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+					if tt.err == nil {
+						t.Fatal("validator should not be called")
+					}
+					return nil, fmt.Errorf("validating: %w", tt.err)
+				},
+			}, slog.Default())
+			if tt.draining {
+				h.StartDraining()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			if tt.header != nil {
+				req.Header = tt.header
+			} else {
+				req.Header.Set("Authorization", "Bearer test-token")
+			}
+			rec := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			var resp errorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Code != tt.wantCode {
+				t.Errorf("expected code %q, got %q", tt.wantCode, resp.Code)
+			}
+			if resp.Error == "" {
+				t.Error("expected a non-empty error message")
+			}
+		})
+	}
+}
+
+func TestValidate_WWWAuthenticate(t *testing.T) {
+	tests := []struct {
+		name       string
+		authHeader string
+		err        error
+		wantStatus int
+		wantHeader bool
+	}{
+		{"missing header", "", nil, http.StatusUnauthorized, true},
+		{"malformed header", "Basic xxx", nil, http.StatusUnauthorized, true},
+		{"unauthorized", "Bearer t", validator.ErrUnauthorized, http.StatusUnauthorized, true},
+		{"forbidden", "Bearer t", validator.ErrNotOrgMember, http.StatusForbidden, false},
+		{"rate limited", "Bearer t", validator.ErrRateLimited, http.StatusTooManyRequests, false},
+		{"circuit open", "Bearer t", validator.ErrCircuitOpen, http.StatusServiceUnavailable, false},
+		{"internal error", "Bearer t", errors.New("boom"), http.StatusInternalServerError, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestHandler(&mockValidator{
+				validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+					return nil, fmt.Errorf("%w", tt.err)
+				},
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			got := rec.Header().Get("WWW-Authenticate")
+			if tt.wantHeader && got != `Bearer realm="github", error="invalid_token"` {
+				t.Fatalf("expected WWW-Authenticate challenge, got %q", got)
+			}
+			if !tt.wantHeader && got != "" {
+				t.Fatalf("expected no WWW-Authenticate header, got %q", got)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("expected Content-Type application/json, got %q", ct)
+			}
+		})
+	}
+}
+
 func TestHealthz(t *testing.T) {
 	handler := newTestHandler(&mockValidator{})
 
@@ -333,6 +580,134 @@ func TestReady(t *testing.T) {
 	}
 }
 
+func TestVersion(t *testing.T) {
+	handler := New(&mockValidator{}, slog.Default(), WithVersion("v1.2.3")).Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/version", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected Content-Type application/json, got %q", ct)
+	}
+
+	var resp versionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Version != "v1.2.3" {
+		t.Errorf("expected version %q, got %q", "v1.2.3", resp.Version)
+	}
+	if resp.GoVersion != runtime.Version() {
+		t.Errorf("expected go_version %q, got %q", runtime.Version(), resp.GoVersion)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "github_auth_validation_total 1\n")
+	})
+
+	for _, tc := range []struct {
+		name       string
+		opts       []Option
+		wantStatus int
+	}{
+		{"enabled", []Option{WithMetricsHandler(metrics)}, http.StatusOK},
+		{"disabled", nil, http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := New(&mockValidator{}, slog.Default(), tc.opts...).Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestPprof(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		opts       []Option
+		admin      bool
+		wantStatus int
+	}{
+		{"disabled", nil, false, http.StatusNotFound},
+		{"enabled on admin routes", []Option{WithPprof(), WithSeparateAdminRoutes()}, true, http.StatusOK},
+		{"not on main routes", []Option{WithPprof()}, false, http.StatusNotFound},
+		{"not on main routes with separate admin", []Option{WithPprof(), WithSeparateAdminRoutes()}, false, http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := New(&mockValidator{}, slog.Default(), tc.opts...)
+			handler := h.Routes()
+			if tc.admin {
+				handler = h.AdminRoutes()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestSeparateAdminRoutes(t *testing.T) {
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "github_auth_validation_total 1\n")
+	})
+	h := New(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return &validator.ValidationResult{Login: "octocat", ID: 1, Org: "test-org"}, nil
+		},
+	}, slog.Default(), WithMetricsHandler(metrics), WithSeparateAdminRoutes())
+	routes, admin := h.Routes(), h.AdminRoutes()
+
+	for _, tc := range []struct {
+		name       string
+		handler    http.Handler
+		path       string
+		wantStatus int
+	}{
+		{"main healthz", routes, "/healthz", http.StatusNotFound},
+		{"main ready", routes, "/ready", http.StatusNotFound},
+		{"main version", routes, "/version", http.StatusNotFound},
+		{"main metrics", routes, "/metrics", http.StatusNotFound},
+		{"main validate", routes, "/validate", http.StatusOK},
+		{"admin healthz", admin, "/healthz", http.StatusOK},
+		{"admin ready", admin, "/ready", http.StatusOK},
+		{"admin version", admin, "/version", http.StatusOK},
+		{"admin metrics", admin, "/metrics", http.StatusOK},
+		{"admin validate", admin, "/validate", http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Authorization", "Bearer test-token")
+			rec := httptest.NewRecorder()
+
+			tc.handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
 func TestValidate_EmptyTeams(t *testing.T) {
 	handler := newTestHandler(&mockValidator{
 		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
@@ -360,14 +735,15 @@ func TestValidate_EmptyTeams(t *testing.T) {
 	}
 }
 
-func TestValidate_MultipleTeams(t *testing.T) {
+func TestValidate_TeamsDisabled(t *testing.T) {
 	handler := newTestHandler(&mockValidator{
 		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
 			return &validator.ValidationResult{
-				Login: "octocat",
-				ID:    12345,
-				Org:   "test-org",
-				Teams: []string{"engineering", "security", "platform"},
+				Login:         "octocat",
+				ID:            12345,
+				Org:           "test-org",
+				Teams:         []string{},
+				TeamsDisabled: true,
 			}, nil
 		},
 	})
@@ -382,117 +758,301 @@ func TestValidate_MultipleTeams(t *testing.T) {
 		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
 	}
 
-	expected := "engineering,security,platform"
-	if got := rec.Header().Get("X-Auth-User-Teams"); got != expected {
-		t.Fatalf("expected X-Auth-User-Teams %q, got %q", expected, got)
+	if _, ok := rec.Header()["X-Auth-User-Teams"]; ok {
+		t.Fatal("expected X-Auth-User-Teams to be omitted when teams are disabled")
 	}
 }
 
-func TestGetSourceIP_XForwardedFor_SingleIP(t *testing.T) {
-	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
-	req.RemoteAddr = "10.0.0.5:12345"
-	req.Header.Set("X-Forwarded-For", "203.0.113.42")
-
-	ip := getSourceIP(req)
+func TestValidate_TeamsStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		degraded bool
+		want     string
+	}{
+		{"ok", false, "ok"},
+		{"degraded", true, "degraded"},
+	}
 
-	expected := "203.0.113.42"
-	if ip != expected {
-		t.Fatalf("expected source IP %q, got %q", expected, ip)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestHandler(&mockValidator{
+				validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+					return &validator.ValidationResult{
+						Login:         "octocat",
+						ID:            12345,
+						Org:           "test-org",
+						Teams:         []string{},
+						TeamsDegraded: tt.degraded,
+					}, nil
+				},
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer test-token")
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if got := rec.Header().Get("X-Auth-Teams-Status"); got != tt.want {
+				t.Fatalf("expected X-Auth-Teams-Status %q, got %q", tt.want, got)
+			}
+		})
 	}
 }
 
-func TestGetSourceIP_XForwardedFor_MultipleIPs(t *testing.T) {
-	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
-	req.RemoteAddr = "10.0.0.5:12345"
-	req.Header.Set("X-Forwarded-For", "203.0.113.42, 198.51.100.1, 192.0.2.1")
-
-	ip := getSourceIP(req)
+func TestValidate_TeamsHeaderFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format TeamsHeaderFormat
+		teams  []string
+		want   string
+	}{
+		{"csv", TeamsFormatCSV, []string{"platform-eng", "backend"}, "platform-eng,backend"},
+		{"csv empty", TeamsFormatCSV, []string{}, ""},
+		{"json", TeamsFormatJSON, []string{"platform-eng", "backend"}, `["platform-eng","backend"]`},
+		{"json empty", TeamsFormatJSON, []string{}, "[]"},
+		{"json nil", TeamsFormatJSON, nil, "[]"},
+		{"json comma in slug", TeamsFormatJSON, []string{"a,b"}, `["a,b"]`},
+	}
 
-	// Should return the first (leftmost) IP, which is the original client.
-	expected := "203.0.113.42"
-	if ip != expected {
-		t.Fatalf("expected source IP %q, got %q", expected, ip)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(&mockValidator{
+				validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+					return &validator.ValidationResult{
+						Login: "octocat",
+						ID:    12345,
+						Org:   "test-org",
+						Teams: tt.teams,
+					}, nil
+				},
+			}, slog.Default(), WithTeamsHeaderFormat(tt.format))
+			handler := h.Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer test-token")
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if got := rec.Header().Get("X-Auth-User-Teams"); got != tt.want {
+				t.Fatalf("expected X-Auth-User-Teams %q, got %q", tt.want, got)
+			}
+		})
 	}
 }
 
-func TestGetSourceIP_NoXForwardedFor(t *testing.T) {
-	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
-	req.RemoteAddr = "10.0.0.5:12345"
+func TestValidate_TeamNames(t *testing.T) {
+	result := &validator.ValidationResult{
+		Login:     "octocat",
+		ID:        12345,
+		Org:       "test-org",
+		Teams:     []string{"platform-team", "sre"},
+		TeamNames: []string{"Platform Team", "SRE, On-Call"},
+	}
 
-	ip := getSourceIP(req)
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{"disabled", nil, nil},
+		{"csv", []Option{WithTeamNames()}, []string{"Platform Team,SRE, On-Call"}},
+		{"json", []Option{WithTeamNames(), WithTeamsHeaderFormat(TeamsFormatJSON)}, []string{`["Platform Team","SRE, On-Call"]`}},
+	}
 
-	// Should fall back to RemoteAddr (without the port).
-	expected := "10.0.0.5"
-	if ip != expected {
-		t.Fatalf("expected source IP %q, got %q", expected, ip)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(&mockValidator{
+				validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+					return result, nil
+				},
+			}, slog.Default(), tt.opts...)
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer test-token")
+			rec := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if got := rec.Header().Values("X-Auth-User-Team-Names"); !slices.Equal(got, tt.want) {
+				t.Errorf("expected X-Auth-User-Team-Names %q, got %q", tt.want, got)
+			}
+		})
 	}
 }
 
-func TestGetSourceIP_XForwardedFor_WithSpaces(t *testing.T) {
-	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
-	req.RemoteAddr = "10.0.0.5:12345"
-	req.Header.Set("X-Forwarded-For", "  203.0.113.42  ,  198.51.100.1  ")
-
-	ip := getSourceIP(req)
+func TestValidate_CacheStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		result  validator.ValidationResult
+		want    string
+		wantAge string
+	}{
+		{
+			name:   "disabled",
+			result: validator.ValidationResult{FromCache: true, ValidatedAt: time.Now()},
+		},
+		{
+			name:   "miss",
+			opts:   []Option{WithCacheStatus()},
+			result: validator.ValidationResult{ValidatedAt: time.Now()},
+			want:   "miss",
+		},
+		{
+			name:    "hit",
+			opts:    []Option{WithCacheStatus()},
+			result:  validator.ValidationResult{FromCache: true, ValidatedAt: time.Now().Add(-90 * time.Second)},
+			want:    "hit",
+			wantAge: "90",
+		},
+	}
 
-	// Should trim whitespace from the first IP.
-	expected := "203.0.113.42"
-	if ip != expected {
-		t.Fatalf("expected source IP %q, got %q", expected, ip)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(&mockValidator{
+				validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+					result := tt.result
+					result.Login = "octocat"
+					return &result, nil
+				},
+			}, slog.Default(), tt.opts...)
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer test-token")
+			rec := httptest.NewRecorder()
+			h.Routes().ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if got := rec.Header().Get("X-Auth-Cache"); got != tt.want {
+				t.Errorf("expected X-Auth-Cache %q, got %q", tt.want, got)
+			}
+			if got := rec.Header().Get("X-Auth-Cache-Age"); got != tt.wantAge {
+				t.Errorf("expected X-Auth-Cache-Age %q, got %q", tt.wantAge, got)
+			}
+		})
 	}
 }
 
-func TestGetSourceIP_XForwardedFor_Empty(t *testing.T) {
-	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
-	req.RemoteAddr = "10.0.0.5:12345"
-	req.Header.Set("X-Forwarded-For", "")
-
-	ip := getSourceIP(req)
+func TestValidate_OrgRole(t *testing.T) {
+	tests := []struct {
+		name string
+		role string
+		want []string
+	}{
+		{"admin", "admin", []string{"admin"}},
+		{"member", "member", []string{"member"}},
+		{"not fetched", "", nil},
+	}
 
-	// Empty X-Forwarded-For should fall back to RemoteAddr.
-	expected := "10.0.0.5"
-	if ip != expected {
-		t.Fatalf("expected source IP %q, got %q", expected, ip)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestHandler(&mockValidator{
+				validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+					return &validator.ValidationResult{Login: "octocat", ID: 1, Org: "test-org", OrgRole: tt.role}, nil
+				},
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer test-token")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if got := rec.Header().Values("X-Auth-User-Org-Role"); !slices.Equal(got, tt.want) {
+				t.Errorf("expected X-Auth-User-Org-Role %q, got %q", tt.want, got)
+			}
+		})
 	}
 }
 
-func TestValidate_RateLimited(t *testing.T) {
-	handler := newTestHandler(&mockValidator{
-		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
-			return nil, fmt.Errorf("%w", validator.ErrRateLimited)
-		},
-	})
-
-	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
-	req.Header.Set("Authorization", "Bearer test-token")
-	rec := httptest.NewRecorder()
-
-	handler.ServeHTTP(rec, req)
-
-	if rec.Code != http.StatusTooManyRequests {
-		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+func TestValidate_TeamHeaders(t *testing.T) {
+	teamHeaders := map[string]string{
+		"admins": "X-Is-Admin",
+		"sre":    "x-is-sre",
 	}
 
-	var resp errorResponse
-	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
-		t.Fatalf("failed to decode response: %v", err)
+	tests := []struct {
+		name     string
+		result   validator.ValidationResult
+		wantHdrs map[string]string
+	}{
+		{
+			name:     "member of one team",
+			result:   validator.ValidationResult{Teams: []string{"Admins", "backend"}},
+			wantHdrs: map[string]string{"X-Is-Admin": "true", "X-Is-Sre": "false"},
+		},
+		{
+			name:     "member of no team",
+			result:   validator.ValidationResult{Teams: []string{"backend"}},
+			wantHdrs: map[string]string{"X-Is-Admin": "false", "X-Is-Sre": "false"},
+		},
+		{
+			name:     "teams degraded",
+			result:   validator.ValidationResult{TeamsDegraded: true},
+			wantHdrs: map[string]string{"X-Is-Admin": "", "X-Is-Sre": ""},
+		},
+		{
+			name:     "teams disabled",
+			result:   validator.ValidationResult{TeamsDisabled: true},
+			wantHdrs: map[string]string{"X-Is-Admin": "", "X-Is-Sre": ""},
+		},
 	}
-	if resp.Error != "rate limit exceeded, try again later" {
-		t.Fatalf("expected error %q, got %q", "rate limit exceeded, try again later", resp.Error)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(&mockValidator{
+				validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+					res := tt.result
+					res.Login, res.ID, res.Org = "octocat", 12345, "test-org"
+					return &res, nil
+				},
+			}, slog.Default(), WithTeamHeaders(teamHeaders))
+			handler := h.Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer test-token")
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			for name, want := range tt.wantHdrs {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("expected %s %q, got %q", name, want, got)
+				}
+			}
+		})
 	}
 }
 
-func TestValidate_HeaderInjection_Login(t *testing.T) {
-	handler := newTestHandler(&mockValidator{
+func TestValidate_TeamHeadersInjected(t *testing.T) {
+	h := New(&mockValidator{
 		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
-			t.Fatal("validator should not be called when auth headers are injected")
+			t.Fatal("validator should not be called when team headers are injected")
 			return nil, nil
 		},
-	})
+	}, slog.Default(), WithTeamHeaders(map[string]string{"admins": "X-Is-Admin"}))
+	handler := h.Routes()
 
 	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
-	req.Header.Set("Authorization", "Bearer valid-token")
-	req.Header.Set("X-Auth-User-Login", "admin")
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("X-Is-Admin", "true")
 	rec := httptest.NewRecorder()
 
 	handler.ServeHTTP(rec, req)
@@ -500,33 +1060,1278 @@ func TestValidate_HeaderInjection_Login(t *testing.T) {
 	if rec.Code != http.StatusForbidden {
 		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
 	}
-
-	var resp errorResponse
-	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
-		t.Fatalf("failed to decode response: %v", err)
-	}
-	if resp.Error != "forbidden: request contains disallowed headers" {
-		t.Fatalf("expected error %q, got %q", "forbidden: request contains disallowed headers", resp.Error)
-	}
 }
 
-func TestValidate_HeaderInjection_Teams(t *testing.T) {
+func TestValidate_MultipleTeams(t *testing.T) {
 	handler := newTestHandler(&mockValidator{
 		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
-			t.Fatal("validator should not be called when auth headers are injected")
-			return nil, nil
+			return &validator.ValidationResult{
+				Login: "octocat",
+				ID:    12345,
+				Org:   "test-org",
+				Teams: []string{"engineering", "security", "platform"},
+			}, nil
 		},
 	})
 
 	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
-	req.Header.Set("Authorization", "Bearer valid-token")
-	req.Header.Set("X-Auth-User-Teams", "admin-team")
+	req.Header.Set("Authorization", "Bearer test-token")
 	rec := httptest.NewRecorder()
 
 	handler.ServeHTTP(rec, req)
 
-	if rec.Code != http.StatusForbidden {
-		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	expected := "engineering,security,platform"
+	if got := rec.Header().Get("X-Auth-User-Teams"); got != expected {
+		t.Fatalf("expected X-Auth-User-Teams %q, got %q", expected, got)
+	}
+}
+
+func TestGetSourceIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		trusted    []netip.Prefix
+		want       string
+	}{
+		{
+			name:       "single IP from trusted proxy",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"203.0.113.42"},
+			trusted:    trusted,
+			want:       "203.0.113.42",
+		},
+		{
+			name:       "untrusted remote ignores XFF",
+			remoteAddr: "198.51.100.7:12345",
+			xff:        []string{"203.0.113.42"},
+			trusted:    trusted,
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "no trusted proxies ignores XFF",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"203.0.113.42"},
+			want:       "10.0.0.5",
+		},
+		{
+			name:       "right-to-left walk skips trusted hops",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"203.0.113.42, 198.51.100.1, 192.0.2.1, 10.1.2.3"},
+			trusted:    trusted,
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "spoofed leftmost entry is not returned",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"1.2.3.4, 203.0.113.42"},
+			trusted:    trusted,
+			want:       "203.0.113.42",
+		},
+		{
+			name:       "all hops trusted returns leftmost",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"192.0.2.9, 10.1.2.3"},
+			trusted:    trusted,
+			want:       "192.0.2.9",
+		},
+		{
+			name:       "multiple header lines",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"203.0.113.42", "192.0.2.1"},
+			trusted:    trusted,
+			want:       "203.0.113.42",
+		},
+		{
+			name:       "whitespace is trimmed",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"  203.0.113.42  ,  192.0.2.1  "},
+			trusted:    trusted,
+			want:       "203.0.113.42",
+		},
+		{
+			name:       "no XFF",
+			remoteAddr: "10.0.0.5:12345",
+			trusted:    trusted,
+			want:       "10.0.0.5",
+		},
+		{
+			name:       "empty XFF",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{""},
+			trusted:    trusted,
+			want:       "10.0.0.5",
+		},
+		{
+			name:       "IPv6 trusted proxy",
+			remoteAddr: "[2001:db8::1]:443",
+			xff:        []string{"203.0.113.42"},
+			trusted:    trusted,
+			want:       "203.0.113.42",
+		},
+		{
+			name:       "IPv4-mapped IPv6 remote",
+			remoteAddr: "[::ffff:10.0.0.5]:443",
+			xff:        []string{"203.0.113.42"},
+			trusted:    trusted,
+			want:       "203.0.113.42",
+		},
+		{
+			name:       "IPv6 XFF is canonicalized",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"2001:DB9:0:0::42"},
+			trusted:    trusted,
+			want:       "2001:db9::42",
+		},
+		{
+			name:       "bracketed IPv6 XFF",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"[2001:db9::42]"},
+			trusted:    trusted,
+			want:       "2001:db9::42",
+		},
+		{
+			name:       "bracketed IPv6 XFF with port",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"[2001:db9::42]:8443"},
+			trusted:    trusted,
+			want:       "2001:db9::42",
+		},
+		{
+			name:       "zoned IPv6 XFF",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"fe80::1%eth0"},
+			trusted:    trusted,
+			want:       "fe80::1",
+		},
+		{
+			name:       "bracketed trusted IPv6 hop is skipped",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"2001:db9::42, [2001:db8::7]"},
+			trusted:    trusted,
+			want:       "2001:db9::42",
+		},
+		{
+			name:       "zoned IPv6 remote",
+			remoteAddr: "[fe80::1%eth0]:443",
+			xff:        []string{"203.0.113.42"},
+			want:       "fe80::1",
+		},
+		{
+			name:       "unparseable XFF is returned",
+			remoteAddr: "10.0.0.5:12345",
+			xff:        []string{"unknown"},
+			trusted:    trusted,
+			want:       "unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+
+			if got := getSourceIP(req, tt.trusted); got != tt.want {
+				t.Fatalf("expected source IP %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidate_RateLimited(t *testing.T) {
+	handler := newTestHandler(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return nil, fmt.Errorf("%w", validator.ErrRateLimited)
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+
+	var resp errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error != "rate limit exceeded, try again later" {
+		t.Fatalf("expected error %q, got %q", "rate limit exceeded, try again later", resp.Error)
+	}
+}
+
+func TestValidate_CircuitOpen(t *testing.T) {
+	handler := newTestHandler(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return nil, fmt.Errorf("%w", validator.ErrCircuitOpen)
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	var resp errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error != "service unavailable, try again later" {
+		t.Fatalf("expected error %q, got %q", "service unavailable, try again later", resp.Error)
+	}
+}
+
+func TestValidate_HeaderInjection_Login(t *testing.T) {
+	handler := newTestHandler(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			t.Fatal("validator should not be called when auth headers are injected")
+			return nil, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	req.Header.Set("X-Auth-User-Login", "admin")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	var resp errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error != "forbidden: request contains disallowed headers" {
+		t.Fatalf("expected error %q, got %q", "forbidden: request contains disallowed headers", resp.Error)
+	}
+}
+
+func TestValidate_HeaderInjection_NonCanonical(t *testing.T) {
+	mv := &mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			t.Fatal("validator should not be called when auth headers are injected")
+			return nil, nil
+		},
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		key  string
+	}{
+		{name: "lowercase teams", key: "x-auth-user-teams"},
+		{name: "mixed case login", key: "X-AUTH-user-Login"},
+		{name: "custom prefix", opts: []Option{WithHeaderPrefix("X-Forwarded-User-")}, key: "x-forwarded-user-login"},
+		{name: "team header", opts: []Option{WithTeamHeaders(map[string]string{"admins": "X-Is-Admin"})}, key: "x-is-admin"},
+		{name: "signature", opts: []Option{WithHeaderSigningKey([]byte("key"))}, key: "x-auth-signature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(mv, slog.Default(), tt.opts...).Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			// Bypass canonicalization, as a raw HTTP/2 header map could.
+			req.Header[tt.key] = []string{"admin"}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+			}
+		})
+	}
+}
+
+func TestValidate_RejectInjectedHeaders(t *testing.T) {
+	mv := &mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return &validator.ValidationResult{Login: "octocat", ID: 1, Org: "test-org"}, nil
+		},
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantStatus int
+		wantLogin  string
+	}{
+		{"default rejects", nil, http.StatusForbidden, ""},
+		{"explicitly enabled", []Option{WithRejectInjectedHeaders(true)}, http.StatusForbidden, ""},
+		{"disabled overwrites", []Option{WithRejectInjectedHeaders(false)}, http.StatusOK, "octocat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(mv, slog.Default(), tt.opts...).Routes()
+
+			// Simulate a retry that replays a header previously set by
+			// this service.
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			req.Header.Set("X-Auth-User-Login", "octocat")
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("X-Auth-User-Login"); got != tt.wantLogin {
+				t.Errorf("expected X-Auth-User-Login %q, got %q", tt.wantLogin, got)
+			}
+		})
+	}
+}
+
+func TestValidate_CORSPreflight(t *testing.T) {
+	mv := &mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			t.Fatal("validator should not be called for a preflight request")
+			return nil, nil
+		},
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		method     string
+		header     http.Header
+		wantStatus int
+		wantOrigin string
+	}{
+		{
+			name:       "preflight",
+			opts:       []Option{WithCORSAllowOrigin("https://app.example.com")},
+			method:     http.MethodOptions,
+			header:     http.Header{"Origin": {"https://app.example.com"}, "Access-Control-Request-Method": {"PUT"}, "Access-Control-Request-Headers": {"authorization"}},
+			wantStatus: http.StatusNoContent,
+			wantOrigin: "https://app.example.com",
+		},
+		{
+			name:       "forwarded preflight any origin",
+			opts:       []Option{WithCORSAllowOrigin("*")},
+			method:     http.MethodGet,
+			header:     http.Header{"X-Forwarded-Method": {"OPTIONS"}, "Origin": {"https://other.example.com"}, "Access-Control-Request-Method": {"PUT"}},
+			wantStatus: http.StatusNoContent,
+			wantOrigin: "*",
+		},
+		{
+			name:       "origin not allowed",
+			opts:       []Option{WithCORSAllowOrigin("https://app.example.com")},
+			method:     http.MethodOptions,
+			header:     http.Header{"Origin": {"https://evil.example.com"}, "Access-Control-Request-Method": {"PUT"}},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "not configured",
+			method:     http.MethodOptions,
+			header:     http.Header{"Origin": {"https://app.example.com"}, "Access-Control-Request-Method": {"PUT"}},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(mv, slog.Default(), tt.opts...).Routes()
+
+			req := httptest.NewRequest(tt.method, "/validate", nil)
+			req.Header = tt.header
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.wantOrigin, got)
+			}
+			if tt.wantStatus != http.StatusNoContent {
+				return
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "PUT" {
+				t.Errorf("expected Access-Control-Allow-Methods %q, got %q", "PUT", got)
+			}
+			if got, want := rec.Header().Get("Access-Control-Allow-Headers"), tt.header.Get("Access-Control-Request-Headers"); got != want {
+				t.Errorf("expected Access-Control-Allow-Headers %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestValidate_MaxTokenLength(t *testing.T) {
+	var calls int
+	mv := &mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			calls++
+			return &validator.ValidationResult{Login: "octocat", ID: 1, Org: "test-org"}, nil
+		},
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		token      string
+		wantStatus int
+		wantCalls  int
+	}{
+		{name: "default limit", token: strings.Repeat("a", defaultMaxTokenLength), wantStatus: http.StatusOK, wantCalls: 1},
+		{name: "over default limit", token: strings.Repeat("a", defaultMaxTokenLength+1), wantStatus: http.StatusUnauthorized},
+		{name: "over custom limit", opts: []Option{WithMaxTokenLength(10)}, token: "github_pat_0123", wantStatus: http.StatusUnauthorized},
+		{name: "disabled", opts: []Option{WithMaxTokenLength(0)}, token: strings.Repeat("a", 1<<16), wantStatus: http.StatusOK, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			handler := New(mv, slog.Default(), tt.opts...).Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("expected %d validator calls, got %d", tt.wantCalls, calls)
+			}
+			if tt.wantStatus == http.StatusUnauthorized {
+				var resp errorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+					t.Fatalf("decoding response: %v", err)
+				}
+				if resp.Code != codeTokenTooLong {
+					t.Errorf("expected code %q, got %q", codeTokenTooLong, resp.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_Methods(t *testing.T) {
+	var calls int
+	mv := &mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			calls++
+			return &validator.ValidationResult{Login: "octocat", ID: 1, Org: "test-org"}, nil
+		},
+	}
+	srv := httptest.NewServer(New(mv, slog.Default()).Routes())
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		wantStatus int
+		wantCalls  int
+		wantLogin  string
+	}{
+		{name: "HEAD valid token", method: http.MethodHead, token: "valid-token", wantStatus: http.StatusOK, wantCalls: 1, wantLogin: "octocat"},
+		{name: "HEAD missing token", method: http.MethodHead, wantStatus: http.StatusUnauthorized},
+		{name: "POST", method: http.MethodPost, token: "valid-token", wantStatus: http.StatusMethodNotAllowed},
+		{name: "DELETE", method: http.MethodDelete, token: "valid-token", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			req, err := http.NewRequest(tt.method, srv.URL+"/validate", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("expected %d validator calls, got %d", tt.wantCalls, calls)
+			}
+			if got := resp.Header.Get("X-Auth-User-Login"); got != tt.wantLogin {
+				t.Errorf("expected X-Auth-User-Login %q, got %q", tt.wantLogin, got)
+			}
+			if tt.method == http.MethodHead && len(body) != 0 {
+				t.Errorf("expected no body for HEAD, got %q", body)
+			}
+		})
+	}
+}
+
+func TestValidate_Email(t *testing.T) {
+	mv := &mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return &validator.ValidationResult{Login: "octocat", ID: 1, Email: "octocat@github.com", Org: "test-org"}, nil
+		},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		opts      []Option
+		wantEmail string
+	}{
+		{"default", nil, "octocat@github.com"},
+		{"enabled", []Option{WithEmail(true)}, "octocat@github.com"},
+		{"disabled", []Option{WithEmail(false)}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := New(mv, slog.Default(), tc.opts...).Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+			if got := rec.Header().Get("X-Auth-User-Email"); got != tc.wantEmail {
+				t.Errorf("expected X-Auth-User-Email %q, got %q", tc.wantEmail, got)
+			}
+			for _, name := range []string{"X-Auth-User-Login", "X-Auth-User-Id", "X-Auth-User-Org"} {
+				if rec.Header().Get(name) == "" {
+					t.Errorf("expected %s to be set", name)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_EnableEmailEndToEnd(t *testing.T) {
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/user":
+			fmt.Fprint(w, `{"login":"octocat","id":1,"email":"octocat@github.com"}`)
+		case "/orgs/test-org/members/octocat":
+			w.WriteHeader(http.StatusNoContent)
+		case "/user/teams":
+			fmt.Fprint(w, `[]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer gh.Close()
+
+	for _, tc := range []struct {
+		name      string
+		enabled   bool
+		wantEmail string
+	}{
+		{"enabled", true, "octocat@github.com"},
+		{"disabled", false, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ghOpts := []github.Option{github.WithBaseURL(gh.URL)}
+			var validatorOpts []validator.Option
+			if !tc.enabled {
+				ghOpts = append(ghOpts, github.WithoutEmail())
+				validatorOpts = append(validatorOpts, validator.WithoutEmail())
+			}
+			tokenCache := cache.New(time.Minute, 10, cache.WithoutCleanup())
+			v := validator.New(github.NewHTTPClient(ghOpts...), tokenCache, []string{"test-org"}, false, slog.Default(), validatorOpts...)
+			handler := New(v, slog.Default(), WithEmail(tc.enabled)).Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("X-Auth-User-Email"); got != tc.wantEmail {
+				t.Errorf("expected X-Auth-User-Email %q, got %q", tc.wantEmail, got)
+			}
+		})
+	}
+}
+
+func TestValidate_UserFields(t *testing.T) {
+	mv := &mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return &validator.ValidationResult{
+				Login:     "octocat",
+				ID:        1,
+				Org:       "test-org",
+				NodeID:    "MDQ6VXNlcjE=",
+				AvatarURL: "https://avatars.githubusercontent.com/u/1",
+			}, nil
+		},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		opts       []Option
+		wantNodeID string
+		wantAvatar string
+	}{
+		{"default", nil, "", ""},
+		{"node id", []Option{WithUserFields([]UserField{UserFieldNodeID})}, "MDQ6VXNlcjE=", ""},
+		{"both", []Option{WithUserFields([]UserField{UserFieldNodeID, UserFieldAvatarURL})}, "MDQ6VXNlcjE=", "https://avatars.githubusercontent.com/u/1"},
+		{"unknown field", []Option{WithUserFields([]UserField{"site_admin"})}, "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := New(mv, slog.Default(), tc.opts...).Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+			if got := rec.Header().Get("X-Auth-User-Node-Id"); got != tc.wantNodeID {
+				t.Errorf("expected X-Auth-User-Node-Id %q, got %q", tc.wantNodeID, got)
+			}
+			if got := rec.Header().Get("X-Auth-User-Avatar-Url"); got != tc.wantAvatar {
+				t.Errorf("expected X-Auth-User-Avatar-Url %q, got %q", tc.wantAvatar, got)
+			}
+		})
+	}
+}
+
+func TestValidate_SuccessStatus(t *testing.T) {
+	mv := &mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return &validator.ValidationResult{Login: "octocat", ID: 1, Org: "test-org"}, nil
+		},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		opts       []Option
+		wantStatus int
+	}{
+		{"default", nil, http.StatusOK},
+		{"no content", []Option{WithSuccessStatus(http.StatusNoContent)}, http.StatusNoContent},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := New(mv, slog.Default(), tc.opts...).Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("X-Auth-User-Login"); got != "octocat" {
+				t.Errorf("expected X-Auth-User-Login %q, got %q", "octocat", got)
+			}
+		})
+	}
+}
+
+func TestValidate_HeaderSigning(t *testing.T) {
+	key := []byte("test-signing-key")
+	mv := &mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return &validator.ValidationResult{
+				Login:     "octocat",
+				ID:        1,
+				Org:       "test-org",
+				OrgRole:   "member",
+				Email:     "octocat@github.com",
+				NodeID:    "MDQ6VXNlcjE=",
+				AvatarURL: "https://avatars.githubusercontent.com/u/1",
+				Teams:     []string{"backend", "frontend"},
+				TeamNames: []string{"Backend", "Frontend"},
+			}, nil
+		},
+	}
+
+	t.Run("signed", func(t *testing.T) {
+		handler := New(mv, slog.Default(),
+			WithHeaderSigningKey(key),
+			WithHeaderPrefix("X-Forwarded-User-"),
+			WithTeamNames(),
+			WithUserFields([]UserField{UserFieldNodeID, UserFieldAvatarURL}),
+			WithTeamHeaders(map[string]string{"admins": "X-Is-Admin"}),
+		).Routes()
+
+		req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+		req.Header.Set("Authorization", "Bearer valid-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		if err := signature.Verify(key, rec.Header(), "X-Forwarded-User-", "X-Is-Admin"); err != nil {
+			t.Fatalf("expected a valid signature, got %v", err)
+		}
+
+		// Changing any emitted identity header is detected.
+		emitted := []string{
+			"X-Forwarded-User-Login",
+			"X-Forwarded-User-Id",
+			"X-Forwarded-User-Org",
+			"X-Forwarded-User-Org-Role",
+			"X-Forwarded-User-Email",
+			"X-Forwarded-User-Node-Id",
+			"X-Forwarded-User-Avatar-Url",
+			"X-Forwarded-User-Teams",
+			"X-Forwarded-User-Team-Names",
+			"X-Auth-Teams-Status",
+			"X-Is-Admin",
+		}
+		for _, name := range emitted {
+			h := rec.Header().Clone()
+			if h.Get(name) == "" {
+				t.Fatalf("expected %s to be emitted", name)
+			}
+			h.Set(name, "tampered")
+			if err := signature.Verify(key, h, "X-Forwarded-User-", "X-Is-Admin"); !errors.Is(err, signature.ErrInvalidSignature) {
+				t.Errorf("%s: expected ErrInvalidSignature after tampering, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("unsigned by default", func(t *testing.T) {
+		handler := New(mv, slog.Default()).Routes()
+
+		req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+		req.Header.Set("Authorization", "Bearer valid-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get(signature.Header); got != "" {
+			t.Fatalf("expected no signature header, got %q", got)
+		}
+	})
+
+	t.Run("injected signature rejected", func(t *testing.T) {
+		handler := New(mv, slog.Default(), WithHeaderSigningKey(key)).Routes()
+
+		req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+		req.Header.Set("Authorization", "Bearer valid-token")
+		req.Header.Set(signature.Header, "sha256=00")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+		}
+	})
+}
+
+func TestValidate_HeaderInjection_Teams(t *testing.T) {
+	handler := newTestHandler(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			t.Fatal("validator should not be called when auth headers are injected")
+			return nil, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	req.Header.Set("X-Auth-User-Teams", "admin-team")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestValidate_HeaderPrefix(t *testing.T) {
+	h := New(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return &validator.ValidationResult{
+				Login: "octocat",
+				ID:    12345,
+				Org:   "test-org",
+				Teams: []string{"sre"},
+			}, nil
+		},
+	}, slog.Default(), WithHeaderPrefix("x-forwarded-user-"))
+	handler := h.Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	for name, want := range map[string]string{
+		"X-Forwarded-User-Login": "octocat",
+		"X-Forwarded-User-Id":    "12345",
+		"X-Forwarded-User-Org":   "test-org",
+		"X-Forwarded-User-Teams": "sre",
+		"X-Auth-User-Login":      "",
+	} {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("expected %s %q, got %q", name, want, got)
+		}
+	}
+
+	// Headers carrying the custom prefix are rejected as injected.
+	req = httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("X-Forwarded-User-Login", "admin")
+	rec = httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestValidate_TokenCookie(t *testing.T) {
+	tests := []struct {
+		name       string
+		authHeader string
+		cookie     string
+		wantCode   int
+		wantToken  string
+	}{
+		{"cookie only", "", "cookie-token", http.StatusOK, "cookie-token"},
+		{"header only", "Bearer header-token", "", http.StatusOK, "header-token"},
+		{"header wins over cookie", "Bearer header-token", "cookie-token", http.StatusOK, "header-token"},
+		{"malformed header does not fall back", "Basic abc", "cookie-token", http.StatusUnauthorized, ""},
+		{"empty cookie", "", "", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotToken string
+			h := New(&mockValidator{
+				validateFunc: func(_ context.Context, token string) (*validator.ValidationResult, error) {
+					gotToken = token
+					return &validator.ValidationResult{Login: "octocat", ID: 12345, Org: "test-org"}, nil
+				},
+			}, slog.Default(), WithTokenCookie("gh_token"))
+			handler := h.Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "gh_token", Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if gotToken != tt.wantToken {
+				t.Fatalf("expected token %q, got %q", tt.wantToken, gotToken)
+			}
+		})
+	}
+}
+
+func TestValidate_ForwardedAuthHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		authHeader string
+		forwarded  string
+		cookie     string
+		wantCode   int
+		wantToken  string
+	}{
+		{"forwarded only", "", "Bearer forwarded-token", "", http.StatusOK, "forwarded-token"},
+		{"authorization wins", "Bearer header-token", "Bearer forwarded-token", "", http.StatusOK, "header-token"},
+		{"forwarded wins over cookie", "", "Bearer forwarded-token", "cookie-token", http.StatusOK, "forwarded-token"},
+		{"malformed forwarded", "", "Basic abc", "", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotToken string
+			h := New(&mockValidator{
+				validateFunc: func(_ context.Context, token string) (*validator.ValidationResult, error) {
+					gotToken = token
+					return &validator.ValidationResult{Login: "octocat", ID: 12345, Org: "test-org"}, nil
+				},
+			}, slog.Default(), WithForwardedAuthHeader("X-Forwarded-Authorization"), WithTokenCookie("gh_token"))
+			handler := h.Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-Authorization", tt.forwarded)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "gh_token", Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if gotToken != tt.wantToken {
+				t.Fatalf("expected token %q, got %q", tt.wantToken, gotToken)
+			}
+		})
+	}
+}
+
+func TestValidate_ForwardedAuthHeaderNotConfigured(t *testing.T) {
+	handler := newTestHandler(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			t.Fatal("validator should not be called without a forwarded auth header option")
+			return nil, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("X-Forwarded-Authorization", "Bearer forwarded-token")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestValidate_TokenCookieNotConfigured(t *testing.T) {
+	handler := newTestHandler(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			t.Fatal("validator should not be called without a token cookie option")
+			return nil, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.AddCookie(&http.Cookie{Name: "gh_token", Value: "cookie-token"})
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+// fakeReadiness implements ReadinessChecker for testing.
+type fakeReadiness bool
+
+func (f fakeReadiness) Ready() bool { return bool(f) }
+
+func TestReady_ReadinessChecker(t *testing.T) {
+	tests := []struct {
+		name     string
+		ready    bool
+		wantCode int
+	}{
+		{"ready", true, http.StatusOK},
+		{"not ready", false, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(&mockValidator{}, slog.Default(), WithReadinessChecker(fakeReadiness(tt.ready)))
+			handler := h.Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected /ready status %d, got %d", tt.wantCode, rec.Code)
+			}
+
+			// Liveness is unaffected by readiness.
+			req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected /healthz status %d, got %d", http.StatusOK, rec.Code)
+			}
+		})
+	}
+}
+
+func TestValidate_Draining(t *testing.T) {
+	h := New(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			t.Fatal("validator should not be called while draining")
+			return nil, nil
+		},
+	}, slog.Default())
+	h.StartDraining()
+	handler := h.Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	for _, path := range []string{"/healthz", "/ready"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", path, http.StatusOK, rec.Code)
+		}
+	}
+}
+
+func TestReady_ShuttingDown(t *testing.T) {
+	h := New(&mockValidator{
+		validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+			return &validator.ValidationResult{Login: "octocat", ID: 12345, Org: "test-org"}, nil
+		},
+	}, slog.Default())
+	h.StartShutdown()
+	handler := h.Routes()
+
+	tests := []struct {
+		path     string
+		wantCode int
+	}{
+		{"/ready", http.StatusServiceUnavailable},
+		{"/healthz", http.StatusOK},
+		{"/validate", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer test-token")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.wantCode, rec.Code)
+		}
+	}
+}
+
+func TestValidate_ClientCanceled(t *testing.T) {
+	var logBuf bytes.Buffer
+	h := New(&mockValidator{
+		validateFunc: func(ctx context.Context, _ string) (*validator.ValidationResult, error) {
+			<-ctx.Done()
+			return nil, fmt.Errorf("getting user: %w", ctx.Err())
+		},
+	}, slog.New(slog.NewJSONHandler(&logBuf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	handler := h.Routes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != statusClientClosedRequest {
+		t.Fatalf("expected status %d, got %d", statusClientClosedRequest, rec.Code)
+	}
+	if containsString(logBuf.String(), `"level":"ERROR"`) {
+		t.Fatalf("expected no error-level log, got: %s", logBuf.String())
+	}
+}
+
+func TestValidate_RequestID(t *testing.T) {
+	var logBuf bytes.Buffer
+	var gotID string
+	h := New(&mockValidator{
+		validateFunc: func(ctx context.Context, _ string) (*validator.ValidationResult, error) {
+			gotID = requestid.FromContext(ctx)
+			return nil, validator.ErrUnauthorized
+		},
+	}, slog.New(otelsetup.NewTraceHandler(slog.NewJSONHandler(&logBuf, nil))))
+	handler := h.Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set(requestid.Header, "traefik-req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if gotID != "traefik-req-1" {
+		t.Errorf("validator context request ID = %q, want %q", gotID, "traefik-req-1")
+	}
+	if !containsString(logBuf.String(), `"request.id":"traefik-req-1"`) {
+		t.Errorf("expected request.id in log output, got: %s", logBuf.String())
+	}
+
+	// A request ID is generated when Traefik does not send one.
+	req = httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if gotID == "" || gotID == "traefik-req-1" {
+		t.Errorf("expected a generated request ID, got %q", gotID)
+	}
+}
+
+func TestValidate_AuditLog(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		auth string
+		want map[string]any
+	}{
+		{
+			name: "success",
+			auth: "Bearer test-token",
+			want: map[string]any{
+				"event.outcome":             "success",
+				"user.name":                 "octocat",
+				"org":                       "test-org",
+				"result":                    "ok",
+				"reason":                    "authorized",
+				"http.response.status_code": float64(http.StatusOK),
+			},
+		},
+		{
+			name: "denied",
+			auth: "Bearer test-token",
+			err:  validator.ErrNotOrgMember,
+			want: map[string]any{
+				"event.outcome":             "failure",
+				"user.name":                 "",
+				"org":                       "",
+				"result":                    codeNotOrgMember,
+				"reason":                    validator.ErrNotOrgMember.Error(),
+				"http.response.status_code": float64(http.StatusForbidden),
+			},
+		},
+		{
+			name: "missing token",
+			want: map[string]any{
+				"event.outcome":             "failure",
+				"result":                    codeMissingToken,
+				"reason":                    "missing or malformed Authorization header",
+				"http.response.status_code": float64(http.StatusUnauthorized),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logBuf bytes.Buffer
+			h := New(&mockValidator{
+				validateFunc: func(_ context.Context, _ string) (*validator.ValidationResult, error) {
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return &validator.ValidationResult{Login: "octocat", ID: 1, Org: "test-org"}, nil
+				},
+			}, slog.New(slog.NewJSONHandler(&logBuf, nil)), WithAuditLog())
+
+			req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+			req.RemoteAddr = "192.0.2.10:4321"
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			h.Routes().ServeHTTP(httptest.NewRecorder(), req)
+
+			var records []map[string]any
+			dec := json.NewDecoder(&logBuf)
+			for dec.More() {
+				var rec map[string]any
+				if err := dec.Decode(&rec); err != nil {
+					t.Fatalf("failed to decode log record: %v", err)
+				}
+				if rec["msg"] == "Audit" {
+					records = append(records, rec)
+				}
+			}
+			if len(records) != 1 {
+				t.Fatalf("expected exactly one audit record, got %d", len(records))
+			}
+			got := records[0]
+			for _, field := range []string{"time", "event.action", "event.outcome", "source.ip", "user.name", "org", "result", "reason", "http.response.status_code"} {
+				if _, ok := got[field]; !ok {
+					t.Errorf("audit record missing field %q: %v", field, got)
+				}
+			}
+			if got["event.action"] != "github_auth" || got["source.ip"] != "192.0.2.10" {
+				t.Errorf("unexpected audit record: %v", got)
+			}
+			for k, want := range tt.want {
+				if got[k] != want {
+					t.Errorf("%s = %v, want %v", k, got[k], want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_SourceIPLogged(t *testing.T) {
+	var logBuf bytes.Buffer
+	var gotAttrs []slog.Attr
+	h := New(&mockValidator{
+		validateFunc: func(ctx context.Context, _ string) (*validator.ValidationResult, error) {
+			gotAttrs = logattr.FromContext(ctx)
+			return nil, validator.ErrUnauthorized
+		},
+	}, slog.New(otelsetup.NewContextHandler(slog.NewJSONHandler(&logBuf, nil))))
+	handler := h.Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/validate", nil)
+	req.RemoteAddr = "192.0.2.10:4321"
+	req.Header.Set("Authorization", "Bearer test-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// The validator receives the source IP so that its own logs carry it.
+	if len(gotAttrs) != 1 || gotAttrs[0].String() != "source.ip=192.0.2.10" {
+		t.Errorf("validator context attrs = %v, want [source.ip=192.0.2.10]", gotAttrs)
+	}
+	if !containsString(logBuf.String(), `"source.ip":"192.0.2.10"`) {
+		t.Errorf("expected source.ip in log output, got: %s", logBuf.String())
 	}
 }
 
@@ -0,0 +1,114 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package health provides a background prober used to gate readiness on
+// the reachability of a dependency such as the GitHub API.
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// CheckFunc probes a dependency. It returns a non-nil error if the
+// dependency is unreachable.
+type CheckFunc func(ctx context.Context) error
+
+// Prober periodically runs a CheckFunc and reports not ready once a number
+// of consecutive checks have failed. A single successful check restores
+// readiness. The Prober starts out ready so that a slow first check does
+// not hold back startup.
+type Prober struct {
+	check     CheckFunc
+	interval  time.Duration
+	threshold int
+	log       *slog.Logger
+
+	ready atomic.Bool
+
+	// failures is the number of consecutive failed checks. It is only
+	// accessed by the probe loop.
+	failures int
+
+	stop chan struct{}
+}
+
+// NewProber creates a Prober that runs check every interval and reports
+// not ready after threshold consecutive failures. A background goroutine
+// is started; call Stop to terminate it. A threshold below 1 is treated
+// as 1. Each check is bounded by the interval.
+func NewProber(check CheckFunc, interval time.Duration, threshold int, log *slog.Logger) *Prober {
+	if threshold < 1 {
+		threshold = 1
+	}
+	p := &Prober{
+		check:     check,
+		interval:  interval,
+		threshold: threshold,
+		log:       log,
+		stop:      make(chan struct{}),
+	}
+	p.ready.Store(true)
+
+	go p.loop()
+
+	return p
+}
+
+// loop runs the check every interval until Stop is called.
+func (p *Prober) loop() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.probe()
+		}
+	}
+}
+
+// probe runs a single check and updates the readiness state.
+func (p *Prober) probe() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+	defer cancel()
+
+	if err := p.check(ctx); err != nil {
+		p.failures++
+		p.log.Warn("Readiness probe failed",
+			slog.String("error", err.Error()),
+			slog.Int("consecutive_failures", p.failures),
+		)
+		if p.failures >= p.threshold && p.ready.Swap(false) {
+			p.log.Error("Marking service not ready",
+				slog.Int("consecutive_failures", p.failures),
+			)
+		}
+		return
+	}
+
+	p.failures = 0
+	if !p.ready.Swap(true) {
+		p.log.Info("Readiness probe recovered, marking service ready")
+	}
+}
+
+// Ready reports whether the dependency is considered reachable.
+func (p *Prober) Ready() bool {
+	return p.ready.Load()
+}
+
+// Stop terminates the background probe goroutine.
+func (p *Prober) Stop() {
+	select {
+	case <-p.stop:
+		// Already stopped.
+	default:
+		close(p.stop)
+	}
+}
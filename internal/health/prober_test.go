@@ -0,0 +1,83 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package health
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestProber_Threshold(t *testing.T) {
+	var failing atomic.Bool
+	check := func(context.Context) error {
+		if failing.Load() {
+			return errors.New("unreachable")
+		}
+		return nil
+	}
+
+	// Use a long interval so that only the explicit probes below run.
+	p := NewProber(check, time.Hour, 3, discardLogger())
+	defer p.Stop()
+
+	if !p.Ready() {
+		t.Fatal("expected prober to start ready")
+	}
+
+	failing.Store(true)
+	for i := 1; i <= 2; i++ {
+		p.probe()
+		if !p.Ready() {
+			t.Fatalf("expected ready after %d failure(s), below the threshold", i)
+		}
+	}
+	p.probe()
+	if p.Ready() {
+		t.Fatal("expected not ready after reaching the failure threshold")
+	}
+
+	failing.Store(false)
+	p.probe()
+	if !p.Ready() {
+		t.Fatal("expected ready after a successful probe")
+	}
+
+	// A success resets the consecutive failure count.
+	failing.Store(true)
+	p.probe()
+	if !p.Ready() {
+		t.Fatal("expected ready after a single failure following recovery")
+	}
+}
+
+func TestProber_Loop(t *testing.T) {
+	p := NewProber(func(context.Context) error {
+		return errors.New("unreachable")
+	}, 10*time.Millisecond, 1, discardLogger())
+	defer p.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the background probe to mark not ready")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestProber_StopIdempotent(t *testing.T) {
+	p := NewProber(func(context.Context) error { return nil }, time.Hour, 1, discardLogger())
+	p.Stop()
+	p.Stop()
+}
@@ -0,0 +1,34 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package logattr carries log attributes through a context so that they
+// are added to every record logged with that context, rather than being
+// repeated at each call site.
+package logattr
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying attrs in addition to any
+// attributes already carried by ctx.
+func NewContext(ctx context.Context, attrs ...slog.Attr) context.Context {
+	if len(attrs) == 0 {
+		return ctx
+	}
+	// Copy so that contexts derived from the same parent do not share a
+	// backing array.
+	all := slices.Concat(FromContext(ctx), attrs)
+	return context.WithValue(ctx, contextKey{}, all)
+}
+
+// FromContext returns the attributes carried by ctx, or nil if none.
+func FromContext(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return attrs
+}
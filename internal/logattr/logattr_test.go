@@ -0,0 +1,45 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package logattr
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestNewContext(t *testing.T) {
+	if attrs := FromContext(context.Background()); attrs != nil {
+		t.Fatalf("expected no attributes, got %v", attrs)
+	}
+
+	parent := NewContext(context.Background(), slog.String("source.ip", "10.0.0.1"))
+	a := NewContext(parent, slog.String("login", "alice"))
+	b := NewContext(parent, slog.String("login", "bob"))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []string
+	}{
+		{name: "parent", ctx: parent, want: []string{"source.ip=10.0.0.1"}},
+		{name: "child a", ctx: a, want: []string{"source.ip=10.0.0.1", "login=alice"}},
+		{name: "child b", ctx: b, want: []string{"source.ip=10.0.0.1", "login=bob"}},
+		{name: "no attrs", ctx: NewContext(parent), want: []string{"source.ip=10.0.0.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FromContext(tt.ctx)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i, a := range got {
+				if a.String() != tt.want[i] {
+					t.Errorf("attr %d = %q, want %q", i, a.String(), tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package otelsetup
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/andrewkroh/traefik-github-auth/internal/logattr"
+)
+
+// ContextHandler wraps a slog.Handler and adds the attributes carried by
+// the record's context (see logattr.NewContext).
+type ContextHandler struct {
+	inner slog.Handler
+}
+
+// NewContextHandler creates a new ContextHandler wrapping the given inner handler.
+func NewContextHandler(inner slog.Handler) *ContextHandler {
+	return &ContextHandler{inner: inner}
+}
+
+// Enabled delegates to the inner handler.
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle adds the attributes carried by ctx to the log record. Attributes
+// whose key the record already has are skipped so that an explicitly
+// logged value is not duplicated.
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := logattr.FromContext(ctx)
+	if len(attrs) == 0 {
+		return h.inner.Handle(ctx, record)
+	}
+	present := make(map[string]bool, record.NumAttrs())
+	record.Attrs(func(a slog.Attr) bool {
+		present[a.Key] = true
+		return true
+	})
+	for _, a := range attrs {
+		if !present[a.Key] {
+			record.AddAttrs(a)
+		}
+	}
+	return h.inner.Handle(ctx, record)
+}
+
+// WithAttrs returns a new ContextHandler wrapping the inner handler's WithAttrs result.
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return NewContextHandler(h.inner.WithAttrs(attrs))
+}
+
+// WithGroup returns a new ContextHandler wrapping the inner handler's WithGroup result.
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return NewContextHandler(h.inner.WithGroup(name))
+}
@@ -25,17 +25,40 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
 )
 
+// config holds optional Setup settings.
+type config struct {
+	metricReaders []metric.Reader
+}
+
+// Option configures Setup.
+type Option func(*config)
+
+// WithMetricReader registers an additional metric reader, such as the one
+// returned by NewPrometheusReader. When any reader is registered, metrics
+// are enabled even if OTEL_METRICS_EXPORTER is unset.
+func WithMetricReader(r metric.Reader) Option {
+	return func(c *config) {
+		c.metricReaders = append(c.metricReaders, r)
+	}
+}
+
 // Setup initializes OpenTelemetry with trace and metric providers.
 //
 // Traces are only enabled when OTEL_TRACES_EXPORTER is explicitly set
 // to a value other than "none". Metrics are only enabled when
-// OTEL_METRICS_EXPORTER is explicitly set to a value other than "none".
+// OTEL_METRICS_EXPORTER is explicitly set to a value other than "none"
+// or a reader is supplied with WithMetricReader.
 // The autoexport package handles exporter selection based on standard
 // OTel environment variables (e.g., OTEL_EXPORTER_OTLP_PROTOCOL for
 // gRPC vs HTTP).
 //
 // Returns a shutdown function that should be deferred by the caller.
-func Setup(ctx context.Context, serviceName, serviceVersion string) (shutdown func(context.Context) error, err error) {
+func Setup(ctx context.Context, serviceName, serviceVersion string, opts ...Option) (shutdown func(context.Context) error, err error) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	var shutdownFuncs []func(context.Context) error
 
 	shutdown = func(ctx context.Context) error {
@@ -88,17 +111,22 @@ func Setup(ctx context.Context, serviceName, serviceVersion string) (shutdown fu
 
 	// Metrics: Quiet opt-in. Only initialize if the user explicitly set an exporter.
 	// This prevents OTel from defaulting to 'otlp' and logging connection errors.
+	readers := cfg.metricReaders
 	metricsExporter := os.Getenv("OTEL_METRICS_EXPORTER")
 	if metricsExporter != "" && metricsExporter != "none" {
 		reader, err := autoexport.NewMetricReader(ctx)
 		if err != nil {
 			return shutdown, fmt.Errorf("failed to create metric reader: %w", err)
 		}
+		readers = append(readers, reader)
+	}
+	if len(readers) > 0 {
+		mpOpts := []metric.Option{metric.WithResource(res)}
+		for _, r := range readers {
+			mpOpts = append(mpOpts, metric.WithReader(r))
+		}
 
-		meterProvider := metric.NewMeterProvider(
-			metric.WithResource(res),
-			metric.WithReader(reader),
-		)
+		meterProvider := metric.NewMeterProvider(mpOpts...)
 		shutdownFuncs = append(shutdownFuncs, meterProvider.Shutdown)
 		otel.SetMeterProvider(meterProvider)
 
@@ -116,8 +144,29 @@ func Setup(ctx context.Context, serviceName, serviceVersion string) (shutdown fu
 	return shutdown, nil
 }
 
-// NewLogger creates a new slog.Logger with JSON output and trace context integration.
-func NewLogger(w io.Writer) *slog.Logger {
-	jsonHandler := slog.NewJSONHandler(w, nil)
-	return slog.New(NewTraceHandler(jsonHandler))
+// LogFormat selects the encoding of log records.
+type LogFormat string
+
+const (
+	// LogFormatJSON emits one JSON object per record. This is the default.
+	LogFormatJSON LogFormat = "json"
+
+	// LogFormatText emits logfmt-style key=value records, which are easier
+	// to read during local development.
+	LogFormatText LogFormat = "text"
+)
+
+// NewLogger creates a new slog.Logger with trace context integration that
+// also includes the attributes carried by each record's context.
+// Records are encoded as text if format is LogFormatText and as JSON
+// otherwise. Records below level are discarded.
+func NewLogger(w io.Writer, level slog.Level, format LogFormat) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	var inner slog.Handler
+	if format == LogFormatText {
+		inner = slog.NewTextHandler(w, opts)
+	} else {
+		inner = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(NewTraceHandler(NewContextHandler(inner)))
 }
@@ -8,12 +8,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/andrewkroh/traefik-github-auth/internal/handler"
+	"github.com/andrewkroh/traefik-github-auth/internal/logattr"
 )
 
 func TestSetup_NoEndpoint(t *testing.T) {
@@ -201,6 +210,57 @@ func TestTraceHandler_WithGroup(t *testing.T) {
 	}
 }
 
+func TestContextHandler(t *testing.T) {
+	inner := newCaptureHandler()
+	handler := NewContextHandler(inner)
+
+	ctx := logattr.NewContext(context.Background(),
+		slog.String("source.ip", "10.0.0.1"),
+		slog.String("login", "octocat"),
+	)
+	rec := slog.NewRecord(time.Now(), slog.LevelInfo, "test message", 0)
+	rec.AddAttrs(slog.String("key", "value"))
+
+	if err := handler.Handle(ctx, rec); err != nil {
+		t.Fatalf("Handle returned unexpected error: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, attr := range inner.attrs {
+		got[attr.Key] = attr.Value.String()
+	}
+	want := map[string]string{"key": "value", "source.ip": "10.0.0.1", "login": "octocat"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	// Attributes already on the record take precedence.
+	rec = slog.NewRecord(time.Now(), slog.LevelInfo, "explicit", 0)
+	rec.AddAttrs(slog.String("source.ip", "192.0.2.1"))
+	if err := handler.Handle(ctx, rec); err != nil {
+		t.Fatalf("Handle returned unexpected error: %v", err)
+	}
+	var ips []string
+	for _, attr := range inner.attrs {
+		if attr.Key == "source.ip" {
+			ips = append(ips, attr.Value.String())
+		}
+	}
+	if len(ips) != 1 || ips[0] != "192.0.2.1" {
+		t.Errorf("source.ip values = %v, want [192.0.2.1]", ips)
+	}
+
+	// A context without attributes leaves the record unchanged.
+	if err := handler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "bare", 0)); err != nil {
+		t.Fatalf("Handle returned unexpected error: %v", err)
+	}
+	if len(inner.attrs) != 0 {
+		t.Errorf("expected no attributes, got %v", inner.attrs)
+	}
+}
+
 func TestNewLogger(t *testing.T) {
 	// Create a tracer provider and start a span.
 	tp := sdktrace.NewTracerProvider()
@@ -217,7 +277,7 @@ func TestNewLogger(t *testing.T) {
 
 	// Create a logger writing to a buffer.
 	var buf bytes.Buffer
-	logger := NewLogger(&buf)
+	logger := NewLogger(&buf, slog.LevelInfo, LogFormatJSON)
 
 	// Log with the span context.
 	logger.InfoContext(ctx, "hello world")
@@ -250,3 +310,85 @@ func TestNewLogger(t *testing.T) {
 		t.Error("JSON output missing msg field")
 	}
 }
+
+func TestNewLogger_Level(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   slog.Level
+		wantLog bool
+	}{
+		{"debug level emits debug", slog.LevelDebug, true},
+		{"warn level drops debug", slog.LevelWarn, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := NewLogger(&buf, tt.level, LogFormatJSON)
+
+			logger.Debug("debug message")
+
+			if got := strings.Contains(buf.String(), "debug message"); got != tt.wantLog {
+				t.Fatalf("debug record emitted = %v, want %v; output: %s", got, tt.wantLog, buf.String())
+			}
+		})
+	}
+}
+
+func TestNewLogger_TextFormat(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "test-span")
+	defer span.End()
+	sc := trace.SpanContextFromContext(ctx)
+
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, slog.LevelInfo, LogFormatText)
+	logger.InfoContext(ctx, "hello world")
+
+	out := buf.String()
+	for _, want := range []string{
+		`msg="hello world"`,
+		"trace.id=" + sc.TraceID().String(),
+		"span.id=" + sc.SpanID().String(),
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("text output missing %q: %s", want, out)
+		}
+	}
+	if json.Valid(buf.Bytes()) {
+		t.Errorf("expected text output, got JSON: %s", out)
+	}
+}
+
+func TestNewPrometheusReader(t *testing.T) {
+	reader, metricsHandler, err := NewPrometheusReader()
+	if err != nil {
+		t.Fatalf("NewPrometheusReader returned unexpected error: %v", err)
+	}
+
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+
+	counter, err := mp.Meter("github.com/andrewkroh/traefik-github-auth/internal/validator").
+		Int64Counter("github_auth.validation.total")
+	if err != nil {
+		t.Fatalf("failed to create counter: %v", err)
+	}
+	counter.Add(context.Background(), 1)
+
+	routes := handler.New(nil, slog.Default(), handler.WithMetricsHandler(metricsHandler)).Routes()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+
+	routes.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	if !strings.Contains(string(body), "github_auth_validation_total") {
+		t.Fatalf("expected github_auth_validation_total series in output:\n%s", body)
+	}
+}
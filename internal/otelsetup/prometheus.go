@@ -0,0 +1,30 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package otelsetup
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+// NewPrometheusReader creates a metric reader backed by a dedicated
+// Prometheus registry and returns it along with an http.Handler that
+// serves the registry in the Prometheus exposition format. Pass the
+// reader to Setup using WithMetricReader.
+func NewPrometheusReader() (metric.Reader, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+
+	exporter, err := otelprom.New(otelprom.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create prometheus exporter: %w", err)
+	}
+
+	return exporter, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
+}
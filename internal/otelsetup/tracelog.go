@@ -9,9 +9,12 @@ import (
 	"log/slog"
 
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/andrewkroh/traefik-github-auth/internal/requestid"
 )
 
-// TraceHandler wraps a slog.Handler and adds trace context attributes.
+// TraceHandler wraps a slog.Handler and adds trace context and request ID
+// attributes.
 type TraceHandler struct {
 	inner slog.Handler
 }
@@ -27,8 +30,12 @@ func (h *TraceHandler) Enabled(ctx context.Context, level slog.Level) bool {
 }
 
 // Handle extracts span context from ctx and adds trace.id and span.id
-// as attributes to the log record when an active span is present.
+// as attributes to the log record when an active span is present. It also
+// adds request.id when ctx carries a request ID.
 func (h *TraceHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestid.FromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request.id", id))
+	}
 	sc := trace.SpanContextFromContext(ctx)
 	if sc.IsValid() {
 		record.AddAttrs(
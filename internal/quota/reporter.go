@@ -0,0 +1,112 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package quota periodically logs a summary of GitHub API usage so that
+// quota problems surface before requests start failing.
+package quota
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/andrewkroh/traefik-github-auth/internal/cache"
+	"github.com/andrewkroh/traefik-github-auth/internal/github"
+)
+
+// APIStatsSource provides cumulative GitHub API usage.
+type APIStatsSource interface {
+	Stats() github.Stats
+}
+
+// CacheStatsSource provides cumulative cache hit and miss counts.
+type CacheStatsSource interface {
+	Stats() cache.Stats
+}
+
+// Reporter logs a usage summary every interval. Counts in each summary
+// cover only the preceding interval.
+type Reporter struct {
+	api      APIStatsSource
+	cache    CacheStatsSource
+	interval time.Duration
+	log      *slog.Logger
+
+	// lastAPI and lastCache are the cumulative stats at the previous
+	// report. They are only accessed by the report loop.
+	lastAPI   github.Stats
+	lastCache cache.Stats
+
+	stop chan struct{}
+}
+
+// NewReporter creates a Reporter and starts a background goroutine that
+// logs a summary every interval. Call Stop to terminate it.
+func NewReporter(api APIStatsSource, c CacheStatsSource, interval time.Duration, log *slog.Logger) *Reporter {
+	r := &Reporter{
+		api:       api,
+		cache:     c,
+		interval:  interval,
+		log:       log,
+		lastAPI:   api.Stats(),
+		lastCache: c.Stats(),
+		stop:      make(chan struct{}),
+	}
+
+	go r.loop()
+
+	return r
+}
+
+// loop reports every interval until Stop is called.
+func (r *Reporter) loop() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.report()
+		}
+	}
+}
+
+// report logs the usage since the previous report.
+func (r *Reporter) report() {
+	api := r.api.Stats()
+	c := r.cache.Stats()
+
+	hits := c.Hits - r.lastCache.Hits
+	misses := c.Misses - r.lastCache.Misses
+	var hitRatio float64
+	if lookups := hits + misses; lookups > 0 {
+		hitRatio = float64(hits) / float64(lookups)
+	}
+
+	attrs := []any{
+		slog.Duration("interval", r.interval),
+		slog.Int64("api_calls", api.Requests-r.lastAPI.Requests),
+		slog.Int64("cache_hits", hits),
+		slog.Int64("cache_misses", misses),
+		slog.Float64("cache_hit_ratio", hitRatio),
+	}
+	if api.RateLimitRemaining >= 0 {
+		attrs = append(attrs, slog.Int64("rate_limit_remaining", api.RateLimitRemaining))
+	}
+	r.log.Info("GitHub API usage summary", attrs...)
+
+	r.lastAPI = api
+	r.lastCache = c
+}
+
+// Stop terminates the background report goroutine.
+func (r *Reporter) Stop() {
+	select {
+	case <-r.stop:
+		// Already stopped.
+	default:
+		close(r.stop)
+	}
+}
@@ -0,0 +1,89 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package quota
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewkroh/traefik-github-auth/internal/cache"
+	"github.com/andrewkroh/traefik-github-auth/internal/github"
+)
+
+// fakeAPI implements APIStatsSource for testing.
+type fakeAPI struct {
+	stats github.Stats
+}
+
+func (f *fakeAPI) Stats() github.Stats { return f.stats }
+
+// fakeCache implements CacheStatsSource for testing.
+type fakeCache struct {
+	stats cache.Stats
+}
+
+func (f *fakeCache) Stats() cache.Stats { return f.stats }
+
+func TestReporter_Report(t *testing.T) {
+	api := &fakeAPI{stats: github.Stats{Requests: 10, RateLimitRemaining: -1}}
+	c := &fakeCache{stats: cache.Stats{Hits: 5, Misses: 5}}
+
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	// Use a long interval so that only the explicit reports below run.
+	r := NewReporter(api, c, time.Hour, log)
+	defer r.Stop()
+
+	// Simulate activity since the reporter was created.
+	api.stats = github.Stats{Requests: 13, RateLimitRemaining: 4321}
+	c.stats = cache.Stats{Hits: 8, Misses: 6}
+	r.report()
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode log line %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"msg":                  "GitHub API usage summary",
+		"api_calls":            float64(3),
+		"cache_hits":           float64(3),
+		"cache_misses":         float64(1),
+		"cache_hit_ratio":      0.75,
+		"rate_limit_remaining": float64(4321),
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+
+	// A second report with no activity covers only the new interval.
+	buf.Reset()
+	r.report()
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode log line %q: %v", buf.String(), err)
+	}
+	if got["api_calls"] != float64(0) || got["cache_hit_ratio"] != float64(0) {
+		t.Errorf("expected an idle summary, got %v", got)
+	}
+}
+
+func TestReporter_UnknownRateLimitOmitted(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	r := NewReporter(&fakeAPI{stats: github.Stats{RateLimitRemaining: -1}}, &fakeCache{}, time.Hour, log)
+	defer r.Stop()
+	r.report()
+
+	if strings.Contains(buf.String(), "rate_limit_remaining") {
+		t.Fatalf("expected rate_limit_remaining to be omitted, got %s", buf.String())
+	}
+}
@@ -0,0 +1,57 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package requestid carries a per-request correlation ID through a
+// context so that it can be logged and forwarded to GitHub.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+)
+
+// Header is the HTTP header carrying the request ID. Traefik sets it when
+// its access log or tracing is configured to do so.
+const Header = "X-Request-Id"
+
+// maxLen is the maximum length of an accepted incoming request ID.
+const maxLen = 128
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, or "" if none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Resolve returns id if it is an acceptable request ID and otherwise a
+// newly generated one. IDs longer than 128 bytes or containing anything
+// other than printable ASCII are replaced so that they cannot be used to
+// forge log lines or headers.
+func Resolve(id string) string {
+	if valid(id) {
+		return id
+	}
+	return rand.Text()
+}
+
+// valid reports whether id is non-empty, at most maxLen bytes, and
+// printable ASCII.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
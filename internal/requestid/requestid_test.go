@@ -0,0 +1,51 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		wantKeep bool
+	}{
+		{name: "traefik id", id: "3f1c2a9e-8b4d-4e6f-9a0b-1c2d3e4f5a6b", wantKeep: true},
+		{name: "empty", id: ""},
+		{name: "too long", id: strings.Repeat("a", maxLen+1)},
+		{name: "newline", id: "abc\nlevel=ERROR"},
+		{name: "space", id: "abc def"},
+		{name: "non-ascii", id: "abcé"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Resolve(tt.id)
+			if tt.wantKeep {
+				if got != tt.id {
+					t.Errorf("Resolve(%q) = %q, want the input kept", tt.id, got)
+				}
+				return
+			}
+			if got == tt.id || !valid(got) {
+				t.Errorf("Resolve(%q) = %q, want a generated ID", tt.id, got)
+			}
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if id := FromContext(ctx); id != "" {
+		t.Errorf("FromContext() = %q, want empty", id)
+	}
+	if id := FromContext(NewContext(ctx, "abc")); id != "abc" {
+		t.Errorf("FromContext() = %q, want %q", id, "abc")
+	}
+}
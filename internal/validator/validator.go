@@ -10,6 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -18,14 +22,25 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/andrewkroh/traefik-github-auth/internal/github"
+	"github.com/andrewkroh/traefik-github-auth/internal/logattr"
 )
 
 // Sentinel errors returned by the Validator.
 var (
-	ErrUnauthorized = errors.New("unauthorized: invalid or revoked token")
-	ErrNotOrgMember = errors.New("forbidden: user is not a member of the organization")
-	ErrClassicPAT   = errors.New("forbidden: classic PATs are not allowed, use a fine-grained PAT")
-	ErrRateLimited  = errors.New("rate limited: GitHub API rate limit exceeded")
+	ErrUnauthorized   = errors.New("unauthorized: invalid or revoked token")
+	ErrForbiddenToken = errors.New("forbidden: token is not permitted to read the user profile")
+	ErrTokenExpired   = errors.New("unauthorized: token has expired or been revoked")
+	ErrNotOrgMember   = errors.New("forbidden: user is not a member of the organization")
+	ErrClassicPAT     = errors.New("forbidden: classic PATs are not allowed, use a fine-grained PAT")
+	ErrRateLimited    = errors.New("rate limited: GitHub API rate limit exceeded")
+	ErrCircuitOpen    = errors.New("unavailable: GitHub API circuit breaker is open")
+	ErrTimeout        = errors.New("timeout: token validation deadline exceeded")
+
+	ErrUpstreamUnavailable = errors.New("unavailable: GitHub API could not be reached")
+	ErrBadUpstreamResponse = errors.New("bad gateway: GitHub API returned a malformed response")
+
+	ErrLoginNotAllowed   = errors.New("forbidden: login does not match the allowed pattern")
+	ErrTeamNotAuthorized = errors.New("forbidden: user is not a member of any required team")
 )
 
 // Auth result attribute values used for OTel metrics and spans.
@@ -34,6 +49,13 @@ const (
 	resultUnauthorized = "unauthorized"
 	resultForbidden    = "forbidden"
 	resultError        = "error"
+	resultCanceled     = "canceled"
+)
+
+// Cache status attribute values used in the auth.decision span event.
+const (
+	cacheStatusHit  = "hit"
+	cacheStatusMiss = "miss"
 )
 
 // ValidationResult holds the outcome of a successful token validation.
@@ -44,12 +66,47 @@ type ValidationResult struct {
 	// ID is the GitHub user ID.
 	ID int64
 
-	// Org is the GitHub organization that was validated.
+	// Email is the user's public profile email, or "" if it is not public.
+	Email string
+
+	// NodeID is the user's global GraphQL node ID.
+	NodeID string
+
+	// AvatarURL is the URL of the user's avatar image.
+	AvatarURL string
+
+	// Org is the configured GitHub organization in which membership was
+	// confirmed.
 	Org string
 
-	// Teams contains the team slugs within the configured organization
-	// that the user belongs to.
+	// Teams contains the team slugs within Org that the user belongs to.
 	Teams []string
+
+	// TeamNames contains the display names of Teams, in the same order.
+	TeamNames []string
+
+	// OrgRole is the user's role in Org ("admin" or "member"), or "" if
+	// it was not fetched.
+	OrgRole string
+
+	// TeamsDisabled is true when team lookup was skipped, in which case
+	// Teams is always empty.
+	TeamsDisabled bool
+
+	// TeamsDegraded is true when the team lookup failed and the error was
+	// suppressed because teams are best-effort. Teams may be incomplete.
+	TeamsDegraded bool
+
+	// TokenExpiresAt is when the token expires, if GitHub reported it
+	// (fine-grained PATs). A cached result must not outlive the token.
+	TokenExpiresAt time.Time
+
+	// ValidatedAt is when the result was produced from GitHub's responses.
+	ValidatedAt time.Time
+
+	// FromCache is true when Validate served the result from the cache
+	// rather than from GitHub.
+	FromCache bool
 }
 
 // Cache defines the interface for caching validation results.
@@ -69,6 +126,10 @@ type Cache interface {
 	// Pass a non-nil err to cache a negative result (e.g., unauthorized).
 	Set(token string, result ValidationResult, err error)
 
+	// SetWithTTL is like Set but the entry expires after ttl instead of
+	// the cache's default TTL.
+	SetWithTTL(token string, result ValidationResult, err error, ttl time.Duration)
+
 	// Delete removes a cached entry for the given token.
 	Delete(token string)
 }
@@ -78,55 +139,275 @@ type Cache interface {
 type Validator struct {
 	github            github.Client
 	cache             Cache
-	org               string
+	identityCache     Cache
+	orgs              []string
 	rejectClassicPATs bool
+	loginPattern      *regexp.Regexp
+	tokenPrefixes     []string
+	requiredTeams     []string
+	disableTeams      bool
+	fetchOrgRole      bool
+	teamsBestEffort   bool
+	omitEmail         bool
+	errorCacheTTL     time.Duration
+	forbiddenCacheTTL time.Duration
+	timeout           time.Duration
+	denylist          Denylist
 	log               *slog.Logger
 
-	tracer          trace.Tracer
-	validationTotal metric.Int64Counter
+	tracer             trace.Tracer
+	validationTotal    metric.Int64Counter
+	validationDuration metric.Float64Histogram
+}
+
+// Denylist reports whether a token has been explicitly blocked.
+type Denylist interface {
+	Denied(token string) bool
+}
+
+// Option configures a Validator.
+type Option func(*Validator)
+
+// WithErrorCacheTTL briefly caches unexpected errors (network failures,
+// unexpected statuses, decode errors) for ttl so that a flapping GitHub
+// API is not hit by every retry. Keep ttl short so that recovery is not
+// masked. Canceled and timed-out requests are never cached. A ttl of 0
+// disables this.
+func WithErrorCacheTTL(ttl time.Duration) Option {
+	return func(v *Validator) {
+		v.errorCacheTTL = ttl
+	}
+}
+
+// WithForbiddenCacheTTL sets how long ErrNotOrgMember and ErrClassicPAT
+// outcomes are negatively cached. Keep ttl short so that a user who joins
+// the organization is not locked out for long. A ttl of 0 uses the cache's
+// default TTL.
+func WithForbiddenCacheTTL(ttl time.Duration) Option {
+	return func(v *Validator) {
+		v.forbiddenCacheTTL = ttl
+	}
+}
+
+// WithTimeout bounds each call to Validate, including all of its GitHub
+// API requests, to d. When the deadline is exceeded Validate returns an
+// error wrapping ErrTimeout. A d of 0 disables the bound.
+func WithTimeout(d time.Duration) Option {
+	return func(v *Validator) {
+		v.timeout = d
+	}
+}
+
+// WithIdentityCache caches the user identity from step 1 (GetUser) in c,
+// separately from the complete result in the main cache. A token whose
+// identity is cached skips GetUser but still has its organization
+// membership and teams checked once its main cache entry expires. Give c
+// a longer TTL than the main cache so that authorization changes are
+// picked up sooner without re-identifying the user. Only identities that
+// pass the classic PAT check are cached.
+func WithIdentityCache(c Cache) Option {
+	return func(v *Validator) {
+		v.identityCache = c
+	}
+}
+
+// WithDenylist rejects tokens on d with ErrUnauthorized before the cache
+// or GitHub is consulted, so that a blocked token takes effect at once.
+func WithDenylist(d Denylist) Option {
+	return func(v *Validator) {
+		v.denylist = d
+	}
+}
+
+// WithLoginPattern requires that the user's GitHub login match re.
+// Users whose login does not match are rejected with ErrLoginNotAllowed.
+func WithLoginPattern(re *regexp.Regexp) Option {
+	return func(v *Validator) {
+		v.loginPattern = re
+	}
+}
+
+// KnownTokenPrefixes are the prefixes of the GitHub token types that can
+// identify a user: fine-grained PATs, classic PATs, OAuth app tokens, and
+// GitHub App user-to-server tokens.
+var KnownTokenPrefixes = []string{"github_pat_", "ghp_", "gho_", "ghu_"}
+
+// WithTokenPrefixes rejects tokens that do not start with one of prefixes
+// with ErrUnauthorized without calling GitHub, and caches the rejection.
+// GitHub may introduce new token formats, so use this only when the
+// accepted token types are known (see KnownTokenPrefixes).
+func WithTokenPrefixes(prefixes []string) Option {
+	return func(v *Validator) {
+		v.tokenPrefixes = prefixes
+	}
+}
+
+// WithRequiredTeams restricts access to users who belong to at least one
+// of the given team slugs within the organization. Slugs are compared
+// case-insensitively. An empty list allows all org members. Combined with
+// WithTeamsDisabled, membership is checked per team via
+// CheckTeamMembership rather than by listing all of the user's teams.
+func WithRequiredTeams(teams []string) Option {
+	return func(v *Validator) {
+		v.requiredTeams = teams
+	}
+}
+
+// WithTeamsDisabled skips listing the user's teams. Results are returned
+// with an empty Teams slice and TeamsDisabled set.
+func WithTeamsDisabled() Option {
+	return func(v *Validator) {
+		v.disableTeams = true
+	}
+}
+
+// WithoutEmail leaves ValidationResult.Email empty even when the GitHub
+// client returns a profile email.
+func WithoutEmail() Option {
+	return func(v *Validator) {
+		v.omitEmail = true
+	}
+}
+
+// WithOrgRole fetches the user's role in the matched org via
+// GetOrgMembership, costing one more API call per validation, and returns
+// it in ValidationResult.OrgRole.
+func WithOrgRole() Option {
+	return func(v *Validator) {
+		v.fetchOrgRole = true
+	}
+}
+
+// WithTeamsBestEffort treats a failure to list the user's teams as
+// non-fatal. The user is authorized with an empty Teams slice and
+// TeamsDegraded set. Degraded results are not cached.
+func WithTeamsBestEffort() Option {
+	return func(v *Validator) {
+		v.teamsBestEffort = true
+	}
 }
 
-// New creates a new Validator with the given dependencies.
-func New(ghClient github.Client, cache Cache, org string, rejectClassicPATs bool, log *slog.Logger) *Validator {
+// New creates a new Validator with the given dependencies. A user is
+// authorized if they are a member of at least one of orgs; orgs are
+// checked in order and the first match wins.
+func New(ghClient github.Client, cache Cache, orgs []string, rejectClassicPATs bool, log *slog.Logger, opts ...Option) *Validator {
 	tracer := otel.Tracer("github.com/andrewkroh/traefik-github-auth/internal/validator")
 	meter := otel.Meter("github.com/andrewkroh/traefik-github-auth/internal/validator")
 
 	validationTotal, _ := meter.Int64Counter("github_auth.validation.total",
 		metric.WithDescription("Total number of token validations"),
 	)
+	validationDuration, _ := meter.Float64Histogram("github_auth.validation.duration",
+		metric.WithDescription("Duration of token validations"),
+		metric.WithUnit("s"),
+	)
 
-	return &Validator{
-		github:            ghClient,
-		cache:             cache,
-		org:               org,
-		rejectClassicPATs: rejectClassicPATs,
-		log:               log,
-		tracer:            tracer,
-		validationTotal:   validationTotal,
+	v := &Validator{
+		github:             ghClient,
+		cache:              cache,
+		orgs:               orgs,
+		rejectClassicPATs:  rejectClassicPATs,
+		log:                log,
+		tracer:             tracer,
+		validationTotal:    validationTotal,
+		validationDuration: validationDuration,
+	}
+	for _, opt := range opts {
+		opt(v)
 	}
+	return v
 }
 
 // Validate checks whether the given token is valid and the user is
 // authorized. It follows a 3-step validation flow:
-//  1. Identify the user via GetUser.
-//  2. Verify organization membership via CheckOrgMembership.
-//  3. List the user's teams via ListUserTeams.
+//  1. Identify the user via GetUser, or from the identity cache if one
+//     is configured.
+//  2. Verify organization membership via CheckOrgMembership, trying each
+//     configured org until one succeeds.
+//  3. List the user's teams in the matched org via ListUserTeams, or, if
+//     teams are disabled and required teams are set, check each required
+//     team via CheckTeamMembership.
+//
+// Steps 2 and 3 are issued concurrently; a membership failure takes
+// precedence over any error from listing teams. If WithOrgRole is used,
+// the user's org role is then fetched via GetOrgMembership.
 //
 // Results are cached to avoid redundant API calls.
-func (v *Validator) Validate(ctx context.Context, token string) (*ValidationResult, error) {
+func (v *Validator) Validate(ctx context.Context, token string) (res *ValidationResult, err error) {
+	start := time.Now()
 	ctx, span := v.tracer.Start(ctx, "validate_token")
 	defer span.End()
 
-	// Check cache first.
-	if result, cachedErr, ok := v.cache.Get(token); ok {
+	// Record the complete decision as a single span event and the
+	// validation duration on return.
+	var (
+		login, org  string
+		cacheStatus = cacheStatusMiss
+		apiCalls    int
+	)
+	defer func() {
+		if err != nil && !errors.Is(err, ErrTimeout) && errors.Is(context.Cause(ctx), ErrTimeout) {
+			err = fmt.Errorf("%w: %w", ErrTimeout, err)
+		}
+		if errors.Is(err, github.ErrUpstreamUnavailable) && !errors.Is(err, ErrUpstreamUnavailable) {
+			err = fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+		}
+		if errors.Is(err, github.ErrBadUpstreamResponse) && !errors.Is(err, ErrBadUpstreamResponse) {
+			err = fmt.Errorf("%w: %w", ErrBadUpstreamResponse, err)
+		}
+		if res != nil {
+			login, org = res.Login, res.Org
+		}
+		addDecisionEvent(span, err, login, org, cacheStatus, apiCalls)
+
+		result := resultSuccess
+		if err != nil {
+			result = resultOf(err)
+		}
+		v.validationDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+			attribute.String("result", result),
+			attribute.Bool("cache.hit", cacheStatus == cacheStatusHit),
+		))
+	}()
+
+	if v.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, v.timeout, ErrTimeout)
+		defer cancel()
+	}
+
+	// Reject denied tokens before the cache so that a cached success is
+	// not served for them.
+	if v.denylist != nil && v.denylist.Denied(token) {
+		span.RecordError(ErrUnauthorized)
+		span.SetStatus(codes.Error, ErrUnauthorized.Error())
+		span.SetAttributes(attribute.String("auth.result", resultUnauthorized))
+		v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultUnauthorized)))
+
+		v.log.WarnContext(ctx, "Token validation failed: token is on the denylist")
+
+		return nil, fmt.Errorf("%w", ErrUnauthorized)
+	}
+
+	// Check cache first. Positive entries cached under a different teams
+	// setting are ignored so that they are not served with stale teams.
+	result, cachedErr, ok := v.cache.Get(token)
+	if ok && cachedErr == nil && result.TeamsDisabled != v.disableTeams {
+		ok = false
+	}
+	if ok {
 		span.SetAttributes(attribute.Bool("cache.hit", true))
+		cacheStatus = cacheStatusHit
 
-		// Negative cache hit (e.g., previously unauthorized token).
+		// Negative cache hit (e.g., previously unauthorized token). The
+		// result reflects the cached error, which may also be a forbidden
+		// or transient error rejection.
 		if cachedErr != nil {
+			result := resultOf(cachedErr)
 			span.RecordError(cachedErr)
 			span.SetStatus(codes.Error, cachedErr.Error())
-			span.SetAttributes(attribute.String("auth.result", resultUnauthorized))
-			v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultUnauthorized)))
+			span.SetAttributes(attribute.String("auth.result", result))
+			v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
 
 			v.log.DebugContext(ctx, "Negative cache hit",
 				slog.String("error", cachedErr.Error()),
@@ -136,6 +417,7 @@ func (v *Validator) Validate(ctx context.Context, token string) (*ValidationResu
 		}
 
 		// Positive cache hit.
+		result.FromCache = true
 		span.SetAttributes(attribute.String("auth.user.login", result.Login))
 		span.SetAttributes(attribute.String("auth.result", resultSuccess))
 		v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultSuccess)))
@@ -149,8 +431,28 @@ func (v *Validator) Validate(ctx context.Context, token string) (*ValidationResu
 
 	span.SetAttributes(attribute.Bool("cache.hit", false))
 
-	// Step 1: Identify the user.
-	user, isClassicPAT, err := v.github.GetUser(ctx, token)
+	// Reject malformed tokens before spending a GitHub round trip.
+	if len(v.tokenPrefixes) > 0 && !hasAnyPrefix(token, v.tokenPrefixes) {
+		v.cache.Set(token, ValidationResult{}, ErrUnauthorized)
+
+		span.RecordError(ErrUnauthorized)
+		span.SetStatus(codes.Error, ErrUnauthorized.Error())
+		span.SetAttributes(attribute.String("auth.result", resultUnauthorized))
+		v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultUnauthorized)))
+
+		v.log.WarnContext(ctx, "Token validation failed: unrecognized token prefix")
+
+		return nil, fmt.Errorf("%w", ErrUnauthorized)
+	}
+
+	// Step 1: Identify the user, reusing a cached identity if possible.
+	user, identityCached := v.cachedIdentity(token)
+	span.SetAttributes(attribute.Bool("identity_cache.hit", identityCached))
+	var isClassicPAT bool
+	if !identityCached {
+		apiCalls++
+		user, isClassicPAT, err = v.github.GetUser(ctx, token)
+	}
 	if err != nil {
 		if errors.Is(err, github.ErrRateLimited) {
 			span.RecordError(ErrRateLimited)
@@ -161,6 +463,15 @@ func (v *Validator) Validate(ctx context.Context, token string) (*ValidationResu
 			return nil, fmt.Errorf("%w", ErrRateLimited)
 		}
 
+		if errors.Is(err, github.ErrCircuitOpen) {
+			span.RecordError(ErrCircuitOpen)
+			span.SetStatus(codes.Error, ErrCircuitOpen.Error())
+			span.SetAttributes(attribute.String("auth.result", resultError))
+			v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultError)))
+			v.log.WarnContext(ctx, "Token validation failed: GitHub circuit breaker open")
+			return nil, fmt.Errorf("%w", ErrCircuitOpen)
+		}
+
 		if errors.Is(err, github.ErrUnauthorized) {
 			v.cache.Set(token, ValidationResult{}, ErrUnauthorized)
 
@@ -174,6 +485,36 @@ func (v *Validator) Validate(ctx context.Context, token string) (*ValidationResu
 			return nil, fmt.Errorf("%w", ErrUnauthorized)
 		}
 
+		if errors.Is(err, github.ErrTokenExpired) {
+			v.cache.Set(token, ValidationResult{}, ErrTokenExpired)
+
+			span.RecordError(ErrTokenExpired)
+			span.SetStatus(codes.Error, ErrTokenExpired.Error())
+			span.SetAttributes(attribute.String("auth.result", resultUnauthorized))
+			v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultUnauthorized)))
+
+			v.log.WarnContext(ctx, "Token validation failed: token expired")
+
+			return nil, fmt.Errorf("%w", ErrTokenExpired)
+		}
+
+		if errors.Is(err, github.ErrForbiddenToken) {
+			v.cache.Set(token, ValidationResult{}, ErrForbiddenToken)
+
+			span.RecordError(ErrForbiddenToken)
+			span.SetStatus(codes.Error, ErrForbiddenToken.Error())
+			span.SetAttributes(attribute.String("auth.result", resultForbidden))
+			v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultForbidden)))
+
+			v.log.WarnContext(ctx, "Token validation failed: token forbidden from reading user")
+
+			return nil, fmt.Errorf("%w", ErrForbiddenToken)
+		}
+
+		if errors.Is(err, context.Canceled) {
+			return nil, v.canceled(ctx, span, fmt.Errorf("getting user: %w", err))
+		}
+
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		span.SetAttributes(attribute.String("auth.result", resultError))
@@ -181,9 +522,21 @@ func (v *Validator) Validate(ctx context.Context, token string) (*ValidationResu
 
 		v.log.ErrorContext(ctx, "Failed to get user from GitHub", slog.String("error", err.Error()))
 
-		return nil, fmt.Errorf("getting user: %w", err)
+		err = fmt.Errorf("getting user: %w", err)
+		v.cacheError(ctx, token, err)
+		return nil, err
 	}
 
+	if v.omitEmail && user.Email != "" {
+		u := *user
+		u.Email = ""
+		user = &u
+	}
+
+	login = user.Login
+	// Every subsequent log line for this validation carries the login.
+	ctx = logattr.NewContext(ctx, slog.String("login", login))
+
 	// Check for classic PAT rejection.
 	if v.rejectClassicPATs && isClassicPAT {
 		span.RecordError(ErrClassicPAT)
@@ -191,15 +544,62 @@ func (v *Validator) Validate(ctx context.Context, token string) (*ValidationResu
 		span.SetAttributes(attribute.String("auth.result", resultForbidden))
 		v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultForbidden)))
 
-		v.log.WarnContext(ctx, "Token validation failed: classic PAT rejected",
-			slog.String("login", user.Login),
-		)
+		v.log.WarnContext(ctx, "Token validation failed: classic PAT rejected")
 
+		v.cacheForbidden(token, ErrClassicPAT)
 		return nil, fmt.Errorf("%w", ErrClassicPAT)
 	}
 
+	if v.identityCache != nil && !identityCached {
+		v.identityCache.Set(token, ValidationResult{
+			Login:          user.Login,
+			ID:             user.ID,
+			Email:          user.Email,
+			NodeID:         user.NodeID,
+			AvatarURL:      user.AvatarURL,
+			TokenExpiresAt: user.TokenExpiresAt,
+		}, nil)
+	}
+
+	// Check the login against the allowed pattern.
+	if v.loginPattern != nil && !v.loginPattern.MatchString(user.Login) {
+		span.RecordError(ErrLoginNotAllowed)
+		span.SetStatus(codes.Error, ErrLoginNotAllowed.Error())
+		span.SetAttributes(attribute.String("auth.result", resultForbidden))
+		v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultForbidden)))
+
+		v.log.WarnContext(ctx, "Token validation failed: login not allowed")
+
+		return nil, fmt.Errorf("%w", ErrLoginNotAllowed)
+	}
+
+	// Steps 2 and 3 run concurrently. Teams are listed speculatively for
+	// the first configured org, which is the only org in the common case.
+	// If membership fails the teams call is canceled.
+	teamsCtx, cancelTeams := context.WithCancel(ctx)
+	defer cancelTeams()
+
+	var (
+		wg       sync.WaitGroup
+		teams    []github.Team
+		teamsErr error
+		teamsOrg string
+	)
+	if len(v.orgs) > 0 && !v.disableTeams {
+		teamsOrg = v.orgs[0]
+		apiCalls++
+		wg.Go(func() {
+			teams, teamsErr = v.github.ListUserTeams(teamsCtx, token, teamsOrg)
+		})
+	}
+
 	// Step 2: Verify organization membership.
-	if err := v.github.CheckOrgMembership(ctx, token, v.org, user.Login); err != nil {
+	org, checked, err := v.findMemberOrg(ctx, token, user.Login)
+	apiCalls += checked
+	if err != nil {
+		cancelTeams()
+		wg.Wait()
+
 		if errors.Is(err, github.ErrRateLimited) {
 			span.RecordError(ErrRateLimited)
 			span.SetStatus(codes.Error, ErrRateLimited.Error())
@@ -209,6 +609,32 @@ func (v *Validator) Validate(ctx context.Context, token string) (*ValidationResu
 			return nil, fmt.Errorf("%w", ErrRateLimited)
 		}
 
+		if errors.Is(err, github.ErrCircuitOpen) {
+			span.RecordError(ErrCircuitOpen)
+			span.SetStatus(codes.Error, ErrCircuitOpen.Error())
+			span.SetAttributes(attribute.String("auth.result", resultError))
+			v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultError)))
+			v.log.WarnContext(ctx, "Token validation failed: GitHub circuit breaker open")
+			return nil, fmt.Errorf("%w", ErrCircuitOpen)
+		}
+
+		// The token may have been revoked since its identity was cached.
+		if errors.Is(err, github.ErrUnauthorized) {
+			if v.identityCache != nil {
+				v.identityCache.Delete(token)
+			}
+			v.cache.Set(token, ValidationResult{}, ErrUnauthorized)
+
+			span.RecordError(ErrUnauthorized)
+			span.SetStatus(codes.Error, ErrUnauthorized.Error())
+			span.SetAttributes(attribute.String("auth.result", resultUnauthorized))
+			v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultUnauthorized)))
+
+			v.log.WarnContext(ctx, "Token validation failed: unauthorized")
+
+			return nil, fmt.Errorf("%w", ErrUnauthorized)
+		}
+
 		if errors.Is(err, github.ErrNotOrgMember) {
 			span.RecordError(ErrNotOrgMember)
 			span.SetStatus(codes.Error, ErrNotOrgMember.Error())
@@ -216,30 +642,57 @@ func (v *Validator) Validate(ctx context.Context, token string) (*ValidationResu
 			v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultForbidden)))
 
 			v.log.WarnContext(ctx, "Token validation failed: user is not an org member",
-				slog.String("login", user.Login),
-				slog.String("org", v.org),
+				slog.Any("orgs", v.orgs),
 			)
 
+			v.cacheForbidden(token, ErrNotOrgMember)
 			return nil, fmt.Errorf("%w", ErrNotOrgMember)
 		}
 
+		if errors.Is(err, context.Canceled) {
+			return nil, v.canceled(ctx, span, fmt.Errorf("checking org membership: %w", err))
+		}
+
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		span.SetAttributes(attribute.String("auth.result", resultError))
 		v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultError)))
 
 		v.log.ErrorContext(ctx, "Failed to check org membership",
-			slog.String("login", user.Login),
-			slog.String("org", v.org),
+			slog.Any("orgs", v.orgs),
 			slog.String("error", err.Error()),
 		)
 
-		return nil, fmt.Errorf("checking org membership: %w", err)
+		err = fmt.Errorf("checking org membership: %w", err)
+		v.cacheError(ctx, token, err)
+		return nil, err
 	}
 
-	// Step 3: Get teams.
-	teams, err := v.github.ListUserTeams(ctx, token, v.org)
-	if err != nil {
+	// Step 3: Get teams, re-listing them if membership matched a different
+	// org than the speculative call used. When teams are disabled, required
+	// teams are checked one at a time instead.
+	wg.Wait()
+	var inRequiredTeam bool
+	teamsOp := "listing user teams"
+	switch {
+	case v.disableTeams && len(v.requiredTeams) > 0:
+		var checked int
+		teamsOp = "checking team membership"
+		inRequiredTeam, checked, teamsErr = v.findRequiredTeam(ctx, token, org, user.Login)
+		apiCalls += checked
+	case !v.disableTeams && org != teamsOrg:
+		apiCalls++
+		teams, teamsErr = v.github.ListUserTeams(ctx, token, org)
+	}
+	var teamsDegraded bool
+	if teamsErr != nil && v.teamsBestEffort && !errors.Is(teamsErr, context.Canceled) {
+		v.log.WarnContext(ctx, "Failed to list user teams, continuing without teams",
+			slog.String("org", org),
+			slog.String("error", teamsErr.Error()),
+		)
+		teams, teamsErr, teamsDegraded = nil, nil, true
+	}
+	if err = teamsErr; err != nil {
 		if errors.Is(err, github.ErrRateLimited) {
 			span.RecordError(ErrRateLimited)
 			span.SetStatus(codes.Error, ErrRateLimited.Error())
@@ -249,46 +702,336 @@ func (v *Validator) Validate(ctx context.Context, token string) (*ValidationResu
 			return nil, fmt.Errorf("%w", ErrRateLimited)
 		}
 
+		if errors.Is(err, github.ErrCircuitOpen) {
+			span.RecordError(ErrCircuitOpen)
+			span.SetStatus(codes.Error, ErrCircuitOpen.Error())
+			span.SetAttributes(attribute.String("auth.result", resultError))
+			v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultError)))
+			v.log.WarnContext(ctx, "Token validation failed: GitHub circuit breaker open")
+			return nil, fmt.Errorf("%w", ErrCircuitOpen)
+		}
+
+		if errors.Is(err, context.Canceled) {
+			return nil, v.canceled(ctx, span, fmt.Errorf("%s: %w", teamsOp, err))
+		}
+
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		span.SetAttributes(attribute.String("auth.result", resultError))
 		v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultError)))
 
-		v.log.ErrorContext(ctx, "Failed to list user teams",
-			slog.String("login", user.Login),
-			slog.String("org", v.org),
+		v.log.ErrorContext(ctx, "Failed to get user teams",
+			slog.String("org", org),
+			slog.String("operation", teamsOp),
 			slog.String("error", err.Error()),
 		)
 
-		return nil, fmt.Errorf("listing user teams: %w", err)
+		err = fmt.Errorf("%s: %w", teamsOp, err)
+		v.cacheError(ctx, token, err)
+		return nil, err
 	}
 
-	// Extract team slugs.
+	// Extract team slugs and names.
 	teamSlugs := make([]string, len(teams))
+	teamNames := make([]string, len(teams))
 	for i, t := range teams {
 		teamSlugs[i] = t.Slug
+		teamNames[i] = t.Name
+	}
+
+	// Enforce the required team policy.
+	if len(v.requiredTeams) > 0 && !v.disableTeams {
+		inRequiredTeam = containsAnyTeam(teamSlugs, v.requiredTeams)
+	}
+	if len(v.requiredTeams) > 0 && !inRequiredTeam {
+		span.RecordError(ErrTeamNotAuthorized)
+		span.SetStatus(codes.Error, ErrTeamNotAuthorized.Error())
+		span.SetAttributes(attribute.String("auth.result", resultForbidden))
+		v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultForbidden)))
+
+		v.log.WarnContext(ctx, "Token validation failed: user is not in a required team",
+			slog.String("org", org),
+		)
+
+		return nil, fmt.Errorf("%w", ErrTeamNotAuthorized)
+	}
+
+	// Fetch the user's role in the org.
+	var orgRole string
+	if v.fetchOrgRole {
+		apiCalls++
+		membership, err := v.github.GetOrgMembership(ctx, token, org, user.Login)
+		if err != nil {
+			if errors.Is(err, github.ErrRateLimited) {
+				span.RecordError(ErrRateLimited)
+				span.SetStatus(codes.Error, ErrRateLimited.Error())
+				span.SetAttributes(attribute.String("auth.result", resultError))
+				v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultError)))
+				v.log.WarnContext(ctx, "Token validation failed: rate limited")
+				return nil, fmt.Errorf("%w", ErrRateLimited)
+			}
+
+			if errors.Is(err, github.ErrCircuitOpen) {
+				span.RecordError(ErrCircuitOpen)
+				span.SetStatus(codes.Error, ErrCircuitOpen.Error())
+				span.SetAttributes(attribute.String("auth.result", resultError))
+				v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultError)))
+				v.log.WarnContext(ctx, "Token validation failed: GitHub circuit breaker open")
+				return nil, fmt.Errorf("%w", ErrCircuitOpen)
+			}
+
+			if errors.Is(err, github.ErrUnauthorized) {
+				if v.identityCache != nil {
+					v.identityCache.Delete(token)
+				}
+				v.cache.Set(token, ValidationResult{}, ErrUnauthorized)
+
+				span.RecordError(ErrUnauthorized)
+				span.SetStatus(codes.Error, ErrUnauthorized.Error())
+				span.SetAttributes(attribute.String("auth.result", resultUnauthorized))
+				v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultUnauthorized)))
+
+				v.log.WarnContext(ctx, "Token validation failed: unauthorized")
+
+				return nil, fmt.Errorf("%w", ErrUnauthorized)
+			}
+
+			// The membership may have been removed since the check above.
+			if errors.Is(err, github.ErrNotOrgMember) {
+				span.RecordError(ErrNotOrgMember)
+				span.SetStatus(codes.Error, ErrNotOrgMember.Error())
+				span.SetAttributes(attribute.String("auth.result", resultForbidden))
+				v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultForbidden)))
+
+				v.log.WarnContext(ctx, "Token validation failed: user is not an org member",
+					slog.String("org", org),
+				)
+
+				v.cacheForbidden(token, ErrNotOrgMember)
+				return nil, fmt.Errorf("%w", ErrNotOrgMember)
+			}
+
+			if errors.Is(err, context.Canceled) {
+				return nil, v.canceled(ctx, span, fmt.Errorf("getting org role: %w", err))
+			}
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.SetAttributes(attribute.String("auth.result", resultError))
+			v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultError)))
+
+			v.log.ErrorContext(ctx, "Failed to get org role",
+				slog.String("org", org),
+				slog.String("error", err.Error()),
+			)
+
+			err = fmt.Errorf("getting org role: %w", err)
+			v.cacheError(ctx, token, err)
+			return nil, err
+		}
+		orgRole = membership.Role
 	}
 
 	// Build result.
-	result := ValidationResult{
-		Login: user.Login,
-		ID:    user.ID,
-		Org:   v.org,
-		Teams: teamSlugs,
+	result = ValidationResult{
+		Login:          user.Login,
+		ID:             user.ID,
+		Email:          user.Email,
+		NodeID:         user.NodeID,
+		AvatarURL:      user.AvatarURL,
+		Org:            org,
+		Teams:          teamSlugs,
+		TeamNames:      teamNames,
+		OrgRole:        orgRole,
+		TeamsDisabled:  v.disableTeams,
+		TeamsDegraded:  teamsDegraded,
+		TokenExpiresAt: user.TokenExpiresAt,
+		ValidatedAt:    time.Now(),
 	}
 
-	// Cache the result.
-	v.cache.Set(token, result, nil)
+	// Cache the result. Degraded results are not cached so that the next
+	// request retries the team lookup. The cache caps the entry's lifetime
+	// at TokenExpiresAt.
+	if !teamsDegraded {
+		v.cache.Set(token, result, nil)
+	}
 
 	span.SetAttributes(attribute.String("auth.user.login", user.Login))
 	span.SetAttributes(attribute.String("auth.result", resultSuccess))
 	v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultSuccess)))
 
 	v.log.InfoContext(ctx, "Token validation succeeded",
-		slog.String("login", user.Login),
 		slog.Int64("user_id", user.ID),
 		slog.Int("teams", len(teamSlugs)),
 	)
 
 	return &result, nil
 }
+
+// Warmup validates each of tokens once so that their results are cached
+// before traffic arrives. Failures are logged and do not stop the warmup.
+// It returns the number of tokens that validated successfully.
+func (v *Validator) Warmup(ctx context.Context, tokens []string) int {
+	var warmed int
+	for i, token := range tokens {
+		if ctx.Err() != nil {
+			break
+		}
+		result, err := v.Validate(ctx, token)
+		if err != nil {
+			v.log.WarnContext(ctx, "Cache warmup failed for token",
+				slog.Int("index", i),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		warmed++
+		v.log.InfoContext(ctx, "Cache warmed for token",
+			slog.Int("index", i),
+			slog.String("login", result.Login),
+		)
+	}
+	return warmed
+}
+
+// findMemberOrg returns the first configured org in which username is a
+// member, along with the number of membership checks made. If the user
+// belongs to none of them, the returned error wraps github.ErrNotOrgMember.
+// Any other error stops the search.
+func (v *Validator) findMemberOrg(ctx context.Context, token, username string) (string, int, error) {
+	for i, org := range v.orgs {
+		err := v.github.CheckOrgMembership(ctx, token, org, username)
+		if err == nil {
+			return org, i + 1, nil
+		}
+		if !errors.Is(err, github.ErrNotOrgMember) {
+			return "", i + 1, err
+		}
+	}
+	return "", len(v.orgs), github.ErrNotOrgMember
+}
+
+// findRequiredTeam checks the user's membership in each required team of
+// org in turn and reports whether the user is an active member of one. It
+// also returns the number of teams checked. Pending invitations do not
+// count as membership.
+func (v *Validator) findRequiredTeam(ctx context.Context, token, org, username string) (bool, int, error) {
+	for i, team := range v.requiredTeams {
+		m, err := v.github.CheckTeamMembership(ctx, token, org, team, username)
+		if err == nil && m.State == github.TeamMembershipActive {
+			return true, i + 1, nil
+		}
+		if err != nil && !errors.Is(err, github.ErrNotTeamMember) {
+			return false, i + 1, err
+		}
+	}
+	return false, len(v.requiredTeams), nil
+}
+
+// addDecisionEvent records the outcome of a validation as a single
+// "auth.decision" span event.
+func addDecisionEvent(span trace.Span, err error, login, org, cacheStatus string, apiCalls int) {
+	result, reason := resultSuccess, "authorized"
+	if err != nil {
+		result, reason = resultOf(err), err.Error()
+	}
+	span.AddEvent("auth.decision", trace.WithAttributes(
+		attribute.String("result", result),
+		attribute.String("reason", reason),
+		attribute.String("login", login),
+		attribute.String("org", org),
+		attribute.String("cache_status", cacheStatus),
+		attribute.Int("api_calls", apiCalls),
+	))
+}
+
+// cachedIdentity returns the user identity cached for token by a previous
+// call to GetUser, if an identity cache is configured.
+func (v *Validator) cachedIdentity(token string) (*github.User, bool) {
+	if v.identityCache == nil {
+		return nil, false
+	}
+	identity, err, ok := v.identityCache.Get(token)
+	if !ok || err != nil {
+		return nil, false
+	}
+	return &github.User{
+		Login:          identity.Login,
+		ID:             identity.ID,
+		Email:          identity.Email,
+		NodeID:         identity.NodeID,
+		AvatarURL:      identity.AvatarURL,
+		TokenExpiresAt: identity.TokenExpiresAt,
+	}, true
+}
+
+// canceled records a validation abandoned because the caller went away.
+// This is not a failure of the service or of GitHub, so it is logged at
+// debug level, counted with its own result, and never cached.
+func (v *Validator) canceled(ctx context.Context, span trace.Span, err error) error {
+	span.SetAttributes(attribute.String("auth.result", resultCanceled))
+	v.validationTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("result", resultCanceled)))
+	v.log.DebugContext(ctx, "Token validation canceled by the client", slog.String("error", err.Error()))
+	return err
+}
+
+// cacheError caches an unexpected error for the error cache TTL, if one
+// is configured. Errors are not cached once ctx is done because they then
+// reflect the caller's deadline rather than GitHub.
+func (v *Validator) cacheError(ctx context.Context, token string, err error) {
+	if v.errorCacheTTL <= 0 || errors.Is(err, context.Canceled) || ctx.Err() != nil {
+		return
+	}
+	v.cache.SetWithTTL(token, ValidationResult{}, err, v.errorCacheTTL)
+}
+
+// cacheForbidden negatively caches a forbidden outcome for the forbidden
+// cache TTL, or the cache's default TTL if none is configured.
+func (v *Validator) cacheForbidden(token string, err error) {
+	if v.forbiddenCacheTTL > 0 {
+		v.cache.SetWithTTL(token, ValidationResult{}, err, v.forbiddenCacheTTL)
+		return
+	}
+	v.cache.Set(token, ValidationResult{}, err)
+}
+
+// resultOf maps a validation error to its auth result attribute value.
+func resultOf(err error) string {
+	switch {
+	case errors.Is(err, ErrUnauthorized), errors.Is(err, ErrTokenExpired):
+		return resultUnauthorized
+	case errors.Is(err, ErrForbiddenToken),
+		errors.Is(err, ErrNotOrgMember),
+		errors.Is(err, ErrClassicPAT),
+		errors.Is(err, ErrLoginNotAllowed),
+		errors.Is(err, ErrTeamNotAuthorized):
+		return resultForbidden
+	case errors.Is(err, context.Canceled):
+		return resultCanceled
+	default:
+		return resultError
+	}
+}
+
+// hasAnyPrefix reports whether s starts with any of prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAnyTeam reports whether any of the user's team slugs matches one
+// of the required slugs (case-insensitive).
+func containsAnyTeam(teams, required []string) bool {
+	for _, t := range teams {
+		for _, r := range required {
+			if strings.EqualFold(t, r) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -5,19 +5,34 @@
 package validator
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"github.com/andrewkroh/traefik-github-auth/internal/github"
 )
 
 // mockGitHubClient implements github.Client for testing.
 type mockGitHubClient struct {
-	getUser            func(ctx context.Context, token string) (*github.User, bool, error)
-	checkOrgMembership func(ctx context.Context, token, org, username string) error
-	listUserTeams      func(ctx context.Context, token, org string) ([]github.Team, error)
+	getUser             func(ctx context.Context, token string) (*github.User, bool, error)
+	checkOrgMembership  func(ctx context.Context, token, org, username string) error
+	getOrgMembership    func(ctx context.Context, token, org, username string) (*github.OrgMembership, error)
+	listUserTeams       func(ctx context.Context, token, org string) ([]github.Team, error)
+	checkTeamMembership func(ctx context.Context, token, org, teamSlug, username string) (*github.TeamMembership, error)
 }
 
 func (m *mockGitHubClient) GetUser(ctx context.Context, token string) (*github.User, bool, error) {
@@ -28,14 +43,33 @@ func (m *mockGitHubClient) CheckOrgMembership(ctx context.Context, token, org, u
 	return m.checkOrgMembership(ctx, token, org, username)
 }
 
+func (m *mockGitHubClient) GetOrgMembership(ctx context.Context, token, org, username string) (*github.OrgMembership, error) {
+	return m.getOrgMembership(ctx, token, org, username)
+}
+
 func (m *mockGitHubClient) ListUserTeams(ctx context.Context, token, org string) ([]github.Team, error) {
+	// Teams are listed concurrently with the membership check, so tests
+	// that fail before step 3 need not provide an implementation.
+	if m.listUserTeams == nil {
+		return nil, nil
+	}
 	return m.listUserTeams(ctx, token, org)
 }
 
+func (m *mockGitHubClient) CheckTeamMembership(ctx context.Context, token, org, teamSlug, username string) (*github.TeamMembership, error) {
+	return m.checkTeamMembership(ctx, token, org, teamSlug, username)
+}
+
 // mockCacheEntry stores both a result and an optional error for negative caching.
 type mockCacheEntry struct {
 	result ValidationResult
 	err    error
+
+	// ttl is the TTL passed to SetWithTTL, or zero for Set.
+	ttl time.Duration
+
+	// expiresAt, if non-zero, is when the entry stops being returned.
+	expiresAt time.Time
 }
 
 // mockCache implements Cache for testing.
@@ -55,6 +89,9 @@ func (c *mockCache) Get(token string) (ValidationResult, error, bool) {
 	if !ok {
 		return ValidationResult{}, nil, false
 	}
+	if !entry.expiresAt.IsZero() && !time.Now().Before(entry.expiresAt) {
+		return ValidationResult{}, nil, false
+	}
 	return entry.result, entry.err, true
 }
 
@@ -62,6 +99,10 @@ func (c *mockCache) Set(token string, result ValidationResult, err error) {
 	c.store[token] = mockCacheEntry{result: result, err: err}
 }
 
+func (c *mockCache) SetWithTTL(token string, result ValidationResult, err error, ttl time.Duration) {
+	c.store[token] = mockCacheEntry{result: result, err: err, ttl: ttl, expiresAt: time.Now().Add(ttl)}
+}
+
 func (c *mockCache) Delete(token string) {
 	c.deleted = append(c.deleted, token)
 	delete(c.store, token)
@@ -97,7 +138,7 @@ func TestValidate_CacheHit(t *testing.T) {
 		},
 	}
 
-	v := New(ghClient, cache, "myorg", false, discardLogger())
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
 	result, err := v.Validate(context.Background(), "fake-token-cached")
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
@@ -130,7 +171,7 @@ func TestValidate_NegativeCacheHit(t *testing.T) {
 		},
 	}
 
-	v := New(ghClient, cache, "myorg", false, discardLogger())
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
 	_, err := v.Validate(context.Background(), "fake-token-bad")
 	if err == nil {
 		t.Fatal("expected error, got nil")
@@ -167,7 +208,7 @@ func TestValidate_CacheMiss_Success(t *testing.T) {
 		},
 	}
 
-	v := New(ghClient, cache, "myorg", false, discardLogger())
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
 	result, err := v.Validate(context.Background(), "fake-token-miss")
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
@@ -198,6 +239,77 @@ func TestValidate_CacheMiss_Success(t *testing.T) {
 	}
 }
 
+func TestValidate_FromCache(t *testing.T) {
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return &github.User{Login: "testuser", ID: 42}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			return nil
+		},
+		listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+			return nil, nil
+		},
+	}
+
+	v := New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger())
+	miss, err := v.Validate(context.Background(), "fake-token")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if miss.FromCache {
+		t.Error("expected FromCache to be false on a cache miss")
+	}
+	if miss.ValidatedAt.IsZero() {
+		t.Error("expected ValidatedAt to be set")
+	}
+
+	hit, err := v.Validate(context.Background(), "fake-token")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !hit.FromCache {
+		t.Error("expected FromCache to be true on a cache hit")
+	}
+	if !hit.ValidatedAt.Equal(miss.ValidatedAt) {
+		t.Errorf("expected ValidatedAt %v to be preserved, got %v", miss.ValidatedAt, hit.ValidatedAt)
+	}
+}
+
+func TestValidate_WithoutEmail(t *testing.T) {
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return &github.User{Login: "testuser", ID: 42, Email: "testuser@example.com"}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			return nil
+		},
+		listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+			return nil, nil
+		},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		opts      []Option
+		wantEmail string
+	}{
+		{"default", nil, "testuser@example.com"},
+		{"without email", []Option{WithoutEmail()}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger(), tc.opts...)
+			result, err := v.Validate(context.Background(), "fake-token")
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if result.Email != tc.wantEmail {
+				t.Errorf("expected email %q, got %q", tc.wantEmail, result.Email)
+			}
+		})
+	}
+}
+
 func TestValidate_UnauthorizedToken(t *testing.T) {
 	cache := newMockCache()
 
@@ -207,7 +319,7 @@ func TestValidate_UnauthorizedToken(t *testing.T) {
 		},
 	}
 
-	v := New(ghClient, cache, "myorg", false, discardLogger())
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
 	_, err := v.Validate(context.Background(), "fake-token-unauth")
 
 	if err == nil {
@@ -227,6 +339,49 @@ func TestValidate_UnauthorizedToken(t *testing.T) {
 	}
 }
 
+func TestValidate_TokenExpired(t *testing.T) {
+	cache := newMockCache()
+	v := New(&mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return nil, false, github.ErrTokenExpired
+		},
+	}, cache, []string{"myorg"}, false, discardLogger())
+
+	_, err := v.Validate(context.Background(), "expired-token")
+	if !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expected ErrTokenExpired, got: %v", err)
+	}
+	if entry, ok := cache.store["expired-token"]; !ok || !errors.Is(entry.err, ErrTokenExpired) {
+		t.Fatalf("expected a negative cache entry for the expired token, got %+v", entry)
+	}
+}
+
+func TestValidate_ForbiddenToken(t *testing.T) {
+	cache := newMockCache()
+
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return nil, false, github.ErrForbiddenToken
+		},
+	}
+
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
+	_, err := v.Validate(context.Background(), "fake-token-forbidden")
+
+	if !errors.Is(err, ErrForbiddenToken) {
+		t.Fatalf("expected ErrForbiddenToken, got: %v", err)
+	}
+
+	// Verify the forbidden result was negatively cached.
+	entry, ok := cache.store["fake-token-forbidden"]
+	if !ok {
+		t.Fatal("expected forbidden token to be negatively cached")
+	}
+	if !errors.Is(entry.err, ErrForbiddenToken) {
+		t.Errorf("expected cached error ErrForbiddenToken, got: %v", entry.err)
+	}
+}
+
 func TestValidate_NotOrgMember(t *testing.T) {
 	cache := newMockCache()
 
@@ -239,7 +394,7 @@ func TestValidate_NotOrgMember(t *testing.T) {
 		},
 	}
 
-	v := New(ghClient, cache, "myorg", false, discardLogger())
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
 	_, err := v.Validate(context.Background(), "fake-token-nonmember")
 
 	if err == nil {
@@ -250,6 +405,116 @@ func TestValidate_NotOrgMember(t *testing.T) {
 	}
 }
 
+func TestValidate_ForbiddenCached(t *testing.T) {
+	tests := []struct {
+		name       string
+		isClassic  bool
+		isMember   bool
+		opts       []Option
+		wantErr    error
+		wantTTL    time.Duration
+		wantOrgChk int
+	}{
+		{name: "not org member", isMember: false, wantErr: ErrNotOrgMember, wantOrgChk: 1},
+		{name: "classic PAT", isClassic: true, isMember: true, wantErr: ErrClassicPAT},
+		{name: "forbidden cache TTL", isMember: false, opts: []Option{WithForbiddenCacheTTL(time.Minute)}, wantErr: ErrNotOrgMember, wantTTL: time.Minute, wantOrgChk: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var getUserCalls, orgCalls int
+			ghClient := &mockGitHubClient{
+				getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+					getUserCalls++
+					return &github.User{Login: "outsider", ID: 99}, tt.isClassic, nil
+				},
+				checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+					orgCalls++
+					if !tt.isMember {
+						return github.ErrNotOrgMember
+					}
+					return nil
+				},
+			}
+			cache := newMockCache()
+			v := New(ghClient, cache, []string{"myorg"}, true, discardLogger(), tt.opts...)
+
+			for range 2 {
+				if _, err := v.Validate(context.Background(), "fake-token"); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got: %v", tt.wantErr, err)
+				}
+			}
+			if getUserCalls != 1 || orgCalls != tt.wantOrgChk {
+				t.Errorf("expected 1 GetUser and %d membership calls, got %d and %d", tt.wantOrgChk, getUserCalls, orgCalls)
+			}
+			if entry := cache.store["fake-token"]; entry.ttl != tt.wantTTL {
+				t.Errorf("expected cache TTL %v, got %v", tt.wantTTL, entry.ttl)
+			}
+		})
+	}
+}
+
+func TestValidate_OrgRole(t *testing.T) {
+	var roleCalls int
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return &github.User{Login: "octocat", ID: 1}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			return nil
+		},
+		getOrgMembership: func(ctx context.Context, token, org, username string) (*github.OrgMembership, error) {
+			roleCalls++
+			if org != "myorg" || username != "octocat" {
+				t.Errorf("unexpected org role lookup for %s in %s", username, org)
+			}
+			return &github.OrgMembership{Role: github.OrgRoleAdmin, State: "active"}, nil
+		},
+	}
+
+	v := New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger())
+	result, err := v.Validate(context.Background(), "fake-token")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.OrgRole != "" || roleCalls != 0 {
+		t.Errorf("expected no org role lookup by default, got role %q after %d calls", result.OrgRole, roleCalls)
+	}
+
+	v = New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger(), WithOrgRole())
+	result, err = v.Validate(context.Background(), "fake-token")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.OrgRole != github.OrgRoleAdmin {
+		t.Errorf("expected org role %q, got %q", github.OrgRoleAdmin, result.OrgRole)
+	}
+
+	ghClient.getOrgMembership = func(ctx context.Context, token, org, username string) (*github.OrgMembership, error) {
+		return nil, github.ErrRateLimited
+	}
+	v = New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger(), WithOrgRole())
+	if _, err := v.Validate(context.Background(), "fake-token"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got: %v", err)
+	}
+
+	for _, tc := range []struct {
+		ghErr   error
+		wantErr error
+	}{
+		{github.ErrNotOrgMember, ErrNotOrgMember},
+		{github.ErrUnauthorized, ErrUnauthorized},
+	} {
+		ghClient.getOrgMembership = func(ctx context.Context, token, org, username string) (*github.OrgMembership, error) {
+			return nil, tc.ghErr
+		}
+		v = New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger(), WithOrgRole())
+		if _, err := v.Validate(context.Background(), "fake-token"); !errors.Is(err, tc.wantErr) {
+			t.Errorf("expected %v, got: %v", tc.wantErr, err)
+		}
+	}
+}
+
 func TestValidate_ClassicPAT_Rejected(t *testing.T) {
 	cache := newMockCache()
 
@@ -259,7 +524,7 @@ func TestValidate_ClassicPAT_Rejected(t *testing.T) {
 		},
 	}
 
-	v := New(ghClient, cache, "myorg", true, discardLogger())
+	v := New(ghClient, cache, []string{"myorg"}, true, discardLogger())
 	_, err := v.Validate(context.Background(), "fake-token-classic")
 
 	if err == nil {
@@ -287,7 +552,7 @@ func TestValidate_ClassicPAT_Allowed(t *testing.T) {
 		},
 	}
 
-	v := New(ghClient, cache, "myorg", false, discardLogger())
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
 	result, err := v.Validate(context.Background(), "fake-token-classic-allowed")
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
@@ -310,7 +575,7 @@ func TestValidate_GetUserError(t *testing.T) {
 		},
 	}
 
-	v := New(ghClient, cache, "myorg", false, discardLogger())
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
 	_, err := v.Validate(context.Background(), "fake-token-error")
 
 	if err == nil {
@@ -325,6 +590,36 @@ func TestValidate_GetUserError(t *testing.T) {
 	}
 }
 
+func TestValidate_UpstreamUnavailable(t *testing.T) {
+	cache := newMockCache()
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return nil, false, fmt.Errorf("%w: dial tcp: connection refused", github.ErrUpstreamUnavailable)
+		},
+	}
+
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
+	_, err := v.Validate(context.Background(), "fake-token")
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("expected ErrUpstreamUnavailable, got: %v", err)
+	}
+}
+
+func TestValidate_BadUpstreamResponse(t *testing.T) {
+	cache := newMockCache()
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return nil, false, fmt.Errorf("%w: decoding user response: unexpected EOF", github.ErrBadUpstreamResponse)
+		},
+	}
+
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
+	_, err := v.Validate(context.Background(), "fake-token")
+	if !errors.Is(err, ErrBadUpstreamResponse) {
+		t.Fatalf("expected ErrBadUpstreamResponse, got: %v", err)
+	}
+}
+
 func TestValidate_CheckOrgError(t *testing.T) {
 	cache := newMockCache()
 	apiErr := errors.New("github API network error")
@@ -338,7 +633,7 @@ func TestValidate_CheckOrgError(t *testing.T) {
 		},
 	}
 
-	v := New(ghClient, cache, "myorg", false, discardLogger())
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
 	_, err := v.Validate(context.Background(), "fake-token-org-error")
 
 	if err == nil {
@@ -369,7 +664,7 @@ func TestValidate_ListTeamsError(t *testing.T) {
 		},
 	}
 
-	v := New(ghClient, cache, "myorg", false, discardLogger())
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
 	_, err := v.Validate(context.Background(), "fake-token-teams-error")
 
 	if err == nil {
@@ -380,6 +675,133 @@ func TestValidate_ListTeamsError(t *testing.T) {
 	}
 }
 
+func TestValidate_CircuitOpen(t *testing.T) {
+	member := func(ctx context.Context, token, org, username string) error { return nil }
+	user := func(ctx context.Context, token string) (*github.User, bool, error) {
+		return &github.User{Login: "testuser", ID: 42}, false, nil
+	}
+
+	tests := []struct {
+		name     string
+		ghClient *mockGitHubClient
+	}{
+		{
+			name: "get user",
+			ghClient: &mockGitHubClient{
+				getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+					return nil, false, github.ErrCircuitOpen
+				},
+			},
+		},
+		{
+			name: "check org membership",
+			ghClient: &mockGitHubClient{
+				getUser: user,
+				checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+					return github.ErrCircuitOpen
+				},
+				listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+					return nil, nil
+				},
+			},
+		},
+		{
+			name: "list user teams",
+			ghClient: &mockGitHubClient{
+				getUser:            user,
+				checkOrgMembership: member,
+				listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+					return nil, github.ErrCircuitOpen
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newMockCache()
+			v := New(tt.ghClient, cache, []string{"myorg"}, false, discardLogger(), WithErrorCacheTTL(time.Second))
+			_, err := v.Validate(context.Background(), "fake-token-circuit-open")
+			if !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("expected ErrCircuitOpen, got: %v", err)
+			}
+			if len(cache.store) != 0 {
+				t.Error("expected an open circuit not to be cached")
+			}
+		})
+	}
+}
+
+func TestValidate_TokenExpiration(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return &github.User{Login: "testuser", ID: 42, TokenExpiresAt: expiresAt}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			return nil
+		},
+		listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+			return nil, nil
+		},
+	}
+
+	cache := newMockCache()
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
+	result, err := v.Validate(context.Background(), "fake-token-expiring")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.TokenExpiresAt.Equal(expiresAt) {
+		t.Errorf("TokenExpiresAt = %v, want %v", result.TokenExpiresAt, expiresAt)
+	}
+	if cached := cache.store["fake-token-expiring"]; !cached.result.TokenExpiresAt.Equal(expiresAt) {
+		t.Errorf("cached TokenExpiresAt = %v, want %v", cached.result.TokenExpiresAt, expiresAt)
+	}
+}
+
+// denylistFunc implements Denylist for testing.
+type denylistFunc func(token string) bool
+
+func (f denylistFunc) Denied(token string) bool { return f(token) }
+
+func TestValidate_Denylist(t *testing.T) {
+	var getUserCalls int
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			getUserCalls++
+			return &github.User{Login: "testuser", ID: 42}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			return nil
+		},
+		listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+			return nil, nil
+		},
+	}
+
+	cache := newMockCache()
+	// A previously cached success must not be served for a denied token.
+	cache.Set("fake-token-denied", ValidationResult{Login: "testuser", ID: 42, Org: "myorg"}, nil)
+
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger(),
+		WithDenylist(denylistFunc(func(token string) bool { return token == "fake-token-denied" })))
+
+	if _, err := v.Validate(context.Background(), "fake-token-denied"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got: %v", err)
+	}
+	if getUserCalls != 0 {
+		t.Fatalf("expected GetUser not to be called for a denied token, got %d calls", getUserCalls)
+	}
+
+	if _, err := v.Validate(context.Background(), "fake-token-allowed"); err != nil {
+		t.Fatalf("unexpected error for an allowed token: %v", err)
+	}
+	if getUserCalls != 1 {
+		t.Fatalf("expected GetUser to be called once, got %d calls", getUserCalls)
+	}
+}
+
 func TestValidate_TeamsExtracted(t *testing.T) {
 	cache := newMockCache()
 
@@ -392,14 +814,14 @@ func TestValidate_TeamsExtracted(t *testing.T) {
 		},
 		listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
 			return []github.Team{
-				{Slug: "platform", Organization: github.Organization{Login: "myorg"}},
-				{Slug: "security", Organization: github.Organization{Login: "myorg"}},
-				{Slug: "sre", Organization: github.Organization{Login: "myorg"}},
+				{Slug: "platform", Name: "Platform", Organization: github.Organization{Login: "myorg"}},
+				{Slug: "security", Name: "Security", Organization: github.Organization{Login: "myorg"}},
+				{Slug: "sre", Name: "Site Reliability", Organization: github.Organization{Login: "myorg"}},
 			}, nil
 		},
 	}
 
-	v := New(ghClient, cache, "myorg", false, discardLogger())
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
 	result, err := v.Validate(context.Background(), "fake-token-teams")
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
@@ -414,6 +836,9 @@ func TestValidate_TeamsExtracted(t *testing.T) {
 			t.Errorf("team[%d]: expected %q, got %q", i, expected, result.Teams[i])
 		}
 	}
+	if want := []string{"Platform", "Security", "Site Reliability"}; !slices.Equal(result.TeamNames, want) {
+		t.Errorf("expected team names %q, got %q", want, result.TeamNames)
+	}
 
 	if result.Login != "teamuser" {
 		t.Errorf("expected login 'teamuser', got %q", result.Login)
@@ -422,3 +847,964 @@ func TestValidate_TeamsExtracted(t *testing.T) {
 		t.Errorf("expected ID 77, got %d", result.ID)
 	}
 }
+
+func TestValidate_TokenPrefixes(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "fine-grained PAT", token: "github_pat_11AAAAAA"},
+		{name: "classic PAT", token: "ghp_abc123"},
+		{name: "OAuth token", token: "gho_abc123"},
+		{name: "user-to-server token", token: "ghu_abc123"},
+		{name: "installation token", token: "ghs_abc123", wantErr: ErrUnauthorized},
+		{name: "no prefix", token: "not-a-github-token", wantErr: ErrUnauthorized},
+		{name: "wrong case", token: "GHP_abc123", wantErr: ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getUserCalled := false
+			ghClient := &mockGitHubClient{
+				getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+					getUserCalled = true
+					return &github.User{Login: "octocat", ID: 1}, false, nil
+				},
+				checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+					return nil
+				},
+			}
+
+			cache := newMockCache()
+			v := New(ghClient, cache, []string{"myorg"}, false, discardLogger(),
+				WithTokenPrefixes(KnownTokenPrefixes))
+			_, err := v.Validate(context.Background(), tt.token)
+
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got: %v", tt.wantErr, err)
+			}
+			if getUserCalled {
+				t.Error("expected GitHub not to be called for a rejected prefix")
+			}
+			if entry, ok := cache.store[tt.token]; !ok || !errors.Is(entry.err, ErrUnauthorized) {
+				t.Errorf("expected the rejection to be cached, got ok=%v err=%v", ok, entry.err)
+			}
+		})
+	}
+}
+
+func TestValidate_LoginPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		login   string
+		wantErr error
+	}{
+		{"matching login", "svc-deploy", nil},
+		{"non-matching login", "octocat", ErrLoginNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orgChecked := false
+			ghClient := &mockGitHubClient{
+				getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+					return &github.User{Login: tt.login, ID: 1}, false, nil
+				},
+				checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+					orgChecked = true
+					return nil
+				},
+				listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+					return nil, nil
+				},
+			}
+
+			v := New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger(),
+				WithLoginPattern(regexp.MustCompile(`^svc-[a-z]+$`)))
+			result, err := v.Validate(context.Background(), "fake-token")
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got: %v", tt.wantErr, err)
+				}
+				if orgChecked {
+					t.Error("expected org membership not to be checked for a disallowed login")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if result.Login != tt.login {
+				t.Errorf("expected login %q, got %q", tt.login, result.Login)
+			}
+		})
+	}
+}
+
+func TestValidate_RequiredTeams(t *testing.T) {
+	tests := []struct {
+		name          string
+		requiredTeams []string
+		wantErr       error
+	}{
+		{"empty list allows all", nil, nil},
+		{"matching team", []string{"sre", "Platform-Eng"}, nil},
+		{"no matching team", []string{"sre", "security"}, ErrTeamNotAuthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newMockCache()
+			ghClient := &mockGitHubClient{
+				getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+					return &github.User{Login: "teamuser", ID: 77}, false, nil
+				},
+				checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+					return nil
+				},
+				listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+					return []github.Team{
+						{Slug: "platform-eng", Organization: github.Organization{Login: "myorg"}},
+						{Slug: "backend", Organization: github.Organization{Login: "myorg"}},
+					}, nil
+				},
+			}
+
+			v := New(ghClient, cache, []string{"myorg"}, false, discardLogger(), WithRequiredTeams(tt.requiredTeams))
+			result, err := v.Validate(context.Background(), "fake-token-teams")
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got: %v", tt.wantErr, err)
+				}
+				if _, ok := cache.store["fake-token-teams"]; ok {
+					t.Error("expected denied result not to be cached as a success")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if result.Login != "teamuser" {
+				t.Errorf("expected login 'teamuser', got %q", result.Login)
+			}
+		})
+	}
+}
+
+func TestValidate_RequiredTeamsWithTeamsDisabled(t *testing.T) {
+	tests := []struct {
+		name        string
+		states      map[string]string
+		wantErr     error
+		wantChecked []string
+	}{
+		{
+			name:        "active in first",
+			states:      map[string]string{"sre": github.TeamMembershipActive},
+			wantChecked: []string{"sre"},
+		},
+		{
+			name:        "active in second",
+			states:      map[string]string{"platform-eng": github.TeamMembershipActive},
+			wantChecked: []string{"sre", "platform-eng"},
+		},
+		{
+			name:        "pending only",
+			states:      map[string]string{"sre": github.TeamMembershipPending},
+			wantErr:     ErrTeamNotAuthorized,
+			wantChecked: []string{"sre", "platform-eng"},
+		},
+		{
+			name:        "member of none",
+			wantErr:     ErrTeamNotAuthorized,
+			wantChecked: []string{"sre", "platform-eng"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checked []string
+			ghClient := &mockGitHubClient{
+				getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+					return &github.User{Login: "teamuser", ID: 77}, false, nil
+				},
+				checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+					return nil
+				},
+				listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+					t.Error("ListUserTeams should not be called when teams are disabled")
+					return nil, nil
+				},
+				checkTeamMembership: func(ctx context.Context, token, org, teamSlug, username string) (*github.TeamMembership, error) {
+					checked = append(checked, teamSlug)
+					state, ok := tt.states[teamSlug]
+					if !ok {
+						return nil, github.ErrNotTeamMember
+					}
+					return &github.TeamMembership{Role: "member", State: state}, nil
+				},
+			}
+
+			v := New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger(),
+				WithTeamsDisabled(), WithRequiredTeams([]string{"sre", "platform-eng"}))
+			result, err := v.Validate(context.Background(), "fake-token-teams")
+
+			if !slices.Equal(checked, tt.wantChecked) {
+				t.Errorf("checked teams = %v, want %v", checked, tt.wantChecked)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if !result.TeamsDisabled {
+				t.Error("expected TeamsDisabled to be set")
+			}
+		})
+	}
+}
+
+func TestValidate_RequiredTeamsWithTeamsDisabledError(t *testing.T) {
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return &github.User{Login: "teamuser", ID: 77}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			return nil
+		},
+		checkTeamMembership: func(ctx context.Context, token, org, teamSlug, username string) (*github.TeamMembership, error) {
+			return nil, github.ErrRateLimited
+		},
+	}
+
+	v := New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger(),
+		WithTeamsDisabled(), WithRequiredTeams([]string{"sre"}))
+	if _, err := v.Validate(context.Background(), "fake-token-teams"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got: %v", err)
+	}
+}
+
+func TestValidate_MultipleOrgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		memberOf    map[string]bool
+		wantOrg     string
+		wantErr     error
+		wantChecked []string
+	}{
+		{
+			name:        "member of first",
+			memberOf:    map[string]bool{"org-a": true, "org-b": true},
+			wantOrg:     "org-a",
+			wantChecked: []string{"org-a"},
+		},
+		{
+			name:        "member of second only",
+			memberOf:    map[string]bool{"org-b": true},
+			wantOrg:     "org-b",
+			wantChecked: []string{"org-a", "org-b"},
+		},
+		{
+			name:        "member of none",
+			memberOf:    map[string]bool{},
+			wantErr:     ErrNotOrgMember,
+			wantChecked: []string{"org-a", "org-b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checked []string
+			var teamsOrg string
+			ghClient := &mockGitHubClient{
+				getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+					return &github.User{Login: "partner", ID: 5}, false, nil
+				},
+				checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+					checked = append(checked, org)
+					if tt.memberOf[org] {
+						return nil
+					}
+					return github.ErrNotOrgMember
+				},
+				listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+					teamsOrg = org
+					return []github.Team{{Slug: org + "-team", Organization: github.Organization{Login: org}}}, nil
+				},
+			}
+
+			v := New(ghClient, newMockCache(), []string{"org-a", "org-b"}, false, discardLogger())
+			result, err := v.Validate(context.Background(), "fake-token-orgs")
+
+			if !slices.Equal(checked, tt.wantChecked) {
+				t.Errorf("checked orgs = %v, want %v", checked, tt.wantChecked)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if result.Org != tt.wantOrg {
+				t.Errorf("expected org %q, got %q", tt.wantOrg, result.Org)
+			}
+			if teamsOrg != tt.wantOrg {
+				t.Errorf("expected teams listed for %q, got %q", tt.wantOrg, teamsOrg)
+			}
+			if len(result.Teams) != 1 || result.Teams[0] != tt.wantOrg+"-team" {
+				t.Errorf("unexpected teams: %v", result.Teams)
+			}
+		})
+	}
+}
+
+func TestValidate_MembershipAndTeamsConcurrent(t *testing.T) {
+	teamsStarted := make(chan struct{})
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return &github.User{Login: "octocat", ID: 1}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			// Membership does not complete until the teams call is in flight.
+			select {
+			case <-teamsStarted:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("teams call was not issued concurrently")
+			}
+		},
+		listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+			close(teamsStarted)
+			return []github.Team{{Slug: "backend", Organization: github.Organization{Login: org}}}, nil
+		},
+	}
+
+	v := New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger())
+	result, err := v.Validate(context.Background(), "fake-token-concurrent")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(result.Teams) != 1 || result.Teams[0] != "backend" {
+		t.Errorf("unexpected teams: %v", result.Teams)
+	}
+}
+
+func TestValidate_MembershipFailureCancelsTeams(t *testing.T) {
+	var teamsCanceled atomic.Bool
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return &github.User{Login: "outsider", ID: 2}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			time.Sleep(10 * time.Millisecond)
+			return github.ErrNotOrgMember
+		},
+		listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+			select {
+			case <-ctx.Done():
+				teamsCanceled.Store(true)
+				return nil, ctx.Err()
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("teams call was not canceled")
+			}
+		},
+	}
+
+	v := New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger())
+
+	start := time.Now()
+	_, err := v.Validate(context.Background(), "fake-token-cancel")
+	if !errors.Is(err, ErrNotOrgMember) {
+		t.Fatalf("expected ErrNotOrgMember, got: %v", err)
+	}
+	if !teamsCanceled.Load() {
+		t.Error("expected the teams call to be canceled")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Validate took %v; expected the teams call to be canceled promptly", elapsed)
+	}
+}
+
+func TestValidate_TeamsDisabled(t *testing.T) {
+	cache := newMockCache()
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return &github.User{Login: "octocat", ID: 1}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			return nil
+		},
+		listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+			t.Error("ListUserTeams should not be called when teams are disabled")
+			return nil, nil
+		},
+	}
+
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger(), WithTeamsDisabled())
+	result, err := v.Validate(context.Background(), "fake-token-noteams")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result.Teams == nil || len(result.Teams) != 0 {
+		t.Errorf("expected empty non-nil teams, got %#v", result.Teams)
+	}
+	if !result.TeamsDisabled {
+		t.Error("expected TeamsDisabled to be set")
+	}
+
+	cached, ok := cache.store["fake-token-noteams"]
+	if !ok {
+		t.Fatal("expected result to be cached")
+	}
+	if !cached.result.TeamsDisabled {
+		t.Error("expected cached result to record that teams were disabled")
+	}
+}
+
+func TestValidate_CachedTeamsStateMismatchIsMiss(t *testing.T) {
+	cache := newMockCache()
+	cache.store["fake-token-stale"] = mockCacheEntry{
+		result: ValidationResult{Login: "octocat", ID: 1, Org: "myorg", Teams: []string{}, TeamsDisabled: true},
+	}
+
+	var teamsCalled bool
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return &github.User{Login: "octocat", ID: 1}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			return nil
+		},
+		listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+			teamsCalled = true
+			return []github.Team{{Slug: "backend", Organization: github.Organization{Login: org}}}, nil
+		},
+	}
+
+	// Teams are enabled, so the entry cached while they were disabled must
+	// not be served.
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
+	result, err := v.Validate(context.Background(), "fake-token-stale")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !teamsCalled {
+		t.Error("expected teams to be listed")
+	}
+	if len(result.Teams) != 1 || result.Teams[0] != "backend" {
+		t.Errorf("unexpected teams: %v", result.Teams)
+	}
+}
+
+func TestValidate_TeamsBestEffort(t *testing.T) {
+	cache := newMockCache()
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return &github.User{Login: "octocat", ID: 1}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			return nil
+		},
+		listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+			return nil, errors.New("github: unexpected status 502")
+		},
+	}
+
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger(), WithTeamsBestEffort())
+	result, err := v.Validate(context.Background(), "fake-token-degraded")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !result.TeamsDegraded {
+		t.Error("expected TeamsDegraded to be set")
+	}
+	if len(result.Teams) != 0 {
+		t.Errorf("expected no teams, got %v", result.Teams)
+	}
+	if _, ok := cache.store["fake-token-degraded"]; ok {
+		t.Error("expected degraded result not to be cached")
+	}
+}
+
+func TestValidate_DecisionEvent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer tp.Shutdown(context.Background())
+
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	tests := []struct {
+		name     string
+		isMember bool
+		want     map[string]any
+	}{
+		{
+			name:     "success",
+			isMember: true,
+			want: map[string]any{
+				"result":       "success",
+				"reason":       "authorized",
+				"login":        "octocat",
+				"org":          "myorg",
+				"cache_status": "miss",
+				"api_calls":    int64(3),
+			},
+		},
+		{
+			name:     "denied",
+			isMember: false,
+			want: map[string]any{
+				"result":       "forbidden",
+				"reason":       ErrNotOrgMember.Error(),
+				"login":        "octocat",
+				"org":          "",
+				"cache_status": "miss",
+				"api_calls":    int64(3),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghClient := &mockGitHubClient{
+				getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+					return &github.User{Login: "octocat", ID: 1}, false, nil
+				},
+				checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+					if tt.isMember {
+						return nil
+					}
+					return github.ErrNotOrgMember
+				},
+				listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+					return nil, nil
+				},
+			}
+
+			v := New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger())
+			v.Validate(context.Background(), "fake-token-decision-"+tt.name)
+
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+			if span.Name() != "validate_token" {
+				t.Fatalf("expected validate_token span, got %q", span.Name())
+			}
+
+			var event *sdktrace.Event
+			for i, e := range span.Events() {
+				if e.Name == "auth.decision" {
+					event = &span.Events()[i]
+				}
+			}
+			if event == nil {
+				t.Fatal("expected an auth.decision event")
+			}
+
+			got := map[string]any{}
+			for _, kv := range event.Attributes {
+				got[string(kv.Key)] = kv.Value.AsInterface()
+			}
+			for k, want := range tt.want {
+				if got[k] != want {
+					t.Errorf("attribute %s = %v, want %v", k, got[k], want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_NegativeCacheHitResult(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer tp.Shutdown(context.Background())
+
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	tests := []struct {
+		name      string
+		cachedErr error
+		want      string
+	}{
+		{name: "unauthorized", cachedErr: fmt.Errorf("%w", ErrUnauthorized), want: resultUnauthorized},
+		{name: "not org member", cachedErr: fmt.Errorf("%w", ErrNotOrgMember), want: resultForbidden},
+		{name: "classic PAT", cachedErr: fmt.Errorf("%w", ErrClassicPAT), want: resultForbidden},
+		{name: "transient error", cachedErr: errors.New("getting user: github: unexpected status 500"), want: resultError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newMockCache()
+			cache.Set("fake-token", ValidationResult{}, tt.cachedErr)
+
+			v := New(&mockGitHubClient{}, cache, []string{"myorg"}, false, discardLogger())
+			if _, err := v.Validate(context.Background(), "fake-token"); !errors.Is(err, tt.cachedErr) {
+				t.Fatalf("expected the cached error, got: %v", err)
+			}
+
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+			got := map[string]any{}
+			for _, kv := range span.Attributes() {
+				got[string(kv.Key)] = kv.Value.AsInterface()
+			}
+			if got["cache.hit"] != true {
+				t.Errorf("cache.hit = %v, want true", got["cache.hit"])
+			}
+			if got["auth.result"] != tt.want {
+				t.Errorf("auth.result = %v, want %q", got["auth.result"], tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_DurationMetric(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	defer otel.SetMeterProvider(prev)
+
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return &github.User{Login: "octocat", ID: 1}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			return nil
+		},
+		listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+			return nil, nil
+		},
+	}
+	v := New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger())
+
+	// A miss followed by a cache hit.
+	for range 2 {
+		if _, err := v.Validate(context.Background(), "fake-token-duration"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	var hist *metricdata.Histogram[float64]
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == "github_auth.validation.duration" {
+				h, ok := m.Data.(metricdata.Histogram[float64])
+				if !ok {
+					t.Fatalf("unexpected data type %T", m.Data)
+				}
+				hist = &h
+			}
+		}
+	}
+	if hist == nil {
+		t.Fatal("expected a github_auth.validation.duration metric")
+	}
+
+	counts := map[bool]uint64{}
+	for _, dp := range hist.DataPoints {
+		if result, _ := dp.Attributes.Value("result"); result.AsString() != "success" {
+			t.Errorf("result = %q, want %q", result.AsString(), "success")
+		}
+		hit, _ := dp.Attributes.Value("cache.hit")
+		counts[hit.AsBool()] += dp.Count
+	}
+	if counts[false] != 1 || counts[true] != 1 {
+		t.Errorf("expected one miss and one hit measurement, got %v", counts)
+	}
+}
+
+func TestValidate_ClientCanceled(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	defer otel.SetMeterProvider(prev)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			cancel()
+			return nil, false, fmt.Errorf("Get \"https://api.github.com/user\": %w", ctx.Err())
+		},
+	}
+
+	var logBuf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logBuf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	cache := newMockCache()
+	v := New(ghClient, cache, []string{"myorg"}, false, logger, WithErrorCacheTTL(time.Minute))
+
+	_, err := v.Validate(ctx, "fake-token")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+	if strings.Contains(logBuf.String(), "level=ERROR") {
+		t.Errorf("expected no ERROR log record, got:\n%s", logBuf.String())
+	}
+	if _, _, ok := cache.Get("fake-token"); ok {
+		t.Error("expected a canceled validation not to be cached")
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+	counts := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "github_auth.validation.total" {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("unexpected data type %T", m.Data)
+			}
+			for _, dp := range sum.DataPoints {
+				result, _ := dp.Attributes.Value("result")
+				counts[result.AsString()] += dp.Value
+			}
+		}
+	}
+	if counts["error"] != 0 {
+		t.Errorf("expected no result=error count, got %d", counts["error"])
+	}
+	if counts["canceled"] != 1 {
+		t.Errorf("expected one result=canceled count, got %v", counts)
+	}
+}
+
+func TestValidate_Timeout(t *testing.T) {
+	var teamsCalled atomic.Bool
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			return &github.User{Login: "testuser", ID: 42}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			// Block like a hung GitHub request until the context ends.
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		listUserTeams: func(ctx context.Context, token, org string) ([]github.Team, error) {
+			teamsCalled.Store(true)
+			return nil, nil
+		},
+	}
+	cache := newMockCache()
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger(),
+		WithTimeout(20*time.Millisecond), WithErrorCacheTTL(time.Second))
+
+	start := time.Now()
+	_, err := v.Validate(context.Background(), "fake-token")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the deadline to end validation, took %v", elapsed)
+	}
+	if _, ok := cache.store["fake-token"]; ok {
+		t.Fatal("expected timed-out validation not to be cached")
+	}
+
+	// A deadline set by the caller is not reported as ErrTimeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	v = New(ghClient, newMockCache(), []string{"myorg"}, false, discardLogger(), WithTimeout(time.Minute))
+	if _, err := v.Validate(ctx, "fake-token"); err == nil || errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected a non-timeout error for the caller's deadline, got: %v", err)
+	}
+}
+
+func TestValidate_IdentityCache(t *testing.T) {
+	var getUserCalls, membershipCalls int
+	membershipErr := error(nil)
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			getUserCalls++
+			return &github.User{Login: "testuser", ID: 42, Email: "testuser@example.com"}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			membershipCalls++
+			if username != "testuser" {
+				t.Errorf("expected membership check for testuser, got %q", username)
+			}
+			return membershipErr
+		},
+	}
+	cache, identities := newMockCache(), newMockCache()
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger(), WithIdentityCache(identities))
+
+	// expireResult simulates the main cache entry reaching its shorter TTL.
+	expireResult := func() {
+		entry := cache.store["fake-token"]
+		entry.expiresAt = time.Now().Add(-time.Millisecond)
+		cache.store["fake-token"] = entry
+	}
+
+	if _, err := v.Validate(context.Background(), "fake-token"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if identity := identities.store["fake-token"].result; identity.Login != "testuser" || identity.ID != 42 {
+		t.Fatalf("expected the identity to be cached, got %+v", identity)
+	}
+
+	// After the result expires, the identity is reused and membership is
+	// checked again.
+	expireResult()
+	result, err := v.Validate(context.Background(), "fake-token")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Login != "testuser" || result.ID != 42 || result.Email != "testuser@example.com" {
+		t.Errorf("unexpected result from cached identity: %+v", result)
+	}
+	if getUserCalls != 1 || membershipCalls != 2 {
+		t.Fatalf("expected 1 GetUser and 2 membership calls, got %d and %d", getUserCalls, membershipCalls)
+	}
+
+	// A user removed from the org is denied despite the cached identity.
+	expireResult()
+	membershipErr = github.ErrNotOrgMember
+	if _, err := v.Validate(context.Background(), "fake-token"); !errors.Is(err, ErrNotOrgMember) {
+		t.Fatalf("expected ErrNotOrgMember, got: %v", err)
+	}
+
+	// A token revoked after its identity was cached is unauthorized and
+	// its identity is forgotten once the not-member entry expires.
+	expireResult()
+	membershipErr = github.ErrUnauthorized
+	if _, err := v.Validate(context.Background(), "fake-token"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got: %v", err)
+	}
+	if _, ok := identities.store["fake-token"]; ok {
+		t.Error("expected the identity of a revoked token to be deleted")
+	}
+	if getUserCalls != 1 {
+		t.Fatalf("expected 1 GetUser call, got %d", getUserCalls)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			if token != "service-token" {
+				return nil, false, github.ErrUnauthorized
+			}
+			return &github.User{Login: "ci-bot", ID: 7}, false, nil
+		},
+		checkOrgMembership: func(ctx context.Context, token, org, username string) error {
+			return nil
+		},
+	}
+	cache := newMockCache()
+	v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
+
+	if warmed := v.Warmup(context.Background(), []string{"revoked-token", "service-token"}); warmed != 1 {
+		t.Fatalf("expected 1 token warmed, got %d", warmed)
+	}
+	entry, ok := cache.store["service-token"]
+	if !ok || entry.err != nil || entry.result.Login != "ci-bot" {
+		t.Fatalf("expected a cached result for service-token, got %+v (ok=%v)", entry, ok)
+	}
+}
+
+func TestValidate_ErrorCacheTTL(t *testing.T) {
+	transient := errors.New("connection refused")
+	var getUserCalls int
+	ghClient := &mockGitHubClient{
+		getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+			getUserCalls++
+			return nil, false, transient
+		},
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		cache := newMockCache()
+		v := New(ghClient, cache, []string{"myorg"}, false, discardLogger())
+
+		if _, err := v.Validate(context.Background(), "fake-token"); !errors.Is(err, transient) {
+			t.Fatalf("expected transient error, got: %v", err)
+		}
+		if _, ok := cache.store["fake-token"]; ok {
+			t.Fatal("expected generic error not to be cached without an error cache TTL")
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		getUserCalls = 0
+		cache := newMockCache()
+		v := New(ghClient, cache, []string{"myorg"}, false, discardLogger(), WithErrorCacheTTL(time.Second))
+
+		if _, err := v.Validate(context.Background(), "fake-token"); !errors.Is(err, transient) {
+			t.Fatalf("expected transient error, got: %v", err)
+		}
+		entry, ok := cache.store["fake-token"]
+		if !ok {
+			t.Fatal("expected generic error to be cached")
+		}
+		if entry.ttl != time.Second {
+			t.Errorf("expected error cache TTL %v, got %v", time.Second, entry.ttl)
+		}
+
+		// Within the TTL the cached error is served without calling GitHub.
+		if _, err := v.Validate(context.Background(), "fake-token"); !errors.Is(err, transient) {
+			t.Fatalf("expected cached transient error, got: %v", err)
+		}
+		if getUserCalls != 1 {
+			t.Fatalf("expected 1 GetUser call within the TTL, got %d", getUserCalls)
+		}
+
+		// Once the TTL has elapsed GitHub is called again.
+		entry.expiresAt = time.Now().Add(-time.Millisecond)
+		cache.store["fake-token"] = entry
+		if _, err := v.Validate(context.Background(), "fake-token"); !errors.Is(err, transient) {
+			t.Fatalf("expected transient error, got: %v", err)
+		}
+		if getUserCalls != 2 {
+			t.Fatalf("expected 2 GetUser calls after the TTL, got %d", getUserCalls)
+		}
+	})
+
+	t.Run("canceled is not cached", func(t *testing.T) {
+		cache := newMockCache()
+		v := New(&mockGitHubClient{
+			getUser: func(ctx context.Context, token string) (*github.User, bool, error) {
+				return nil, false, context.Canceled
+			},
+		}, cache, []string{"myorg"}, false, discardLogger(), WithErrorCacheTTL(time.Second))
+
+		if _, err := v.Validate(context.Background(), "fake-token"); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got: %v", err)
+		}
+		if _, ok := cache.store["fake-token"]; ok {
+			t.Fatal("expected canceled request not to be cached")
+		}
+	})
+}
@@ -0,0 +1,133 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package signature signs and verifies the identity headers that
+// traefik-github-auth adds to authenticated requests. Upstream services
+// that share the signing key can use Verify to confirm that the headers
+// were produced by traefik-github-auth and not forged by a client that
+// reached the upstream directly.
+//
+// Every identity header of a response is signed: the headers with the
+// identity prefix, X-Auth-Teams-Status, and any configured team headers.
+// The signed header names are listed, sorted and comma-separated, in the
+// X-Auth-Signed-Headers header. The signature is an HMAC-SHA256 over one
+// "name:value" line per listed header, each terminated by a newline, and
+// is sent in the X-Auth-Signature header as "sha256=<hex digest>". A
+// signature is not bound to a request, so it does not protect against
+// replay of a previously observed set of headers.
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Header is the name of the response header carrying the signature.
+const Header = "X-Auth-Signature"
+
+// SignedHeadersHeader is the name of the response header listing the
+// headers covered by the signature.
+const SignedHeadersHeader = "X-Auth-Signed-Headers"
+
+// TeamsStatusHeader is the name of the teams status header. It is always
+// signed when present.
+const TeamsStatusHeader = "X-Auth-Teams-Status"
+
+// DefaultHeaderPrefix is the default prefix of the identity headers.
+const DefaultHeaderPrefix = "X-Auth-User-"
+
+// scheme prefixes the hex-encoded digest in the signature header.
+const scheme = "sha256="
+
+// Errors returned by Verify.
+var (
+	ErrMissingSignature = errors.New("signature: missing " + Header + " header")
+	ErrInvalidSignature = errors.New("signature: invalid signature")
+	ErrUnsignedHeader   = errors.New("signature: identity header is not signed")
+)
+
+// Sign signs the values of the named headers in h and sets Header and
+// SignedHeadersHeader on h. Names are canonicalized and sorted so that the
+// signed form does not depend on the order in which headers were set.
+// Names that are not present in h are skipped.
+func Sign(key []byte, h http.Header, names []string) {
+	signed := make([]string, 0, len(names))
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		if _, ok := h[name]; ok {
+			signed = append(signed, name)
+		}
+	}
+	slices.Sort(signed)
+	signed = slices.Compact(signed)
+
+	h.Set(SignedHeadersHeader, strings.Join(signed, ","))
+	h.Set(Header, scheme+hex.EncodeToString(mac(key, h, signed)))
+}
+
+// Verify checks the signature in h over the headers it lists. It also
+// returns ErrUnsignedHeader if h carries a header with the given identity
+// prefix (e.g. DefaultHeaderPrefix), the teams status header, or one of
+// the required header names (such as configured team headers) that is not
+// covered by the signature.
+func Verify(key []byte, h http.Header, prefix string, required ...string) error {
+	sig := h.Get(Header)
+	if sig == "" {
+		return ErrMissingSignature
+	}
+	digest, err := hex.DecodeString(strings.TrimPrefix(sig, scheme))
+	if err != nil || !strings.HasPrefix(sig, scheme) {
+		return ErrInvalidSignature
+	}
+
+	var signed []string
+	if v := h.Get(SignedHeadersHeader); v != "" {
+		signed = strings.Split(v, ",")
+	}
+	if !hmac.Equal(digest, mac(key, h, signed)) {
+		return ErrInvalidSignature
+	}
+
+	isSigned := func(name string) bool {
+		return slices.ContainsFunc(signed, func(s string) bool { return strings.EqualFold(s, name) })
+	}
+	for name := range h {
+		if strings.EqualFold(name, Header) || strings.EqualFold(name, SignedHeadersHeader) {
+			continue
+		}
+		identity := hasPrefixFold(name, prefix) || strings.EqualFold(name, TeamsStatusHeader)
+		if identity && !isSigned(name) {
+			return ErrUnsignedHeader
+		}
+	}
+	for _, name := range required {
+		if !isSigned(name) {
+			return ErrUnsignedHeader
+		}
+	}
+	return nil
+}
+
+// mac computes the HMAC-SHA256 of the canonical form of the named headers
+// in h.
+func mac(key []byte, h http.Header, names []string) []byte {
+	m := hmac.New(sha256.New, key)
+	for _, name := range names {
+		m.Write([]byte(strings.ToLower(name)))
+		m.Write([]byte{':'})
+		m.Write([]byte(strings.Join(h.Values(name), ",")))
+		m.Write([]byte{'\n'})
+	}
+	return m.Sum(nil)
+}
+
+// hasPrefixFold reports whether s begins with prefix, ignoring case.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
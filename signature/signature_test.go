@@ -0,0 +1,190 @@
+// Licensed to Andrew Kroh under one or more agreements.
+// Andrew Kroh licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package signature
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var testKey = []byte("test-signing-key")
+
+// identityNames are the headers signed by signedHeader.
+var identityNames = []string{
+	DefaultHeaderPrefix + "Login",
+	DefaultHeaderPrefix + "Id",
+	DefaultHeaderPrefix + "Org",
+	DefaultHeaderPrefix + "Org-Role",
+	DefaultHeaderPrefix + "Teams",
+	TeamsStatusHeader,
+	"X-Is-Admin",
+}
+
+func signedHeader() http.Header {
+	h := http.Header{}
+	h.Set(DefaultHeaderPrefix+"Login", "octocat")
+	h.Set(DefaultHeaderPrefix+"Id", "1")
+	h.Set(DefaultHeaderPrefix+"Org", "my-org")
+	h.Set(DefaultHeaderPrefix+"Org-Role", "member")
+	h.Set(DefaultHeaderPrefix+"Teams", "backend,frontend")
+	h.Set(TeamsStatusHeader, "ok")
+	h.Set("X-Is-Admin", "false")
+	Sign(testKey, h, identityNames)
+	return h
+}
+
+func TestSign(t *testing.T) {
+	h := signedHeader()
+	sig := h.Get(Header)
+	if !strings.HasPrefix(sig, "sha256=") || len(sig) != len("sha256=")+64 {
+		t.Fatalf("unexpected signature format %q", sig)
+	}
+	want := "X-Auth-Teams-Status,X-Auth-User-Id,X-Auth-User-Login,X-Auth-User-Org,X-Auth-User-Org-Role,X-Auth-User-Teams,X-Is-Admin"
+	if got := h.Get(SignedHeadersHeader); got != want {
+		t.Errorf("signed headers = %q, want %q", got, want)
+	}
+
+	// The order in which names are given does not matter.
+	reversed := slices.Clone(identityNames)
+	slices.Reverse(reversed)
+	h2 := h.Clone()
+	Sign(testKey, h2, reversed)
+	if h2.Get(Header) != sig {
+		t.Error("expected signing to be independent of name order")
+	}
+
+	h2 = h.Clone()
+	Sign([]byte("other-key"), h2, identityNames)
+	if h2.Get(Header) == sig {
+		t.Error("expected a different key to produce a different signature")
+	}
+
+	// Names of absent headers are skipped.
+	h2 = h.Clone()
+	Sign(testKey, h2, append(slices.Clone(identityNames), DefaultHeaderPrefix+"Email"))
+	if h2.Get(SignedHeadersHeader) != want {
+		t.Errorf("expected absent headers to be skipped, got %q", h2.Get(SignedHeadersHeader))
+	}
+}
+
+func TestVerify_DetectsChangeToAnyHeader(t *testing.T) {
+	for _, name := range identityNames {
+		t.Run(name, func(t *testing.T) {
+			h := signedHeader()
+			h.Set(name, h.Get(name)+"x")
+			if err := Verify(testKey, h, DefaultHeaderPrefix); !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("Verify() error = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   func() http.Header
+		key      []byte
+		required []string
+		wantErr  error
+	}{
+		{
+			name:     "valid",
+			header:   signedHeader,
+			key:      testKey,
+			required: []string{"X-Is-Admin"},
+		},
+		{
+			name: "valid without teams",
+			header: func() http.Header {
+				h := http.Header{}
+				h.Set(DefaultHeaderPrefix+"Login", "octocat")
+				h.Set(DefaultHeaderPrefix+"Id", "1")
+				h.Set(DefaultHeaderPrefix+"Org", "my-org")
+				Sign(testKey, h, identityNames)
+				return h
+			},
+			key: testKey,
+		},
+		{
+			name: "removed header",
+			header: func() http.Header {
+				h := signedHeader()
+				h.Del("X-Is-Admin")
+				return h
+			},
+			key:     testKey,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "header dropped from signed list",
+			header: func() http.Header {
+				h := signedHeader()
+				h.Set(SignedHeadersHeader, strings.TrimSuffix(h.Get(SignedHeadersHeader), ",X-Is-Admin"))
+				return h
+			},
+			key:     testKey,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "added identity header",
+			header: func() http.Header {
+				h := signedHeader()
+				h.Set(DefaultHeaderPrefix+"Email", "admin@example.com")
+				return h
+			},
+			key:     testKey,
+			wantErr: ErrUnsignedHeader,
+		},
+		{
+			name: "required header not signed",
+			header: func() http.Header {
+				h := signedHeader()
+				h.Set("X-Is-Owner", "true")
+				return h
+			},
+			key:      testKey,
+			required: []string{"X-Is-Owner"},
+			wantErr:  ErrUnsignedHeader,
+		},
+		{
+			name:    "wrong key",
+			header:  signedHeader,
+			key:     []byte("other-key"),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "missing signature",
+			header: func() http.Header {
+				h := signedHeader()
+				h.Del(Header)
+				return h
+			},
+			key:     testKey,
+			wantErr: ErrMissingSignature,
+		},
+		{
+			name: "malformed signature",
+			header: func() http.Header {
+				h := signedHeader()
+				h.Set(Header, "md5=abc")
+				return h
+			},
+			key:     testKey,
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.key, tt.header(), DefaultHeaderPrefix, tt.required...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}